@@ -2,6 +2,10 @@ package apm
 
 import (
 	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
 
 	"github.com/starclusterteam/go-starbox/log"
 )
@@ -36,12 +40,12 @@ func NewConsoleReporter() ErrorReporting {
 }
 
 func (c *ConsoleReporter) Report(ctx context.Context, err error) error {
-	log.Errorf("received error: %v", err)
+	log.FromContext(ctx).Errorf("received error: %v", err)
 	return nil
 }
 
 func (c *ConsoleReporter) ReportAsync(ctx context.Context, err error) {
-	log.Errorf("received error: %v", err)
+	log.FromContext(ctx).Errorf("received error: %v", err)
 }
 
 func SetNullReporter() {
@@ -51,3 +55,59 @@ func SetNullReporter() {
 func SetConsoleReporter() {
 	GlobalReporter = NewConsoleReporter()
 }
+
+// Report reports err with the GlobalReporter, redacted by RedactError.
+func Report(ctx context.Context, err error) error {
+	return GlobalReporter.Report(ctx, RedactError(err))
+}
+
+// ReportAsync reports err asynchronously with the GlobalReporter, redacted by RedactError.
+func ReportAsync(ctx context.Context, err error) {
+	GlobalReporter.ReportAsync(ctx, RedactError(err))
+}
+
+// RedactError returns err with its message redacted by the logger redaction, see log.RedactString, so that
+// the reporters don't leak more than the logs. The redacted error keeps the stack trace of err but doesn't
+// unwrap to it. err is returned as is if it has nothing to redact.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	redacted := log.RedactString(msg)
+	if redacted == msg {
+		return err
+	}
+
+	return &redactedError{msg: redacted, err: err}
+}
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// StackTrace returns the stack trace of the original error, if any.
+func (e *redactedError) StackTrace() errors.StackTrace {
+	if st, ok := e.err.(stackTracer); ok {
+		return st.StackTrace()
+	}
+	return nil
+}
+
+// Format formats the error like the errors of github.com/pkg/errors, %+v printing the stack trace.
+func (e *redactedError) Format(s fmt.State, verb rune) {
+	io.WriteString(s, e.msg)
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%+v", e.StackTrace())
+	}
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/health"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+const (
+	shutdownTimeoutEnv     = "SHUTDOWN_TIMEOUT"
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// Component is a long running part of an application. web.Server, *scrpc.Server and
+// metrics.Server all satisfy this interface.
+//
+// Run must block until the component stops, either because it failed or because Stop was called.
+// Stop must make Run return and should not return before the component finished draining or
+// the given context expired.
+type Component interface {
+	Run() error
+	Stop(ctx context.Context) error
+}
+
+// App runs a set of components concurrently and shuts them down gracefully when one of them
+// fails or when the process receives a termination signal.
+type App struct {
+	components []namedComponent
+	options    options
+}
+
+type namedComponent struct {
+	name string
+	Component
+}
+
+type options struct {
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+}
+
+// New returns a new App. Components are added with Add. If no shutdown timeout is given through
+// the options, the duration defined in the SHUTDOWN_TIMEOUT environment variable will be used.
+// If SHUTDOWN_TIMEOUT is not defined, the default 30s timeout is used.
+func New(opts ...Option) *App {
+	timeout, err := config.Duration(shutdownTimeoutEnv, defaultShutdownTimeout)
+	if err != nil {
+		log.Warningf("Failed to read shutdown timeout, using %s: %v", defaultShutdownTimeout, err)
+		timeout = defaultShutdownTimeout
+	}
+
+	a := &App{
+		options: options{
+			shutdownTimeout: timeout,
+			signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		},
+	}
+
+	for _, o := range opts {
+		o(&a.options)
+	}
+
+	return a
+}
+
+// Add registers a component under the given name. Components are started in the order they were
+// added and stopped in the reverse order.
+func (a *App) Add(name string, c Component) *App {
+	a.components = append(a.components, namedComponent{name: name, Component: c})
+	return a
+}
+
+// Run starts all the components and blocks until they are stopped. See RunContext for details.
+func (a *App) Run() error {
+	return a.RunContext(context.Background())
+}
+
+// RunContext starts all the components concurrently and blocks until one of them returns, the
+// process receives one of the configured signals or the given context is canceled. It then stops
+// all the components in the reverse order they were added, within the shutdown timeout. The default
+// health registry is marked as shutting down before the components are stopped.
+// The returned error aggregates every run and stop error, or is nil if all components stopped cleanly.
+func (a *App) RunContext(ctx context.Context) error {
+	if len(a.components) == 0 {
+		return errors.New("no components to run")
+	}
+
+	if len(a.options.signals) > 0 {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, a.options.signals...)
+		defer stop()
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		stopped = make(chan string, len(a.components))
+	)
+
+	for _, c := range a.components {
+		wg.Add(1)
+		go func(c namedComponent) {
+			defer wg.Done()
+
+			log.Infof("Starting %s", c.name)
+			if err := c.Run(); err != nil {
+				log.Errorf("%s failed: %v", c.name, err)
+
+				mu.Lock()
+				errs = append(errs, errors.Wrapf(err, "failed to run %s", c.name))
+				mu.Unlock()
+			}
+
+			stopped <- c.name
+		}(c)
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Infof("Shutting down: %v", ctx.Err())
+	case name := <-stopped:
+		log.Infof("Shutting down: %s stopped", name)
+	}
+
+	// Report the application as not ready before draining, so load balancers stop sending traffic.
+	health.Shutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.options.shutdownTimeout)
+	defer cancel()
+
+	for i := len(a.components) - 1; i >= 0; i-- {
+		c := a.components[i]
+
+		log.Infof("Stopping %s", c.name)
+		if err := c.Stop(shutdownCtx); err != nil {
+			log.Errorf("Failed to stop %s: %v", c.name, err)
+
+			mu.Lock()
+			errs = append(errs, errors.Wrapf(err, "failed to stop %s", c.name))
+			mu.Unlock()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Infof("All components stopped")
+	case <-shutdownCtx.Done():
+		mu.Lock()
+		errs = append(errs, errors.Wrap(shutdownCtx.Err(), "components did not stop within the shutdown timeout"))
+		mu.Unlock()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return stderrors.Join(errs...)
+}
+
+// Run is a shorthand for creating an App with the default options, adding the given components
+// and running it. Components are named after their type.
+func Run(components ...Component) error {
+	a := New()
+	for _, c := range components {
+		a.Add(fmt.Sprintf("%T", c), c)
+	}
+
+	return a.Run()
+}
+
+// Option is used to specify functional options when creating a new App.
+type Option func(*options)
+
+// WithShutdownTimeout sets the deadline for draining all components once the shutdown starts.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithSignals overrides the signals that trigger the shutdown. It defaults to SIGINT and SIGTERM.
+// Calling WithSignals without arguments disables signal handling.
+func WithSignals(signals ...os.Signal) Option {
+	return func(o *options) {
+		o.signals = signals
+	}
+}
+
+// Func returns a Component that runs fn until it returns or Stop is called. The context given to
+// fn is canceled when Stop is called and an error caused by that cancellation is not reported.
+func Func(fn func(ctx context.Context) error) Component {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &funcComponent{
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+type funcComponent struct {
+	fn     func(ctx context.Context) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (c *funcComponent) Run() error {
+	defer close(c.done)
+
+	err := c.fn(c.ctx)
+	if c.ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *funcComponent) Stop(ctx context.Context) error {
+	c.cancel()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
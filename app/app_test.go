@@ -0,0 +1,128 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/app"
+)
+
+type fakeComponent struct {
+	name    string
+	runErr  error
+	stopErr error
+	order   *[]string
+	mu      *sync.Mutex
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newFakeComponent(name string, order *[]string, mu *sync.Mutex) *fakeComponent {
+	return &fakeComponent{name: name, order: order, mu: mu, stop: make(chan struct{})}
+}
+
+func (c *fakeComponent) Run() error {
+	if c.runErr != nil {
+		return c.runErr
+	}
+
+	<-c.stop
+	return nil
+}
+
+func (c *fakeComponent) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	*c.order = append(*c.order, c.name)
+	c.mu.Unlock()
+
+	c.once.Do(func() { close(c.stop) })
+	return c.stopErr
+}
+
+func TestRunStopsInReverseOrderOnCancel(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+
+	a := app.New(app.WithSignals()).
+		Add("first", newFakeComponent("first", &order, &mu)).
+		Add("second", newFakeComponent("second", &order, &mu)).
+		Add("third", newFakeComponent("third", &order, &mu))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := a.RunContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"third", "second", "first"}, order)
+}
+
+func TestRunStopsAllWhenOneFails(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+
+	failing := newFakeComponent("failing", &order, &mu)
+	failing.runErr = errors.New("listen failed")
+
+	stopFailing := newFakeComponent("stop-failing", &order, &mu)
+	stopFailing.stopErr = errors.New("drain failed")
+
+	a := app.New(app.WithSignals()).
+		Add("healthy", newFakeComponent("healthy", &order, &mu)).
+		Add("failing", failing).
+		Add("stop-failing", stopFailing)
+
+	err := a.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to run failing: listen failed")
+	assert.Contains(t, err.Error(), "failed to stop stop-failing: drain failed")
+	assert.Equal(t, []string{"stop-failing", "failing", "healthy"}, order)
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	stuck := app.Func(func(ctx context.Context) error {
+		select {}
+	})
+
+	a := app.New(app.WithSignals(), app.WithShutdownTimeout(10*time.Millisecond)).Add("stuck", stuck)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := a.RunContext(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestFunc(t *testing.T) {
+	started := make(chan struct{})
+	c := app.Func(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	a := app.New(app.WithSignals()).Add("worker", c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	assert.NoError(t, a.RunContext(ctx))
+}
+
+func TestRunWithoutComponents(t *testing.T) {
+	assert.Error(t, app.New().Run())
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+)
+
+// APIKeyLookup returns the principal owning an API key. It returns an error wrapping
+// ErrInvalidCredentials for unknown keys.
+type APIKeyLookup func(ctx context.Context, key string) (*Principal, error)
+
+// StaticAPIKeys returns a lookup for a fixed set of API keys, compared in constant time.
+func StaticAPIKeys(keys map[string]*Principal) APIKeyLookup {
+	return func(_ context.Context, key string) (*Principal, error) {
+		for k, p := range keys {
+			if secureCompare(k, key) {
+				return p, nil
+			}
+		}
+
+		return nil, ErrInvalidCredentials
+	}
+}
+
+type apiKeyAuthenticator struct {
+	header string
+	lookup APIKeyLookup
+}
+
+// NewAPIKeyAuthenticator returns an authenticator reading API keys from the given header, "X-API-Key"
+// if empty. The method of the principals returned by lookup is set to "api_key".
+func NewAPIKeyAuthenticator(header string, lookup APIKeyLookup) Authenticator {
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	return &apiKeyAuthenticator{header: header, lookup: lookup}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	key := creds.Get(a.header)
+	if key == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	p, err := a.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	p = clonePrincipal(p)
+	p.Method = "api_key"
+	return p, nil
+}
@@ -0,0 +1,157 @@
+// Package auth authenticates requests using pluggable authenticators: Bearer JWT, API keys and HTTP
+// Basic. It is transport agnostic, the web middleware and the scrpc interceptors read the credentials
+// from the HTTP headers and the gRPC metadata.
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Errors returned by authenticators. Implementations wrap them with the reason of the failure, so they
+// must be checked with errors.Is.
+var (
+	// ErrMissingCredentials is returned when the request carries no credentials for the authenticator.
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrMalformedCredentials is returned when the credentials can't be parsed, e.g. an unknown
+	// authorization scheme.
+	ErrMalformedCredentials = errors.New("malformed credentials")
+	// ErrInvalidToken is returned when a token is invalid or expired.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrInvalidCredentials is returned when an API key or a username and password are not valid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	// Subject identifies the principal, e.g. the "sub" claim of a JWT or the username.
+	Subject string
+	// Method is the authentication method, e.g. "jwt", "api_key" or "basic".
+	Method string
+	Scopes []string
+	Roles  []string
+	// Claims are the claims of the token for principals authenticated with a JWT.
+	Claims map[string]interface{}
+}
+
+// String returns the subject of the principal.
+func (p *Principal) String() string {
+	return p.Subject
+}
+
+// HasScopes reports whether the principal has all the given scopes.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	return containsAll(p.Scopes, scopes)
+}
+
+// HasRoles reports whether the principal has all the given roles.
+func (p *Principal) HasRoles(roles ...string) bool {
+	return containsAll(p.Roles, roles)
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Credentials gives access to the credentials of a request: the HTTP headers or the gRPC metadata.
+type Credentials interface {
+	// Get returns the value of the given header, or an empty string.
+	Get(name string) string
+}
+
+// Authenticator authenticates requests.
+type Authenticator interface {
+	// Authenticate returns the principal identified by the credentials. It returns an error wrapping
+	// ErrMissingCredentials if the credentials it expects are missing.
+	Authenticate(ctx context.Context, creds Credentials) (*Principal, error)
+}
+
+// AuthenticatorFunc is an adapter to use functions as authenticators.
+type AuthenticatorFunc func(ctx context.Context, creds Credentials) (*Principal, error)
+
+// Authenticate calls f(ctx, creds).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	return f(ctx, creds)
+}
+
+// Chain returns an authenticator trying the given authenticators in order, until one of them succeeds.
+// When all of them fail, the most specific error is returned: an invalid token or invalid credentials
+// error before malformed credentials, and malformed credentials before missing credentials.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, creds Credentials) (*Principal, error) {
+		var lastErr error = ErrMissingCredentials
+
+		for _, a := range authenticators {
+			p, err := a.Authenticate(ctx, creds)
+			if err == nil {
+				return p, nil
+			}
+
+			if errorRank(err) >= errorRank(lastErr) {
+				lastErr = err
+			}
+		}
+
+		return nil, lastErr
+	})
+}
+
+func errorRank(err error) int {
+	switch {
+	case errors.Is(err, ErrMissingCredentials):
+		return 0
+	case errors.Is(err, ErrMalformedCredentials):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// authorization returns the credentials of the Authorization header for the given scheme.
+func authorization(creds Credentials, scheme string) (string, error) {
+	header := creds.Get("Authorization")
+	if header == "" {
+		return "", ErrMissingCredentials
+	}
+
+	s, value, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(s, scheme) {
+		return "", errors.Wrapf(ErrMalformedCredentials, "expected %s authorization", scheme)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.Wrapf(ErrMalformedCredentials, "empty %s credentials", scheme)
+	}
+
+	return value, nil
+}
+
+type principalKey struct{}
+
+// NewContext returns a new context carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal carried by the context.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func basic(username, password string) headers {
+	return headers{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a := NewAPIKeyAuthenticator("", StaticAPIKeys(map[string]*Principal{
+		"key-1": {Subject: "service-1", Scopes: []string{"read"}},
+	}))
+
+	p, err := a.Authenticate(context.Background(), headers{"X-API-Key": "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "service-1", Method: "api_key", Scopes: []string{"read"}}, p)
+
+	_, err = a.Authenticate(context.Background(), headers{"X-API-Key": "key-2"})
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{})
+	assert.True(t, errors.Is(err, ErrMissingCredentials))
+}
+
+func TestBasicAuthenticator(t *testing.T) {
+	a := NewBasicAuthenticator(StaticBasicCredentials(map[string]string{"john": "secret"}))
+
+	p, err := a.Authenticate(context.Background(), basic("john", "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "john", Method: "basic"}, p)
+
+	_, err = a.Authenticate(context.Background(), basic("john", "wrong"))
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	_, err = a.Authenticate(context.Background(), basic("jane", "secret"))
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{"Authorization": "Basic !!!"})
+	assert.True(t, errors.Is(err, ErrMalformedCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{})
+	assert.True(t, errors.Is(err, ErrMissingCredentials))
+}
+
+func TestChain(t *testing.T) {
+	a := Chain(
+		NewJWTAuthenticator(HMACSecret([]byte("secret"))),
+		NewBasicAuthenticator(StaticBasicCredentials(map[string]string{"john": "secret"})),
+		NewAPIKeyAuthenticator("", StaticAPIKeys(map[string]*Principal{"key": {Subject: "service"}})),
+	)
+
+	p, err := a.Authenticate(context.Background(), basic("john", "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "john", p.Subject)
+
+	p, err = a.Authenticate(context.Background(), headers{"X-API-Key": "key"})
+	require.NoError(t, err)
+	assert.Equal(t, "service", p.Subject)
+
+	_, err = a.Authenticate(context.Background(), basic("john", "wrong"))
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{"Authorization": "Digest abc"})
+	assert.True(t, errors.Is(err, ErrMalformedCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{})
+	assert.True(t, errors.Is(err, ErrMissingCredentials))
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	p := &Principal{Subject: "john"}
+	got, ok := FromContext(NewContext(context.Background(), p))
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BasicVerifier returns the principal identified by a username and password. It returns an error
+// wrapping ErrInvalidCredentials if they are not valid.
+type BasicVerifier func(ctx context.Context, username, password string) (*Principal, error)
+
+// StaticBasicCredentials returns a verifier for a fixed set of usernames and passwords, compared in
+// constant time. The subject of the principals is the username.
+func StaticBasicCredentials(passwords map[string]string) BasicVerifier {
+	return func(_ context.Context, username, password string) (*Principal, error) {
+		expected, ok := passwords[username]
+		if !ok || !secureCompare(expected, password) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return &Principal{Subject: username}, nil
+	}
+}
+
+type basicAuthenticator struct {
+	verify BasicVerifier
+}
+
+// NewBasicAuthenticator returns an authenticator for the HTTP Basic scheme. The method of the principals
+// returned by verify is set to "basic".
+func NewBasicAuthenticator(verify BasicVerifier) Authenticator {
+	return &basicAuthenticator{verify: verify}
+}
+
+func (a *basicAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	encoded, err := authorization(creds, "Basic")
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedCredentials, "invalid base64 encoding")
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, errors.Wrap(ErrMalformedCredentials, "missing password")
+	}
+
+	p, err := a.verify(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	p = clonePrincipal(p)
+	p.Method = "basic"
+	return p, nil
+}
+
+// secureCompare compares the hashes of the strings in constant time, so that neither their content
+// nor their length leak.
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func clonePrincipal(p *Principal) *Principal {
+	c := *p
+	return &c
+}
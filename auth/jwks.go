@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// minJWKSRefreshInterval limits how often the keys are refreshed because of an unknown key id.
+const minJWKSRefreshInterval = 30 * time.Second
+
+// JWKS is a KeySource using a JSON Web Key Set. The set is reloaded every refresh interval, and when a
+// token is signed with an unknown key id, so rotated keys are picked up. If a reload fails, the keys
+// loaded previously keep being used.
+type JWKS struct {
+	fetch   func(ctx context.Context) ([]byte, error)
+	refresh time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	loadedAt    time.Time
+	lastAttempt time.Time
+}
+
+// NewJWKSFromFile returns a key set loaded from a JWKS file. A zero refresh interval disables the
+// periodic reload.
+func NewJWKSFromFile(path string, refresh time.Duration) (*JWKS, error) {
+	return newJWKS(func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}, refresh)
+}
+
+// NewJWKSFromURL returns a key set fetched from a JWKS endpoint, e.g.
+// "https://example.com/.well-known/jwks.json". A zero refresh interval disables the periodic reload.
+func NewJWKSFromURL(url string, refresh time.Duration) (*JWKS, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return newJWKS(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create request")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch keys")
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+
+		return io.ReadAll(resp.Body)
+	}, refresh)
+}
+
+func newJWKS(fetch func(ctx context.Context) ([]byte, error), refresh time.Duration) (*JWKS, error) {
+	s := &JWKS{fetch: fetch, refresh: refresh}
+
+	if err := s.load(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Key implements KeySource.
+func (s *JWKS) Key(ctx context.Context, kid, _ string) (interface{}, error) {
+	s.mu.RLock()
+	stale := s.refresh > 0 && time.Since(s.loadedAt) > s.refresh
+	s.mu.RUnlock()
+
+	if stale {
+		s.reload(ctx)
+	}
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	if s.reload(ctx) {
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, errors.Errorf("unknown key id %q", kid)
+}
+
+func (s *JWKS) lookup(kid string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// reload reloads the keys, unless the last attempt was less than minJWKSRefreshInterval ago.
+// It reports whether the keys were reloaded.
+func (s *JWKS) reload(ctx context.Context) bool {
+	s.mu.RLock()
+	recent := time.Since(s.lastAttempt) < minJWKSRefreshInterval
+	s.mu.RUnlock()
+
+	if recent {
+		return false
+	}
+
+	if err := s.load(ctx); err != nil {
+		log.Errorf("Failed to reload JWKS: %v", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *JWKS) load(ctx context.Context) error {
+	s.mu.Lock()
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	data, err := s.fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load JWKS")
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "failed to parse JWKS")
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		// Keys of unsupported types are skipped, so they can be added to the set without breaking clients.
+		key, err := k.key()
+		if err != nil {
+			log.Debugf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable key")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, errors.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
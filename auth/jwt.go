@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// KeySource returns the keys verifying the signatures of JWTs.
+type KeySource interface {
+	// Key returns the key for the given key id ("kid" header, possibly empty) and algorithm: a []byte
+	// for HS256, an *rsa.PublicKey for RS256 or an *ecdsa.PublicKey for ES256.
+	Key(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+// KeySourceFunc is an adapter to use functions as key sources.
+type KeySourceFunc func(ctx context.Context, kid, alg string) (interface{}, error)
+
+// Key calls f(ctx, kid, alg).
+func (f KeySourceFunc) Key(ctx context.Context, kid, alg string) (interface{}, error) {
+	return f(ctx, kid, alg)
+}
+
+// HMACSecret returns a key source verifying HS256 tokens with the given secret.
+func HMACSecret(secret []byte) KeySource {
+	return KeySourceFunc(func(_ context.Context, _, alg string) (interface{}, error) {
+		if alg != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.Errorf("unexpected algorithm %s", alg)
+		}
+
+		return secret, nil
+	})
+}
+
+// PublicKey returns a key source verifying RS256 or ES256 tokens with the given *rsa.PublicKey or
+// *ecdsa.PublicKey, whatever their key id.
+func PublicKey(key interface{}) KeySource {
+	return KeySourceFunc(func(context.Context, string, string) (interface{}, error) {
+		return key, nil
+	})
+}
+
+type jwtAuthenticator struct {
+	keys       KeySource
+	parser     *jwt.Parser
+	scopeClaim string
+	rolesClaim string
+}
+
+// JWTOption configures the JWT authenticator.
+type JWTOption func(*jwtOptions)
+
+type jwtOptions struct {
+	algorithms []string
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	scopeClaim string
+	rolesClaim string
+}
+
+// WithAlgorithms sets the accepted signing algorithms. Defaults to HS256, RS256 and ES256.
+func WithAlgorithms(algorithms ...string) JWTOption {
+	return func(o *jwtOptions) {
+		o.algorithms = algorithms
+	}
+}
+
+// WithIssuer requires the "iss" claim to be equal to issuer.
+func WithIssuer(issuer string) JWTOption {
+	return func(o *jwtOptions) {
+		o.issuer = issuer
+	}
+}
+
+// WithAudience requires the "aud" claim to contain audience.
+func WithAudience(audience string) JWTOption {
+	return func(o *jwtOptions) {
+		o.audience = audience
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when validating the "exp", "nbf" and "iat" claims.
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(o *jwtOptions) {
+		o.leeway = leeway
+	}
+}
+
+// WithScopeClaim sets the claim holding the scopes, either a space separated string or an array of
+// strings. Defaults to "scope".
+func WithScopeClaim(claim string) JWTOption {
+	return func(o *jwtOptions) {
+		o.scopeClaim = claim
+	}
+}
+
+// WithRolesClaim sets the claim holding the roles, either a space separated string or an array of
+// strings. Defaults to "roles".
+func WithRolesClaim(claim string) JWTOption {
+	return func(o *jwtOptions) {
+		o.rolesClaim = claim
+	}
+}
+
+// NewJWTAuthenticator returns an authenticator verifying the Bearer JWT of the Authorization header with
+// the keys of the given source. Tokens must have an expiration time. The subject of the principal is the
+// "sub" claim.
+func NewJWTAuthenticator(keys KeySource, opts ...JWTOption) Authenticator {
+	o := jwtOptions{
+		algorithms: []string{"HS256", "RS256", "ES256"},
+		scopeClaim: "scope",
+		rolesClaim: "roles",
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(o.algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(o.leeway),
+	}
+	if o.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(o.issuer))
+	}
+	if o.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(o.audience))
+	}
+
+	return &jwtAuthenticator{
+		keys:       keys,
+		parser:     jwt.NewParser(parserOpts...),
+		scopeClaim: o.scopeClaim,
+		rolesClaim: o.rolesClaim,
+	}
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	raw, err := authorization(creds, "Bearer")
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = a.parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(ctx, kid, t.Method.Alg())
+	})
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, errors.Wrap(ErrInvalidToken, "token has no subject")
+	}
+
+	return &Principal{
+		Subject: sub,
+		Method:  "jwt",
+		Scopes:  stringsClaim(claims[a.scopeClaim]),
+		Roles:   stringsClaim(claims[a.rolesClaim]),
+		Claims:  claims,
+	}, nil
+}
+
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
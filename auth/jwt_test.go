@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type headers map[string]string
+
+func (h headers) Get(name string) string {
+	return h[name]
+}
+
+func bearer(token string) headers {
+	return headers{"Authorization": "Bearer " + token}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"iss":   "starbox",
+		"aud":   "api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "projects:read projects:write",
+		"roles": []string{"admin"},
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   encode(key.N.Bytes()),
+		"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   encode(key.X.Bytes()),
+		"y":   encode(key.Y.Bytes()),
+	}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func TestJWTAuthenticatorHS256(t *testing.T) {
+	secret := []byte("secret")
+	a := NewJWTAuthenticator(HMACSecret(secret), WithIssuer("starbox"), WithAudience("api"))
+
+	p, err := a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "", secret, validClaims())))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+	assert.Equal(t, "jwt", p.Method)
+	assert.Equal(t, []string{"projects:read", "projects:write"}, p.Scopes)
+	assert.Equal(t, []string{"admin"}, p.Roles)
+	assert.True(t, p.HasScopes("projects:read"))
+	assert.False(t, p.HasRoles("admin", "owner"))
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "", secret, claims)))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	claims = validClaims()
+	delete(claims, "exp")
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "", secret, claims)))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	claims = validClaims()
+	claims["iss"] = "other"
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "", secret, claims)))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "", []byte("other"), validClaims())))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	_, err = a.Authenticate(context.Background(), headers{})
+	assert.True(t, errors.Is(err, ErrMissingCredentials))
+
+	_, err = a.Authenticate(context.Background(), headers{"Authorization": "Token abc"})
+	assert.True(t, errors.Is(err, ErrMalformedCredentials))
+}
+
+func TestJWTAuthenticatorJWKSURL(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var (
+		mu   sync.Mutex
+		keys = jwksJSON(t, rsaJWK("key-1", &key1.PublicKey))
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(keys)
+	}))
+	defer server.Close()
+
+	jwks, err := NewJWKSFromURL(server.URL, time.Hour)
+	require.NoError(t, err)
+
+	a := NewJWTAuthenticator(jwks)
+
+	p, err := a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodRS256, "key-1", key1, validClaims())))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+
+	// An HS256 token signed with the public key must not be accepted.
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodHS256, "key-1", key1.PublicKey.N.Bytes(), validClaims())))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	// Rotated keys are fetched when a token is signed with an unknown key id.
+	mu.Lock()
+	keys = jwksJSON(t, rsaJWK("key-1", &key1.PublicKey), rsaJWK("key-2", &key2.PublicKey))
+	mu.Unlock()
+	jwks.lastAttempt = time.Time{}
+
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodRS256, "key-2", key2, validClaims())))
+	require.NoError(t, err)
+
+	_, err = a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodRS256, "key-3", key2, validClaims())))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+}
+
+func TestJWTAuthenticatorJWKSFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksJSON(t, ecJWK("ec", &key.PublicKey)), 0o600))
+
+	jwks, err := NewJWKSFromFile(path, 0)
+	require.NoError(t, err)
+
+	a := NewJWTAuthenticator(jwks)
+
+	// Tokens without key id are verified with the only key of the set.
+	p, err := a.Authenticate(context.Background(), bearer(sign(t, jwt.SigningMethodES256, "", key, validClaims())))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+
+	_, err = NewJWKSFromFile(filepath.Join(t.TempDir(), "missing.json"), 0)
+	assert.Error(t, err)
+}
+
+func TestParseJWKSSkipsUnsupportedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	okp := map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": encode([]byte("key"))}
+	k256 := ecJWK("k256", &key.PublicKey)
+	k256["crv"] = "secp256k1"
+
+	keys, err := parseJWKS(jwksJSON(t, okp, k256, ecJWK("ec", &key.PublicKey)))
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Contains(t, keys, "ec")
+
+	_, err = parseJWKS(jwksJSON(t, okp, k256))
+	assert.Error(t, err)
+}
@@ -0,0 +1,375 @@
+// Package breaker implements circuit breakers, used by scrpc.Dial and the HTTP transports to stop
+// calling downstream services that keep failing and give them time to recover.
+//
+// A breaker is closed while calls succeed. It opens when its trip policy reports too many failures, and
+// then fails the calls immediately with ErrOpen. After the cool-down, it becomes half-open and lets a few
+// probe calls through: it closes if they all succeed and opens again as soon as one fails.
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// ErrOpen is returned instead of calling the downstream service when the circuit is open, or when it is
+// half-open and all the probes are in flight.
+var ErrOpen = errors.Unavailable("circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed lets all the calls through.
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of probe calls through.
+	StateHalfOpen
+	// StateOpen fails all the calls.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Counts holds the numbers of calls made while the breaker is closed, since the last state change or
+// the start of the current interval.
+type Counts struct {
+	Requests             int
+	Successes            int
+	Failures             int
+	ConsecutiveSuccesses int
+	ConsecutiveFailures  int
+}
+
+func (c *Counts) success() {
+	c.Requests++
+	c.Successes++
+	c.ConsecutiveSuccesses++
+	c.ConsecutiveFailures = 0
+}
+
+func (c *Counts) failure() {
+	c.Requests++
+	c.Failures++
+	c.ConsecutiveFailures++
+	c.ConsecutiveSuccesses = 0
+}
+
+// TripPolicy reports whether the breaker should open, given the counts after a failed call.
+type TripPolicy func(Counts) bool
+
+// ConsecutiveFailures opens the breaker after n consecutive failures.
+func ConsecutiveFailures(n int) TripPolicy {
+	return func(c Counts) bool {
+		return c.ConsecutiveFailures >= n
+	}
+}
+
+// FailureRatio opens the breaker when at least the given ratio of the calls failed, once minRequests
+// calls have been made in the current interval.
+func FailureRatio(ratio float64, minRequests int) TripPolicy {
+	return func(c Counts) bool {
+		return c.Requests >= minRequests && float64(c.Failures)/float64(c.Requests) >= ratio
+	}
+}
+
+type options struct {
+	trip     TripPolicy
+	coolDown time.Duration
+	probes   int
+	interval time.Duration
+	metrics  *breakerMetrics
+}
+
+// Option represents a functional option for New and NewGroup.
+type Option func(*options)
+
+// WithTripPolicy sets the policy opening the breaker. Defaults to ConsecutiveFailures(5).
+func WithTripPolicy(p TripPolicy) Option {
+	return func(o *options) {
+		o.trip = p
+	}
+}
+
+// WithCoolDown sets how long the breaker stays open before letting probes through. Defaults to 30 seconds.
+func WithCoolDown(d time.Duration) Option {
+	return func(o *options) {
+		o.coolDown = d
+	}
+}
+
+// WithProbes sets the number of successful probes needed to close a half-open breaker, which is also
+// the number of calls let through concurrently while half-open. Defaults to 1.
+func WithProbes(n int) Option {
+	return func(o *options) {
+		o.probes = n
+	}
+}
+
+// WithInterval sets the period after which the counts of a closed breaker are cleared, so that the trip
+// policy only considers recent calls. Defaults to 1 minute; 0 never clears them.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+func defaultOptions() options {
+	return options{
+		trip:     ConsecutiveFailures(5),
+		coolDown: 30 * time.Second,
+		probes:   1,
+		interval: time.Minute,
+		metrics:  defaultBreakerMetrics,
+	}
+}
+
+// Breaker is a circuit breaker. It is safe for concurrent use.
+type Breaker struct {
+	name string
+	opts options
+
+	mu       sync.Mutex
+	state    State
+	counts   Counts
+	inFlight int
+	// expiry is the end of the current interval when closed, or of the cool-down when open.
+	expiry time.Time
+	// generation changes with the state and the intervals, so that calls started before are ignored.
+	generation uint64
+
+	now func() time.Time
+}
+
+// New returns a closed circuit breaker. The name identifies it in logs and metrics.
+func New(name string, opts ...Option) *Breaker {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return newBreaker(name, o)
+}
+
+func newBreaker(name string, o options) *Breaker {
+	if o.probes < 1 {
+		o.probes = 1
+	}
+
+	b := &Breaker{
+		name: name,
+		opts: o,
+		now:  time.Now,
+	}
+	b.toState(StateClosed, b.now())
+	b.opts.metrics.setState(name, StateClosed)
+
+	return b
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh(b.now())
+	return b.state
+}
+
+// Allow reports whether a call can be made. If it can, done must be called with the outcome of the
+// call. Otherwise, it returns ErrOpen.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	done, _, err = b.AllowWithRelease()
+	return done, err
+}
+
+// AllowWithRelease is Allow that also returns release, to be called instead of done when the outcome of
+// the call says nothing about the health of the service, e.g. when the caller canceled it. It frees the
+// slot of the call without recording an outcome.
+func (b *Breaker) AllowWithRelease() (done func(success bool), release func(), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.refresh(now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, nil, ErrOpen
+	case StateHalfOpen:
+		if b.inFlight >= b.opts.probes-b.counts.ConsecutiveSuccesses {
+			return nil, nil, ErrOpen
+		}
+	}
+
+	b.inFlight++
+	generation := b.generation
+
+	var once sync.Once
+	done = func(success bool) {
+		once.Do(func() { b.done(generation, success) })
+	}
+	release = func() {
+		once.Do(func() { b.release(generation) })
+	}
+	return done, release, nil
+}
+
+// Execute calls fn if the breaker allows it, and records its outcome: any error is a failure.
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	done(err == nil)
+	return err
+}
+
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.refresh(now)
+
+	if generation != b.generation {
+		return
+	}
+	b.inFlight--
+
+	if success {
+		b.counts.success()
+		if b.state == StateHalfOpen && b.counts.ConsecutiveSuccesses >= b.opts.probes {
+			b.setState(StateClosed, now)
+		}
+		return
+	}
+
+	b.counts.failure()
+	switch b.state {
+	case StateClosed:
+		if b.opts.trip(b.counts) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	}
+}
+
+func (b *Breaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation {
+		b.inFlight--
+	}
+}
+
+// refresh moves an open breaker to half-open after the cool-down, and starts a new interval for a
+// closed breaker.
+func (b *Breaker) refresh(now time.Time) {
+	switch b.state {
+	case StateClosed:
+		if !b.expiry.IsZero() && !now.Before(b.expiry) {
+			b.toState(StateClosed, now)
+		}
+	case StateOpen:
+		if !now.Before(b.expiry) {
+			b.setState(StateHalfOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	prev := b.state
+	b.toState(state, now)
+
+	logger := log.Logger().With("circuit_breaker", b.name)
+	if state == StateOpen {
+		logger.Warningf("circuit breaker %s changed from %s to %s", b.name, prev, state)
+		b.opts.metrics.opened(b.name)
+	} else {
+		logger.Infof("circuit breaker %s changed from %s to %s", b.name, prev, state)
+	}
+	b.opts.metrics.setState(b.name, state)
+}
+
+// toState resets the counts and starts a new generation in the given state.
+func (b *Breaker) toState(state State, now time.Time) {
+	b.state = state
+	b.counts = Counts{}
+	b.inFlight = 0
+	b.generation++
+
+	b.expiry = time.Time{}
+	switch state {
+	case StateClosed:
+		if b.opts.interval > 0 {
+			b.expiry = now.Add(b.opts.interval)
+		}
+	case StateOpen:
+		b.expiry = now.Add(b.opts.coolDown)
+	}
+}
+
+// Group holds the breakers of a set of keys, e.g. one per host or per gRPC method, created on first use
+// with the same options.
+type Group struct {
+	name string
+	opts options
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup returns an empty group. The breakers are named after the group name and their key.
+func NewGroup(name string, opts ...Option) *Group {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Group{
+		name:     name,
+		opts:     o,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get returns the breaker of the key, creating it if needed.
+func (g *Group) Get(key string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[key]
+	if !ok {
+		name := key
+		if g.name != "" {
+			name = g.name + ":" + key
+		}
+
+		b = newBreaker(name, g.opts)
+		g.breakers[key] = b
+	}
+
+	return b
+}
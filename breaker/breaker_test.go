@@ -0,0 +1,214 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/errors"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestBreaker(opts ...Option) (*Breaker, *clock, *breakerMetrics) {
+	metrics := newBreakerMetrics()
+	b := New("test", append([]Option{func(o *options) { o.metrics = metrics }}, opts...)...)
+
+	c := &clock{t: time.Unix(1700000000, 0)}
+	b.now = c.now
+	b.toState(StateClosed, c.t)
+
+	return b, c, metrics
+}
+
+func call(b *Breaker, success bool) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	done(success)
+	return nil
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	b, c, metrics := newTestBreaker(WithTripPolicy(ConsecutiveFailures(3)), WithCoolDown(10*time.Second))
+
+	require.NoError(t, call(b, false))
+	require.NoError(t, call(b, false))
+	require.NoError(t, call(b, true))
+	require.NoError(t, call(b, false))
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.state.WithLabelValues("test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.opens.WithLabelValues("test")))
+
+	err := call(b, true)
+	assert.Equal(t, ErrOpen, err)
+	assert.Equal(t, errors.KindUnavailable, errors.KindOf(err))
+
+	c.t = c.t.Add(10 * time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.state.WithLabelValues("test")))
+}
+
+func TestFailureRatio(t *testing.T) {
+	b, c, _ := newTestBreaker(WithTripPolicy(FailureRatio(0.5, 4)), WithInterval(time.Minute))
+
+	require.NoError(t, call(b, false))
+	require.NoError(t, call(b, true))
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateClosed, b.State())
+
+	// The counts are cleared at the end of the interval.
+	c.t = c.t.Add(time.Minute)
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, call(b, true))
+	require.NoError(t, call(b, true))
+	require.NoError(t, call(b, true))
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, call(b, false))
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestHalfOpen(t *testing.T) {
+	b, c, metrics := newTestBreaker(WithTripPolicy(ConsecutiveFailures(1)), WithCoolDown(time.Second), WithProbes(2))
+
+	require.NoError(t, call(b, false))
+	c.t = c.t.Add(time.Second)
+
+	// Only two probes are allowed at once.
+	done1, err := b.Allow()
+	require.NoError(t, err)
+	done2, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	assert.Equal(t, ErrOpen, err)
+
+	done1(true)
+	assert.Equal(t, StateHalfOpen, b.State())
+	done2(true)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.state.WithLabelValues("test")))
+
+	// A failed probe opens the breaker again, and late outcomes of earlier calls are ignored.
+	require.NoError(t, call(b, false))
+	c.t = c.t.Add(time.Second)
+
+	done1, err = b.Allow()
+	require.NoError(t, err)
+	done2, err = b.Allow()
+	require.NoError(t, err)
+
+	done1(false)
+	assert.Equal(t, StateOpen, b.State())
+	done2(true)
+	done2(true)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.opens.WithLabelValues("test")))
+}
+
+func TestExecute(t *testing.T) {
+	b, _, _ := newTestBreaker(WithTripPolicy(ConsecutiveFailures(1)))
+
+	failure := errors.New("failure")
+	assert.Equal(t, failure, b.Execute(func() error { return failure }))
+	assert.Equal(t, ErrOpen, b.Execute(func() error {
+		t.Fatal("unexpected call")
+		return nil
+	}))
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup("billing", WithTripPolicy(ConsecutiveFailures(1)))
+
+	assert.Same(t, g.Get("a"), g.Get("a"))
+	assert.Equal(t, "billing:a", g.Get("a").Name())
+
+	require.NoError(t, call(g.Get("a"), false))
+	assert.Equal(t, StateOpen, g.Get("a").State())
+	assert.Equal(t, StateClosed, g.Get("b").State())
+}
+
+func TestTransport(t *testing.T) {
+	status := http.StatusInternalServerError
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := http.Client{
+		Transport: &Transport{Group: NewGroup("", WithTripPolicy(ConsecutiveFailures(2)))},
+	}
+
+	status = http.StatusNotFound
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	status = http.StatusInternalServerError
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	_, err := client.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrOpen))
+	assert.Equal(t, 5, calls)
+}
+
+func TestTransportCanceledProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	g := NewGroup("", WithTripPolicy(ConsecutiveFailures(1)), WithCoolDown(10*time.Millisecond))
+	client := http.Client{Transport: &Transport{Group: g}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	b := g.Get(server.Listener.Addr().String())
+	assert.Equal(t, StateOpen, b.State())
+	time.Sleep(10 * time.Millisecond)
+
+	// A probe canceled by the caller neither closes the breaker nor keeps its slot.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	require.Error(t, err)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, StateOpen, b.State())
+}
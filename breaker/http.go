@@ -0,0 +1,56 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/starclusterteam/go-starbox/errors"
+)
+
+// Transport is an http.RoundTripper failing fast with ErrOpen while the breaker of the request is open.
+// Transport errors, except for canceled requests, and 5xx responses are failures. It can be wrapped by web.TracedTransport, e.g.
+//
+//	web.TracedTransport(tracer, &breaker.Transport{Group: breaker.NewGroup("billing")}, "billing")
+type Transport struct {
+	// Group holds the breakers of the transport.
+	Group *Group
+	// Key returns the breaker key of a request. Defaults to the host of the request.
+	Key func(*http.Request) string
+	// Base is the underlying transport. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := r.URL.Host
+	if t.Key != nil {
+		key = t.Key(r)
+	}
+
+	done, release, err := t.Group.Get(key).AllowWithRelease()
+	if err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		// Requests canceled by the caller say nothing about the health of the service.
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			release()
+		} else {
+			done(false)
+		}
+		return nil, err
+	}
+
+	done(resp.StatusCode < 500)
+	return resp, nil
+}
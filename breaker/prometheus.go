@@ -0,0 +1,54 @@
+package breaker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+)
+
+var defaultBreakerMetrics = newBreakerMetrics()
+
+func init() {
+	if config.Bool(envvar.PrometheusEnabled, false) {
+		defaultBreakerMetrics.mustRegister()
+	}
+}
+
+type breakerMetrics struct {
+	state *prometheus.GaugeVec
+	opens *prometheus.CounterVec
+}
+
+func newBreakerMetrics() *breakerMetrics {
+	var m breakerMetrics
+	m.state = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "The state of the circuit breakers: 0 when closed, 1 when half-open and 2 when open.",
+		},
+		[]string{"name"},
+	)
+
+	m.opens = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_opens_total",
+			Help: "The number of times the circuit breakers opened.",
+		},
+		[]string{"name"},
+	)
+
+	return &m
+}
+
+func (m *breakerMetrics) mustRegister() {
+	prometheus.MustRegister(m.state, m.opens)
+}
+
+func (m *breakerMetrics) setState(name string, state State) {
+	m.state.WithLabelValues(name).Set(float64(state))
+}
+
+func (m *breakerMetrics) opened(name string) {
+	m.opens.WithLabelValues(name).Inc()
+}
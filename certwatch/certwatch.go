@@ -0,0 +1,283 @@
+// Package certwatch keeps TLS certificates and CA pools loaded from files up to date, so that rotated
+// certificates, e.g. by cert-manager, are used without restarting the servers and clients.
+package certwatch
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// Watcher holds a key pair and a CA pool loaded from files, and reloads them when the files change.
+// Failed reloads are logged and counted, and the previous certificates are kept.
+type Watcher struct {
+	certFile string
+	keyFile  string
+	caFiles  []string
+	name     string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	content []byte
+
+	fw      *filewatch.Watcher
+	metrics *watcherMetrics
+}
+
+// New loads the key pair and the CA files and watches them for changes. The key pair is optional, e.g.
+// for clients only verifying servers, as are the CA files for servers not verifying clients.
+func New(certFile, keyFile string, caFiles []string) (*Watcher, error) {
+	name := certFile
+	if name == "" && len(caFiles) > 0 {
+		name = caFiles[0]
+	}
+
+	w := &Watcher{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFiles:  caFiles,
+		name:     name,
+		metrics:  defaultWatcherMetrics,
+	}
+
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, f := range w.files() {
+		dirs = append(dirs, filepath.Dir(f))
+	}
+
+	logger := log.Logger().With("certificate", w.name)
+	fw, err := filewatch.New(dirs, func() {
+		if err := w.reload(); err != nil {
+			logger.Errorf("failed to reload certificates, keeping the previous ones: %v", err)
+		}
+	}, func(err error) {
+		logger.Errorf("certificate watcher error: %v", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.fw = fw
+
+	return w, nil
+}
+
+// Certificate returns the current key pair, or nil if there is none.
+func (w *Watcher) Certificate() *tls.Certificate {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.cert
+}
+
+// CertPool returns the current CA pool, or nil if there are no CA files.
+func (w *Watcher) CertPool() *x509.CertPool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.pool
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := w.Certificate()
+	if cert == nil {
+		return nil, errors.New("no certificate configured")
+	}
+
+	return cert, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate.
+func (w *Watcher) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := w.Certificate(); cert != nil {
+		return cert, nil
+	}
+
+	// No certificate is sent.
+	return &tls.Certificate{}, nil
+}
+
+// ServerTLSConfig returns a server configuration presenting the current certificate. If there are CA
+// files, client certificates are requested according to clientAuth. They are verified against the current
+// pool with VerifyClientCertIfGiven and RequireAndVerifyClientCert only, by VerifyConnection: the verified
+// chains of the connection state are empty. With RequestClientCert and RequireAnyClientCert, the
+// certificates aren't verified and must not be trusted.
+func (w *Watcher) ServerTLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	config := &tls.Config{
+		GetCertificate: w.GetCertificate,
+	}
+
+	if len(w.caFiles) == 0 {
+		return config
+	}
+
+	// The default verification, which uses a fixed pool, is replaced by VerifyConnection.
+	switch clientAuth {
+	case tls.VerifyClientCertIfGiven:
+		config.ClientAuth = tls.RequestClientCert
+	case tls.RequireAndVerifyClientCert:
+		config.ClientAuth = tls.RequireAnyClientCert
+	default:
+		config.ClientAuth = clientAuth
+	}
+
+	if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				// Only possible if the certificate is optional.
+				return nil
+			}
+
+			return w.verify(cs.PeerCertificates, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	return config
+}
+
+// ClientTLSConfig returns a client configuration presenting the current certificate, if any. If there
+// are CA files, server certificates are verified against the current pool instead of the system roots.
+func (w *Watcher) ClientTLSConfig(serverName string) *tls.Config {
+	config := &tls.Config{
+		ServerName:           serverName,
+		GetClientCertificate: w.GetClientCertificate,
+	}
+
+	if len(w.caFiles) > 0 {
+		// The default verification, which uses a fixed pool, is replaced by VerifyConnection.
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no server certificate")
+			}
+
+			// The server name of the state is empty for IP addresses, which are sent without SNI.
+			name := serverName
+			if name == "" {
+				name = cs.ServerName
+			}
+
+			return w.verify(cs.PeerCertificates, name, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	return config
+}
+
+func (w *Watcher) verify(certs []*x509.Certificate, dnsName string, usage x509.ExtKeyUsage) error {
+	opts := x509.VerifyOptions{
+		Roots:         w.CertPool(),
+		DNSName:       dnsName,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// Close stops watching the files.
+func (w *Watcher) Close() error {
+	if w.fw == nil {
+		return nil
+	}
+
+	return w.fw.Close()
+}
+
+func (w *Watcher) files() []string {
+	var files []string
+	if w.certFile != "" {
+		files = append(files, w.certFile, w.keyFile)
+	}
+
+	return append(files, w.caFiles...)
+}
+
+// reload loads the files if their content changed.
+func (w *Watcher) reload() error {
+	var content [][]byte
+	for _, f := range w.files() {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			w.metrics.reloads.Reloaded(false, w.name)
+			return errors.Wrapf(err, "failed to read %s", f)
+		}
+
+		content = append(content, b)
+	}
+
+	joined := bytes.Join(content, []byte{0})
+
+	w.mu.RLock()
+	unchanged := w.content != nil && bytes.Equal(joined, w.content)
+	w.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if w.certFile != "" {
+		c, err := tls.X509KeyPair(content[0], content[1])
+		if err != nil {
+			w.metrics.reloads.Reloaded(false, w.name)
+			return errors.Wrap(err, "failed to load key pair")
+		}
+
+		if c.Leaf == nil {
+			c.Leaf, err = x509.ParseCertificate(c.Certificate[0])
+			if err != nil {
+				w.metrics.reloads.Reloaded(false, w.name)
+				return errors.Wrap(err, "failed to parse certificate")
+			}
+		}
+
+		cert = &c
+		content = content[2:]
+	}
+
+	var pool *x509.CertPool
+	if len(w.caFiles) > 0 {
+		pool = x509.NewCertPool()
+		for i, ca := range content {
+			if ok := pool.AppendCertsFromPEM(ca); !ok {
+				w.metrics.reloads.Reloaded(false, w.name)
+				return errors.Errorf("failed to append ca certs from %s", w.caFiles[i])
+			}
+		}
+	}
+
+	w.mu.Lock()
+	initial := w.content == nil
+	w.cert = cert
+	w.pool = pool
+	w.content = joined
+	w.mu.Unlock()
+
+	if cert != nil {
+		w.metrics.setExpiry(w.name, cert.Leaf.NotAfter)
+	}
+
+	if !initial {
+		w.metrics.reloads.Reloaded(true, w.name)
+		log.Logger().With("certificate", w.name).Infof("reloaded certificates")
+	}
+
+	return nil
+}
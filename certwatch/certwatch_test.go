@@ -0,0 +1,144 @@
+package certwatch
+
+import (
+	"crypto/tls"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	starboxtestutil "github.com/starclusterteam/go-starbox/testutil"
+)
+
+type certFiles struct {
+	cert, key, ca string
+}
+
+func writeCerts(t *testing.T, dir, commonName string) (*starboxtestutil.CertificateAuthority, certFiles) {
+	ca, err := starboxtestutil.NewCertificateAuthority(commonName + " CA")
+	require.NoError(t, err)
+
+	cert, key, err := ca.Issue(commonName, "localhost", "127.0.0.1")
+	require.NoError(t, err)
+
+	files := certFiles{
+		cert: filepath.Join(dir, "tls.crt"),
+		key:  filepath.Join(dir, "tls.key"),
+		ca:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(files.cert, cert, 0600))
+	require.NoError(t, os.WriteFile(files.key, key, 0600))
+	require.NoError(t, os.WriteFile(files.ca, ca.CertPEM, 0600))
+
+	return ca, files
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	_, files := writeCerts(t, dir, "server-1")
+
+	w, err := New(files.cert, files.key, []string{files.ca})
+	require.NoError(t, err)
+	defer w.Close()
+
+	assert.Equal(t, "server-1", w.Certificate().Leaf.Subject.CommonName)
+	assert.NotNil(t, w.CertPool())
+
+	writeCerts(t, dir, "server-2")
+
+	assert.Eventually(t, func() bool {
+		return w.Certificate().Leaf.Subject.CommonName == "server-2"
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 1.0, testutil.ToFloat64(w.metrics.reloads.WithLabelValues(files.cert, "success")))
+
+	// An invalid key pair is not loaded.
+	require.NoError(t, os.WriteFile(files.key, []byte("invalid"), 0600))
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(w.metrics.reloads.WithLabelValues(files.cert, "failure")) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "server-2", w.Certificate().Leaf.Subject.CommonName)
+}
+
+func TestNewWithMissingFiles(t *testing.T) {
+	_, err := New("missing.crt", "missing.key", nil)
+	assert.Error(t, err)
+}
+
+func TestHandshake(t *testing.T) {
+	serverDir, clientDir := t.TempDir(), t.TempDir()
+	_, serverFiles := writeCerts(t, serverDir, "server")
+	clientCA, clientFiles := writeCerts(t, clientDir, "client")
+
+	// The server trusts the client CA and the client trusts the server CA.
+	server, err := New(serverFiles.cert, serverFiles.key, []string{clientFiles.ca})
+	require.NoError(t, err)
+	defer server.Close()
+
+	client, err := New(clientFiles.cert, clientFiles.key, []string{serverFiles.ca})
+	require.NoError(t, err)
+	defer client.Close()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server.ServerTLSConfig(tls.RequireAndVerifyClientCert))
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("ok"))
+			}()
+		}
+	}()
+
+	dial := func(config *tls.Config) error {
+		conn, err := tls.Dial("tcp", l.Addr().String(), config)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		// With TLS 1.3, the client certificate is verified after the client handshake.
+		_, err = io.ReadAll(conn)
+		return err
+	}
+
+	assert.NoError(t, dial(client.ClientTLSConfig("localhost")))
+	assert.Error(t, dial(client.ClientTLSConfig("example.com")))
+
+	// IP addresses are checked against the IP SANs of the certificate.
+	assert.NoError(t, dial(client.ClientTLSConfig("127.0.0.1")))
+	assert.Error(t, dial(client.ClientTLSConfig("10.0.0.5")))
+
+	// A client certificate from another CA is rejected.
+	other, err := starboxtestutil.NewCertificateAuthority("other CA")
+	require.NoError(t, err)
+
+	cert, key, err := other.Issue("client")
+	require.NoError(t, err)
+	pair, err := tls.X509KeyPair(cert, key)
+	require.NoError(t, err)
+
+	config := client.ClientTLSConfig("localhost")
+	config.GetClientCertificate = nil
+	config.Certificates = []tls.Certificate{pair}
+	assert.Error(t, dial(config))
+
+	// Once the server trusts the other CA, its certificates are accepted.
+	require.NoError(t, os.WriteFile(clientFiles.ca, append(clientCA.CertPEM, other.CertPEM...), 0600))
+
+	assert.Eventually(t, func() bool {
+		return dial(config) == nil
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,51 @@
+package certwatch
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+)
+
+var defaultWatcherMetrics = newWatcherMetrics()
+
+func init() {
+	if config.Bool(envvar.PrometheusEnabled, false) {
+		defaultWatcherMetrics.mustRegister()
+	}
+}
+
+type watcherMetrics struct {
+	reloads filewatch.ReloadCounter
+	expiry  *prometheus.GaugeVec
+}
+
+func newWatcherMetrics() *watcherMetrics {
+	var m watcherMetrics
+	m.reloads = filewatch.NewReloadCounter(
+		"tls_certificate_reloads_total",
+		"The number of TLS certificate reloads.",
+		"certificate",
+	)
+
+	m.expiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tls_certificate_expiry_timestamp_seconds",
+			Help: "The expiry time of the TLS certificates in use, as a Unix timestamp.",
+		},
+		[]string{"certificate"},
+	)
+
+	return &m
+}
+
+func (m *watcherMetrics) mustRegister() {
+	prometheus.MustRegister(m.reloads, m.expiry)
+}
+
+func (m *watcherMetrics) setExpiry(name string, t time.Time) {
+	m.expiry.WithLabelValues(name).Set(float64(t.Unix()))
+}
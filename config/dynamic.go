@@ -0,0 +1,521 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	stdlog "log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+)
+
+const dynamicConfigPathEnv = "DYNAMIC_CONFIG_PATH"
+
+// adminSource is the name of the source of the values set through the admin endpoint.
+const adminSource = "admin"
+
+// DefaultStore is the store of the dynamic values created with NewValue and the Dynamic* functions. If
+// the DYNAMIC_CONFIG_PATH environment variable is set, it watches the file or directory it points to.
+var DefaultStore = NewStore()
+
+func init() {
+	if path := getEnv(dynamicConfigPathEnv); path != "" {
+		if _, err := DefaultStore.WatchFile(path); err != nil {
+			DefaultStore.reportError(errors.Wrapf(err, "failed to watch %s", path))
+		}
+	}
+}
+
+// Store holds configuration values which can change at runtime. The values come from, by decreasing
+// precedence: the admin endpoint, the watched files (the last one watched first) and the environment.
+// It is safe for concurrent use.
+type Store struct {
+	mu sync.RWMutex
+	// sources holds the values of the watched files and of the admin endpoint, by increasing precedence.
+	sources     []*storeSource
+	listeners   map[string][]func()
+	validators  map[string]func(string) error
+	values      map[string]storeValue
+	errorHandle func(error)
+}
+
+// storeValue is a Value created on the store, with the default and the parser it was created with.
+type storeValue struct {
+	value interface{}
+	def   interface{}
+	parse uintptr
+}
+
+type storeSource struct {
+	name   string
+	values map[string]string
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{
+		sources:    []*storeSource{{name: adminSource, values: map[string]string{}}},
+		listeners:  make(map[string][]func()),
+		validators: make(map[string]func(string) error),
+		values:     make(map[string]storeValue),
+	}
+}
+
+// SetErrorHandler sets the function called with the errors occurring after the values are created,
+// e.g. when a watched file can't be parsed or a new value is invalid. By default they are printed to
+// the standard error. The log package reports them as errors.
+func (s *Store) SetErrorHandler(fn func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errorHandle = fn
+}
+
+func (s *Store) reportError(err error) {
+	s.mu.RLock()
+	fn := s.errorHandle
+	s.mu.RUnlock()
+
+	if fn == nil {
+		stdlog.Printf("config: %v", err)
+		return
+	}
+
+	fn(err)
+}
+
+// Lookup returns the current value of a key.
+func (s *Store) Lookup(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, _, ok := s.lookup(key)
+	return value, ok
+}
+
+func (s *Store) lookup(key string) (value, source string, ok bool) {
+	for i := len(s.sources) - 1; i >= 0; i-- {
+		if v, ok := s.sources[i].values[key]; ok {
+			return v, s.sources[i].name, true
+		}
+	}
+
+	if v := getEnv(key); v != "" {
+		return v, "environment", true
+	}
+
+	return "", "", false
+}
+
+// Set overrides the value of a key, like the admin endpoint does.
+func (s *Store) Set(key, value string) {
+	s.update(adminSource, func(values map[string]string) {
+		values[key] = value
+	})
+}
+
+// Unset removes the value of a key set with Set or the admin endpoint.
+func (s *Store) Unset(key string) {
+	s.update(adminSource, func(values map[string]string) {
+		delete(values, key)
+	})
+}
+
+// update changes the values of a source and notifies the listeners of the keys whose value changed.
+func (s *Store) update(source string, fn func(map[string]string)) {
+	s.mu.Lock()
+
+	before := make(map[string]string, len(s.listeners))
+	for key := range s.listeners {
+		before[key], _, _ = s.lookup(key)
+	}
+
+	var src *storeSource
+	for _, ss := range s.sources {
+		if ss.name == source {
+			src = ss
+		}
+	}
+	if src == nil {
+		// Files are added below the admin source.
+		src = &storeSource{name: source, values: map[string]string{}}
+		last := len(s.sources) - 1
+		admin := s.sources[last]
+		s.sources = append(s.sources[:last], src, admin)
+	}
+	fn(src.values)
+
+	var notify []func()
+	for key, fns := range s.listeners {
+		if after, _, _ := s.lookup(key); after != before[key] {
+			notify = append(notify, fns...)
+		}
+	}
+
+	s.mu.Unlock()
+
+	for _, fn := range notify {
+		fn()
+	}
+}
+
+// WatchFile loads the values of a file and reloads them when it changes. The file can be a YAML or JSON
+// file (see WithFile), a .env file, or a directory holding a file per key, like a mounted ConfigMap.
+func (s *Store) WatchFile(path string) (io.Closer, error) {
+	values, err := readDynamicFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := "file:" + path
+	s.update(source, func(m map[string]string) {
+		for k, v := range values {
+			m[k] = v
+		}
+	})
+
+	// The parent directory of a file is watched, since mounted files are replaced through symlinks.
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	fw, err := filewatch.New([]string{dir}, func() {
+		s.reload(path, source)
+	}, func(err error) {
+		s.reportError(errors.Wrapf(err, "failed to watch %s", path))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fw, nil
+}
+
+// reload replaces the values of a watched file.
+func (s *Store) reload(path, source string) {
+	values, err := readDynamicFile(path)
+	if err != nil {
+		s.reportError(errors.Wrapf(err, "failed to reload %s, keeping the previous values", path))
+		return
+	}
+
+	s.update(source, func(m map[string]string) {
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range values {
+			m[k] = v
+		}
+	})
+}
+
+func readDynamicFile(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dynamic config")
+	}
+
+	if !info.IsDir() {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			return readFile(path)
+		default:
+			return readDotEnv(path)
+		}
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dynamic config directory")
+	}
+
+	values := make(map[string]string)
+	for _, e := range entries {
+		// Skips the ..data directories and symlinks of mounted ConfigMaps.
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(path, e.Name()))
+		if err != nil {
+			// Directories and broken symlinks.
+			continue
+		}
+
+		values[e.Name()] = strings.TrimRight(string(b), "\r\n")
+	}
+
+	return values, nil
+}
+
+// AdminHandler returns a handler showing and changing the dynamic values. GET returns the value and
+// source of the keys of the values created on the store, as a JSON object. PUT takes a JSON object of
+// the keys to change, a null value removing the change, and answers like GET. The changes are validated
+// by the values first. The handler must only be exposed to administrators.
+func (s *Store) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var changes map[string]*string
+			if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+				http.Error(w, "invalid JSON object: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := s.validate(changes); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			s.update(adminSource, func(values map[string]string) {
+				for k, v := range changes {
+					if v == nil {
+						delete(values, k)
+					} else {
+						values[k] = *v
+					}
+				}
+			})
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.describe())
+	})
+}
+
+func (s *Store) validate(changes map[string]*string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if validate := s.validators[k]; validate != nil && changes[k] != nil {
+			if err := validate(*changes[k]); err != nil {
+				return errors.Wrapf(err, "invalid value for %s", k)
+			}
+		}
+	}
+
+	return nil
+}
+
+type describedValue struct {
+	Value  string `json:"value"`
+	Source string `json:"source,omitempty"`
+}
+
+func (s *Store) describe() map[string]describedValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for k := range s.listeners {
+		keys[k] = true
+	}
+	for k := range s.sources[len(s.sources)-1].values {
+		keys[k] = true
+	}
+
+	out := make(map[string]describedValue, len(keys))
+	for k := range keys {
+		value, source, _ := s.lookup(k)
+		out[k] = describedValue{Value: value, Source: source}
+	}
+
+	return out
+}
+
+// Value is a typed configuration value which can change at runtime, see Store. It is safe for
+// concurrent use.
+type Value[T any] struct {
+	key   string
+	def   T
+	parse func(string) (T, error)
+	store *Store
+
+	mu          sync.RWMutex
+	current     T
+	subscribers map[int]func(old, new T)
+	nextID      int
+}
+
+// NewValue returns the value of the key in the store, parsed with parse, or def if the key is not set.
+// If the current value can't be parsed, the value is def and the error is returned. Later invalid
+// values are reported to the error handler of the store and ignored.
+//
+// A key has a single value per store: the value already created for the key is returned, unless it has
+// another default or parser, compared by their code, in which case it is returned with an error, or
+// another type, in which case only the error is returned.
+func NewValue[T any](s *Store, key string, def T, parse func(string) (T, error)) (*Value[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parser := reflect.ValueOf(parse).Pointer()
+	if existing, ok := s.values[key]; ok {
+		v, ok := existing.value.(*Value[T])
+		if !ok {
+			return nil, errors.Errorf("value for %s already created with type %T", key, existing.def)
+		}
+		if existing.parse != parser || !reflect.DeepEqual(existing.def, def) {
+			return v, errors.Errorf("value for %s already created with another default or parser, keeping it", key)
+		}
+		return v, nil
+	}
+
+	v := &Value[T]{
+		key:         key,
+		def:         def,
+		parse:       parse,
+		store:       s,
+		current:     def,
+		subscribers: make(map[int]func(old, new T)),
+	}
+
+	var err error
+	if raw, _, ok := s.lookup(key); ok {
+		var current T
+		if current, err = parse(raw); err == nil {
+			v.current = current
+		}
+	}
+
+	s.values[key] = storeValue{value: v, def: def, parse: parser}
+	s.listeners[key] = append(s.listeners[key], v.refresh)
+	s.validators[key] = func(s string) error {
+		_, err := parse(s)
+		return err
+	}
+
+	return v, errors.Wrapf(err, "invalid value for %s, using the default %v", key, def)
+}
+
+func (v *Value[T]) resolve() (T, error) {
+	s, ok := v.store.Lookup(v.key)
+	if !ok {
+		return v.def, nil
+	}
+
+	return v.parse(s)
+}
+
+// Key returns the key of the value.
+func (v *Value[T]) Key() string {
+	return v.key
+}
+
+// Get returns the current value.
+func (v *Value[T]) Get() T {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.current
+}
+
+// Subscribe calls fn with the previous and the new value each time the value changes, until the
+// returned function is called.
+func (v *Value[T]) Subscribe(fn func(old, new T)) (unsubscribe func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	id := v.nextID
+	v.nextID++
+	v.subscribers[id] = fn
+
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		delete(v.subscribers, id)
+	}
+}
+
+func (v *Value[T]) refresh() {
+	// The value is resolved under the lock, so that concurrent refreshes can't store a stale value.
+	v.mu.Lock()
+	value, err := v.resolve()
+	if err != nil {
+		v.mu.Unlock()
+		v.store.reportError(errors.Wrapf(err, "invalid value for %s, keeping the previous one", v.key))
+		return
+	}
+
+	old := v.current
+	if reflect.DeepEqual(old, value) {
+		v.mu.Unlock()
+		return
+	}
+	v.current = value
+
+	subscribers := make([]func(old, new T), 0, len(v.subscribers))
+	for _, fn := range v.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	v.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, value)
+	}
+}
+
+// DynamicString returns a dynamic string value from DefaultStore, see DynamicBool.
+func DynamicString(key, def string) *Value[string] {
+	return dynamic(key, def, func(s string) (string, error) { return s, nil })
+}
+
+// DynamicBool returns a dynamic boolean value from DefaultStore. An invalid value is reported to the
+// error handler of the store and def is used instead. The value already created for the key is returned,
+// see NewValue, and it panics if the value was created with another type.
+func DynamicBool(key string, def bool) *Value[bool] {
+	return dynamic(key, def, strconv.ParseBool)
+}
+
+// DynamicInt returns a dynamic integer value from DefaultStore, see DynamicBool.
+func DynamicInt(key string, def int) *Value[int] {
+	return dynamic(key, def, strconv.Atoi)
+}
+
+// DynamicFloat returns a dynamic float value from DefaultStore, see DynamicBool.
+func DynamicFloat(key string, def float64) *Value[float64] {
+	return dynamic(key, def, func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+// DynamicDuration returns a dynamic duration value from DefaultStore, see DynamicBool.
+func DynamicDuration(key string, def time.Duration) *Value[time.Duration] {
+	return dynamic(key, def, time.ParseDuration)
+}
+
+// dynamic returns the value of the key in DefaultStore, panicking if it was created with another type.
+func dynamic[T any](key string, def T, parse func(string) (T, error)) *Value[T] {
+	v, err := NewValue(DefaultStore, key, def, parse)
+	if v == nil {
+		panic(err)
+	}
+	if err != nil {
+		DefaultStore.reportError(err)
+	}
+
+	return v
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue(t *testing.T) {
+	t.Setenv("DYNAMIC_TEST_LIMIT", "10")
+
+	s := NewStore()
+	var mu sync.Mutex
+	var errs []error
+	s.SetErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+
+	v, err := NewValue(s, "DYNAMIC_TEST_LIMIT", 5, strconv.Atoi)
+	require.NoError(t, err)
+	assert.Equal(t, 10, v.Get())
+
+	var changes [][2]int
+	unsubscribe := v.Subscribe(func(old, new int) {
+		changes = append(changes, [2]int{old, new})
+	})
+
+	s.Set("DYNAMIC_TEST_LIMIT", "20")
+	assert.Equal(t, 20, v.Get())
+
+	// Invalid values are ignored.
+	s.Set("DYNAMIC_TEST_LIMIT", "many")
+	assert.Equal(t, 20, v.Get())
+	assert.Len(t, errs, 1)
+
+	// Back to the environment.
+	s.Unset("DYNAMIC_TEST_LIMIT")
+	assert.Equal(t, 10, v.Get())
+	assert.Equal(t, [][2]int{{10, 20}, {20, 10}}, changes)
+
+	unsubscribe()
+	s.Set("DYNAMIC_TEST_LIMIT", "30")
+	assert.Equal(t, 30, v.Get())
+	assert.Len(t, changes, 2)
+
+	t.Setenv("DYNAMIC_TEST_OTHER", "invalid")
+	other, err := NewValue(s, "DYNAMIC_TEST_OTHER", 5, strconv.Atoi)
+	assert.Error(t, err)
+	assert.Equal(t, 5, other.Get())
+}
+
+func TestValueConcurrentSet(t *testing.T) {
+	s := NewStore()
+
+	// Slow parses leave time for other refreshes to complete in between.
+	slow := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if n%2 == 1 {
+			time.Sleep(time.Millisecond)
+		}
+		return n, err
+	}
+	v, err := NewValue(s, "DYNAMIC_TEST_LIMIT", 0, slow)
+	require.NoError(t, err)
+
+	for round := 0; round < 50; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				s.Set("DYNAMIC_TEST_LIMIT", strconv.Itoa(n))
+			}(round*8 + i + 1)
+		}
+		wg.Wait()
+
+		// The value ends up with the last value set, whatever the order of the refreshes.
+		raw, _ := s.Lookup("DYNAMIC_TEST_LIMIT")
+		assert.Equal(t, raw, strconv.Itoa(v.Get()))
+	}
+}
+
+func TestValueCache(t *testing.T) {
+	s := NewStore()
+
+	v, err := NewValue(s, "DYNAMIC_TEST_LIMIT", 5, strconv.Atoi)
+	require.NoError(t, err)
+
+	// The value of a key is created once.
+	same, err := NewValue(s, "DYNAMIC_TEST_LIMIT", 5, strconv.Atoi)
+	require.NoError(t, err)
+	assert.Same(t, v, same)
+	assert.Len(t, s.listeners["DYNAMIC_TEST_LIMIT"], 1)
+
+	positive := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n <= 0 {
+			err = errors.New("not positive")
+		}
+		return n, err
+	}
+	other, err := NewValue(s, "DYNAMIC_TEST_LIMIT", 5, positive)
+	assert.Error(t, err)
+	assert.Same(t, v, other)
+
+	_, err = NewValue(s, "DYNAMIC_TEST_LIMIT", 10, strconv.Atoi)
+	assert.Error(t, err)
+
+	typed, err := NewValue(s, "DYNAMIC_TEST_LIMIT", "5", func(s string) (string, error) { return s, nil })
+	assert.Error(t, err)
+	assert.Nil(t, typed)
+
+	// The parser of the first value is kept.
+	s.Set("DYNAMIC_TEST_LIMIT", "-1")
+	assert.Equal(t, -1, v.Get())
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LOG_SEVERITY"), []byte("info\n"), 0600))
+
+	s := NewStore()
+	closer, err := s.WatchFile(dir)
+	require.NoError(t, err)
+	defer closer.Close()
+
+	level, err := NewValue(s, "LOG_SEVERITY", "warning", func(s string) (string, error) { return s, nil })
+	require.NoError(t, err)
+	assert.Equal(t, "info", level.Get())
+
+	changed := make(chan string, 1)
+	level.Subscribe(func(old, new string) { changed <- new })
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LOG_SEVERITY"), []byte("debug\n"), 0600))
+
+	select {
+	case v := <-changed:
+		assert.Equal(t, "debug", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change of the file was not detected")
+	}
+
+	// The admin source takes precedence over the files.
+	s.Set("LOG_SEVERITY", "error")
+	assert.Equal(t, "error", level.Get())
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("log:\n  severity: trace\n"), 0600))
+
+	closer, err = NewStore().WatchFile(file)
+	require.NoError(t, err)
+	closer.Close()
+
+	_, err = NewStore().WatchFile(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestAdminHandler(t *testing.T) {
+	s := NewStore()
+	_, err := NewValue(s, "DYNAMIC_TEST_RATE", 0.5, func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+	require.NoError(t, err)
+
+	handler := s.AdminHandler()
+	do := func(method, body string) (int, map[string]describedValue) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/config", strings.NewReader(body)))
+
+		var out map[string]describedValue
+		json.NewDecoder(w.Body).Decode(&out)
+		return w.Code, out
+	}
+
+	status, out := do("GET", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]describedValue{"DYNAMIC_TEST_RATE": {}}, out)
+
+	status, out = do("PUT", `{"DYNAMIC_TEST_RATE": "0.1", "FEATURE_X": "on"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, describedValue{Value: "0.1", Source: "admin"}, out["DYNAMIC_TEST_RATE"])
+	assert.Equal(t, describedValue{Value: "on", Source: "admin"}, out["FEATURE_X"])
+
+	status, _ = do("PUT", `{"DYNAMIC_TEST_RATE": "often"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, out = do("PUT", `{"FEATURE_X": null}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, out, "FEATURE_X")
+
+	status, _ = do("DELETE", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+}
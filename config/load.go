@@ -0,0 +1,403 @@
+package config
+
+import (
+	"context"
+	"encoding"
+	"flag"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRequired is the error of the required keys which are not set.
+var ErrRequired = errors.New("required key is not set")
+
+// KeyError is the error of a configuration key.
+type KeyError struct {
+	Key   string
+	Field string
+	Err   error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.Key, e.Field, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError lists all the missing and malformed keys found by Load.
+type LoadError struct {
+	Errors []*KeyError
+}
+
+func (e *LoadError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap allows to check the errors of the keys with errors.Is, e.g. errors.Is(err, ErrRequired).
+func (e *LoadError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+type loadOptions struct {
+	files     []string
+	dotEnv    []string
+	flags     *flag.FlagSet
+	arguments []string
+}
+
+// LoadOption is a functional option for Load.
+type LoadOption func(*loadOptions)
+
+// WithFile reads the keys from a YAML or JSON file, depending on its extension. Nested objects are
+// flattened by joining their keys with an underscore, so "web: {port: 8888}" sets WEB_PORT, and lists
+// are joined with commas.
+func WithFile(path string) LoadOption {
+	return func(o *loadOptions) {
+		o.files = append(o.files, path)
+	}
+}
+
+// WithDotEnv reads the keys from a .env file, holding KEY=value lines. A missing file is ignored.
+func WithDotEnv(path string) LoadOption {
+	return func(o *loadOptions) {
+		o.dotEnv = append(o.dotEnv, path)
+	}
+}
+
+// WithFlags defines a string flag on fs for each key, named after the key in lower case with dashes
+// (e.g. -web-port for WEB_PORT), and parses the arguments. Only the flags given override the other sources.
+func WithFlags(fs *flag.FlagSet, arguments []string) LoadOption {
+	return func(o *loadOptions) {
+		o.flags = fs
+		o.arguments = arguments
+	}
+}
+
+// Load sets the fields of the struct pointed by dst from the configuration keys given by their tags:
+//
+//	type Config struct {
+//		Port     int           `env:"WEB_PORT" default:"8888"`
+//		Database struct {
+//			URL     *url.URL      `env:"URL" required:"true"`
+//			Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//		} `prefix:"DATABASE_"`
+//	}
+//
+// The values are taken from, by increasing precedence: the defaults, the files given with WithFile, the
+// .env files given with WithDotEnv, the environment (honoring SetPrefix) and the flags given with WithFlags.
+// Nested structs are loaded with the keys prefixed by their prefix tag. Besides strings, booleans and
+// numbers, the fields can be durations, time.Time (RFC 3339), URLs, encoding.TextUnmarshaler, comma
+// separated slices and maps of comma separated key=value pairs. The Secret fields can hold references to
+// secrets, which are resolved with ResolveSecret.
+//
+// All the missing and malformed keys are reported in a *LoadError.
+func Load(dst interface{}, opts ...LoadOption) error {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("config: Load expects a pointer to a struct, got %T", dst)
+	}
+
+	var fields []field
+	collectFields(v.Elem(), "", "", &fields)
+
+	var sources []map[string]string
+	for _, path := range o.files {
+		values, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, values)
+	}
+
+	for _, path := range o.dotEnv {
+		values, err := readDotEnv(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, values)
+	}
+
+	sources = append(sources, environment(fields))
+
+	if o.flags != nil {
+		values, err := parseFlags(o.flags, o.arguments, fields)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, values)
+	}
+
+	loadErr := &LoadError{}
+	for _, f := range fields {
+		value, ok := f.defaultValue, f.hasDefault
+		for _, source := range sources {
+			if s, found := source[f.key]; found {
+				value, ok = s, true
+			}
+		}
+
+		if !ok || (value == "" && f.required) {
+			if f.required {
+				loadErr.Errors = append(loadErr.Errors, &KeyError{Key: f.key, Field: f.name, Err: ErrRequired})
+			}
+			continue
+		}
+
+		if err := setValue(f.value, value); err != nil {
+			loadErr.Errors = append(loadErr.Errors, &KeyError{Key: f.key, Field: f.name, Err: err})
+		}
+	}
+
+	if len(loadErr.Errors) > 0 {
+		return loadErr
+	}
+
+	return nil
+}
+
+type field struct {
+	key          string
+	name         string
+	value        reflect.Value
+	defaultValue string
+	hasDefault   bool
+	required     bool
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+	secretType          = reflect.TypeOf(Secret{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func collectFields(v reflect.Value, prefix, path string, fields *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		name := path + sf.Name
+
+		key, tagged := sf.Tag.Lookup("env")
+		if key == "-" {
+			continue
+		}
+
+		if !tagged {
+			if isNested(sf.Type) {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						fv.Set(reflect.New(sf.Type.Elem()))
+					}
+					fv = fv.Elem()
+				}
+				collectFields(fv, prefix+sf.Tag.Get("prefix"), name+".", fields)
+			}
+			continue
+		}
+
+		defaultValue, hasDefault := sf.Tag.Lookup("default")
+		required, _ := strconv.ParseBool(sf.Tag.Get("required"))
+
+		*fields = append(*fields, field{
+			key:          prefix + key,
+			name:         name,
+			value:        fv,
+			defaultValue: defaultValue,
+			hasDefault:   hasDefault,
+			required:     required,
+		})
+	}
+}
+
+// isNested reports whether the fields of a struct field are loaded rather than the field itself.
+func isNested(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != timeType && t != urlType && t != secretType &&
+		!reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func environment(fields []field) map[string]string {
+	values := make(map[string]string)
+	for _, f := range fields {
+		if s := getEnv(f.key); s != "" {
+			values[f.key] = s
+		}
+	}
+
+	return values
+}
+
+func parseFlags(fs *flag.FlagSet, arguments []string, fields []field) (map[string]string, error) {
+	// keys maps the names of the flags defined here to their key.
+	keys := make(map[string]string)
+	for _, f := range fields {
+		name := strings.ReplaceAll(strings.ToLower(f.key), "_", "-")
+		if fs.Lookup(name) == nil {
+			fs.String(name, f.defaultValue, fmt.Sprintf("sets %s (%s)", f.key, f.name))
+			keys[name] = f.key
+		}
+	}
+
+	if err := fs.Parse(arguments); err != nil {
+		return nil, errors.Wrap(err, "failed to parse flags")
+	}
+
+	values := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		if key, ok := keys[fl.Name]; ok {
+			values[key] = fl.Value.String()
+		}
+	})
+
+	return values, nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return errors.Errorf("invalid RFC 3339 time %q", s)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return errors.Errorf("invalid URL %q", s)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	case secretType:
+		secret, err := ResolveSecret(context.Background(), s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(NewSecret(secret)))
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("invalid unsigned integer %q", s)
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return errors.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(s) {
+			k, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return errors.Errorf("invalid map item %q, expected key=value", item)
+			}
+
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, strings.TrimSpace(val)); err != nil {
+				return errors.Wrapf(err, "key %s", k)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+
+	return items
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDatabaseConfig struct {
+	URL     *url.URL      `env:"URL" required:"true"`
+	Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+	Hosts   []string      `env:"HOSTS"`
+}
+
+type testConfig struct {
+	Port     int                `env:"PORT" default:"8888"`
+	Debug    bool               `env:"DEBUG"`
+	Ratio    float64            `env:"RATIO" default:"0.5"`
+	Labels   map[string]int     `env:"LABELS"`
+	Since    time.Time          `env:"SINCE"`
+	Database testDatabaseConfig `prefix:"DATABASE_"`
+	Cache    *struct {
+		TTL time.Duration `env:"TTL" default:"1m"`
+	} `prefix:"CACHE_"`
+	Ignored string `env:"-"`
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+port: 9000
+ratio: 0.25
+labels:
+  a: 1
+  b: 2
+database:
+  url: mysql://file
+  hosts: [db1, db2]
+`), 0600))
+
+	dotEnv := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(dotEnv, []byte(`
+# local overrides
+export DATABASE_URL="mysql://dotenv"
+DEBUG=true # enabled locally
+`), 0600))
+
+	t.Setenv("STARBOX_LOAD_TEST_DATABASE_TIMEOUT", "10s")
+	t.Setenv("DATABASE_TIMEOUT", "20s")
+	t.Setenv("SINCE", "2024-01-02T03:04:05Z")
+	SetPrefix("STARBOX_LOAD_TEST")
+	defer func() { envPrefix = "" }()
+
+	var cfg testConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err := Load(&cfg,
+		WithFile(file),
+		WithDotEnv(dotEnv),
+		WithFlags(fs, []string{"-port", "9100", "-cache-ttl", "2m"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 9100, cfg.Port)
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, 0.25, cfg.Ratio)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, cfg.Labels)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), cfg.Since)
+	assert.Equal(t, "mysql://dotenv", cfg.Database.URL.String())
+	assert.Equal(t, 10*time.Second, cfg.Database.Timeout)
+	assert.Equal(t, []string{"db1", "db2"}, cfg.Database.Hosts)
+	assert.Equal(t, 2*time.Minute, cfg.Cache.TTL)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("DATABASE_URL", "mysql://env")
+
+	var cfg testConfig
+	require.NoError(t, Load(&cfg))
+
+	assert.Equal(t, 8888, cfg.Port)
+	assert.False(t, cfg.Debug)
+	assert.Equal(t, 5*time.Second, cfg.Database.Timeout)
+	assert.Equal(t, time.Minute, cfg.Cache.TTL)
+	assert.Nil(t, cfg.Database.Hosts)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("PORT", "http")
+	t.Setenv("LABELS", "a=1,b")
+	t.Setenv("DATABASE_TIMEOUT", "soon")
+
+	var cfg testConfig
+	err := Load(&cfg)
+	require.Error(t, err)
+
+	var loadErr *LoadError
+	require.True(t, errors.As(err, &loadErr))
+
+	keys := make([]string, len(loadErr.Errors))
+	for i, e := range loadErr.Errors {
+		keys[i] = e.Key
+	}
+	assert.Equal(t, []string{"PORT", "LABELS", "DATABASE_URL", "DATABASE_TIMEOUT"}, keys)
+	assert.True(t, errors.Is(err, ErrRequired))
+	assert.Contains(t, err.Error(), `PORT (Port): invalid integer "http"`)
+
+	assert.Error(t, Load(cfg))
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Secret is a configuration value which is redacted when printed, logged or marshaled. The value is only
+// available through Value.
+type Secret struct {
+	value string
+}
+
+const redacted = "[REDACTED]"
+
+// NewSecret returns a secret holding the given value.
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Value returns the value of the secret.
+func (s Secret) Value() string {
+	return s.value
+}
+
+// IsZero reports whether the secret is empty.
+func (s Secret) IsZero() bool {
+	return s.value == ""
+}
+
+func (s Secret) String() string {
+	if s.value == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return "config.Secret(" + s.String() + ")"
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SecretProvider returns the secrets stored in a secret store, e.g. Vault.
+type SecretProvider interface {
+	// GetSecret returns the secret of the reference, stripped from its scheme and "://".
+	GetSecret(ctx context.Context, ref string) (string, error)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{}
+)
+
+// RegisterSecretProvider registers the provider of the references with the given scheme, e.g. "vault"
+// for "vault://secret/app#password".
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+
+	secretProviders[scheme] = p
+}
+
+// ResolveSecret returns the value referenced by ref:
+//
+//   - "file:///run/secrets/db_url" is replaced by the content of the file, without its trailing newline,
+//   - "env:OTHER_VAR" by the value of the OTHER_VAR environment variable, honoring SetPrefix,
+//   - "<scheme>://<path>" by the secret returned by the provider registered for the scheme.
+//
+// Any other value is returned as is.
+func ResolveSecret(ctx context.Context, ref string) (string, error) {
+	if name := strings.TrimPrefix(ref, "env:"); name != ref {
+		return getEnv(name), nil
+	}
+
+	scheme, path, ok := strings.Cut(ref, "://")
+	if !ok {
+		return ref, nil
+	}
+
+	if scheme == "file" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read secret file")
+		}
+
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	secretProvidersMu.RLock()
+	p, ok := secretProviders[scheme]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		// Not a reference, e.g. a URL.
+		return ref, nil
+	}
+
+	s, err := p.GetSecret(ctx, path)
+	return s, errors.Wrapf(err, "failed to get secret from %s provider", scheme)
+}
+
+// LookupSecret returns the secret referenced by the name environment variable, see ResolveSecret, or an
+// empty secret if the variable is not set.
+func LookupSecret(ctx context.Context, name string) (Secret, error) {
+	s, err := ResolveSecret(ctx, getEnv(name))
+	if err != nil {
+		return Secret{}, errors.Wrapf(err, "failed to resolve %s", name)
+	}
+
+	return NewSecret(s), nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	cfg := struct {
+		User     string
+		Password Secret
+	}{"app", NewSecret("p4ss")}
+
+	for _, s := range []string{
+		fmt.Sprint(cfg),
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("%#v", cfg),
+		fmt.Sprintf("%s %q", cfg.Password, cfg.Password),
+	} {
+		assert.NotContains(t, s, "p4ss")
+		assert.Contains(t, s, "[REDACTED]")
+	}
+
+	b, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, `{"User":"app","Password":"[REDACTED]"}`, string(b))
+
+	assert.Equal(t, "p4ss", cfg.Password.Value())
+}
+
+func TestResolveSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db_url")
+	require.NoError(t, os.WriteFile(file, []byte("mysql://file\n"), 0600))
+	t.Setenv("SECRET_TEST_OTHER", "mysql://env")
+
+	tests := []struct {
+		ref, value string
+	}{
+		{"file://" + file, "mysql://file"},
+		{"env:SECRET_TEST_OTHER", "mysql://env"},
+		{"mysql://literal", "mysql://literal"},
+		{"plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		value, err := ResolveSecret(context.Background(), tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.value, value, tt.ref)
+	}
+
+	_, err := ResolveSecret(context.Background(), "file:///missing")
+	assert.Error(t, err)
+}
+
+func TestLoadSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dsn")
+	require.NoError(t, os.WriteFile(file, []byte("https://key@sentry"), 0600))
+	t.Setenv("SENTRY_DSN", "file://"+file)
+
+	var cfg struct {
+		SentryDSN Secret  `env:"SENTRY_DSN" required:"true"`
+		Token     *Secret `env:"TOKEN" default:"t0ken"`
+	}
+	require.NoError(t, Load(&cfg))
+
+	assert.Equal(t, "https://key@sentry", cfg.SentryDSN.Value())
+	assert.Equal(t, "t0ken", cfg.Token.Value())
+
+	t.Setenv("SENTRY_DSN", "file:///missing")
+	assert.Error(t, Load(&cfg))
+}
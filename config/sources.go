@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads the keys of a YAML or JSON file.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	var content map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &content)
+	case ".json":
+		err = json.Unmarshal(b, &content)
+	default:
+		return nil, errors.Errorf("unsupported config file extension %q", ext)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+	}
+
+	values := make(map[string]string)
+	flatten("", content, values)
+
+	return values, nil
+}
+
+// flatten stores the values of m under their upper case keys, joined with underscores for the nested
+// objects. The nested objects are also stored as a whole, as comma separated key=value pairs, so they can
+// be loaded into maps.
+func flatten(prefix string, m map[string]interface{}, values map[string]string) {
+	for _, k := range sortedKeys(m) {
+		key := prefix + strings.ToUpper(k)
+
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			flatten(key+"_", v, values)
+
+			pairs := make([]string, 0, len(v))
+			for _, vk := range sortedKeys(v) {
+				pairs = append(pairs, vk+"="+formatValue(v[vk]))
+			}
+			values[key] = strings.Join(pairs, ",")
+		default:
+			values[key] = formatValue(v)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return strings.Join(items, ",")
+	case float64:
+		// JSON numbers are decoded as float64.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readDotEnv reads the KEY=value lines of a .env file. Empty lines, comments and the "export" keyword are
+// ignored, and the values can be quoted.
+func readDotEnv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, errors.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					value = value[1 : len(value)-1]
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		values[strings.TrimSpace(key)] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	return values, nil
+}
@@ -6,4 +6,11 @@ const RedisURL = "REDIS_URL"
 const PrometheusEnabled = "PROMETHEUS_ENABLED"
 const SentryDSN = "SENTRY_DSN"
 const WebPort = "WEB_PORT"
+const WebServerCert = "WEB_SERVER_CERT"
+const WebServerKey = "WEB_SERVER_KEY"
+const WebClientCA = "WEB_CLIENT_CA"
+const WebClientAuth = "WEB_CLIENT_AUTH"
+const WebTLSMinVersion = "WEB_TLS_MIN_VERSION"
+const WebTrustedProxies = "WEB_TRUSTED_PROXIES"
+const FeatureFlagsPath = "FEATURE_FLAGS_PATH"
 const ZipkinCollectorURL = "ZIPKIN_COLLECTOR_URL"
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/starclusterteam/go-starbox/health"
+)
+
+// HealthChecker returns a health checker that pings the database behind the given connection.
+func HealthChecker(d *gorm.DB) health.Checker {
+	return health.CheckerFunc(func(ctx context.Context) error {
+		sqlDB, err := d.DB()
+		if err != nil {
+			return errors.Wrap(err, "failed to get database handle")
+		}
+
+		return errors.Wrap(sqlDB.PingContext(ctx), "failed to ping database")
+	})
+}
@@ -0,0 +1,262 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Kind classifies an error so that it can be mapped to an HTTP status or a gRPC code.
+type Kind int
+
+// Error kinds.
+const (
+	KindUnknown Kind = iota
+	KindInvalidArgument
+	KindNotFound
+	KindConflict
+	KindUnauthenticated
+	KindPermissionDenied
+	KindFailedPrecondition
+	KindTooManyRequests
+	KindUnavailable
+	KindTimeout
+	KindInternal
+)
+
+var kinds = map[Kind]struct {
+	name       string
+	httpStatus int
+	grpcCode   codes.Code
+}{
+	KindUnknown:            {"unknown", http.StatusInternalServerError, codes.Unknown},
+	KindInvalidArgument:    {"bad_request", http.StatusBadRequest, codes.InvalidArgument},
+	KindNotFound:           {"not_found", http.StatusNotFound, codes.NotFound},
+	KindConflict:           {"conflict", http.StatusConflict, codes.AlreadyExists},
+	KindUnauthenticated:    {"unauthenticated", http.StatusUnauthorized, codes.Unauthenticated},
+	KindPermissionDenied:   {"forbidden", http.StatusForbidden, codes.PermissionDenied},
+	KindFailedPrecondition: {"failed_precondition", http.StatusPreconditionFailed, codes.FailedPrecondition},
+	KindTooManyRequests:    {"too_many_requests", http.StatusTooManyRequests, codes.ResourceExhausted},
+	KindUnavailable:        {"unavailable", http.StatusServiceUnavailable, codes.Unavailable},
+	KindTimeout:            {"timeout", http.StatusGatewayTimeout, codes.DeadlineExceeded},
+	KindInternal:           {"internal", http.StatusInternalServerError, codes.Internal},
+}
+
+// String returns the snake case name of the kind, e.g. "not_found". It is used as the error key in HTTP responses.
+func (k Kind) String() string {
+	if v, ok := kinds[k]; ok {
+		return v.name
+	}
+
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// HTTPStatus returns the HTTP status code matching the kind.
+func (k Kind) HTTPStatus() int {
+	if v, ok := kinds[k]; ok {
+		return v.httpStatus
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC code matching the kind.
+func (k Kind) GRPCCode() codes.Code {
+	if v, ok := kinds[k]; ok {
+		return v.grpcCode
+	}
+
+	return codes.Unknown
+}
+
+// KindFromHTTPStatus returns the kind matching the given HTTP status code.
+func KindFromHTTPStatus(status int) Kind {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return KindInvalidArgument
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusConflict:
+		return KindConflict
+	case http.StatusUnauthorized:
+		return KindUnauthenticated
+	case http.StatusForbidden:
+		return KindPermissionDenied
+	case http.StatusPreconditionFailed:
+		return KindFailedPrecondition
+	case http.StatusTooManyRequests:
+		return KindTooManyRequests
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return KindUnavailable
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return KindTimeout
+	case http.StatusInternalServerError:
+		return KindInternal
+	default:
+		return KindUnknown
+	}
+}
+
+// KindFromGRPCCode returns the kind matching the given gRPC code.
+func KindFromGRPCCode(code codes.Code) Kind {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return KindInvalidArgument
+	case codes.NotFound:
+		return KindNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return KindConflict
+	case codes.Unauthenticated:
+		return KindUnauthenticated
+	case codes.PermissionDenied:
+		return KindPermissionDenied
+	case codes.FailedPrecondition:
+		return KindFailedPrecondition
+	case codes.ResourceExhausted:
+		return KindTooManyRequests
+	case codes.Unavailable:
+		return KindUnavailable
+	case codes.DeadlineExceeded:
+		return KindTimeout
+	case codes.Internal, codes.DataLoss:
+		return KindInternal
+	default:
+		return KindUnknown
+	}
+}
+
+// FieldError describes an error related to a single request field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error is an error with a kind, an optional application specific code, a message that is safe
+// to show to clients and optional field errors. The cause, if any, is never exposed to clients.
+type Error struct {
+	Kind    Kind
+	Code    int
+	Message string
+	Fields  []FieldError
+
+	cause error
+}
+
+// E returns a new error of the given kind with a public message.
+func E(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// InvalidArgument returns a new error of kind KindInvalidArgument.
+func InvalidArgument(message string) *Error {
+	return E(KindInvalidArgument, message)
+}
+
+// NotFound returns a new error of kind KindNotFound.
+func NotFound(message string) *Error {
+	return E(KindNotFound, message)
+}
+
+// Conflict returns a new error of kind KindConflict.
+func Conflict(message string) *Error {
+	return E(KindConflict, message)
+}
+
+// Unauthenticated returns a new error of kind KindUnauthenticated.
+func Unauthenticated(message string) *Error {
+	return E(KindUnauthenticated, message)
+}
+
+// PermissionDenied returns a new error of kind KindPermissionDenied.
+func PermissionDenied(message string) *Error {
+	return E(KindPermissionDenied, message)
+}
+
+// Unavailable returns a new error of kind KindUnavailable.
+func Unavailable(message string) *Error {
+	return E(KindUnavailable, message)
+}
+
+// Internal returns a new error of kind KindInternal that wraps the given cause.
+func Internal(cause error) *Error {
+	return E(KindInternal, "internal server error").WithCause(cause)
+}
+
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.cause.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is an *Error with the same kind, code and message, so that errors.Is
+// matches copies created with the With* methods against a package level error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return t.Kind == e.Kind && t.Code == e.Code && t.Message == e.Message
+}
+
+// WithCode returns a copy of the error with the given application code.
+func (e *Error) WithCode(code int) *Error {
+	c := e.clone()
+	c.Code = code
+	return c
+}
+
+// WithField returns a copy of the error with an error for the given field for each message.
+func (e *Error) WithField(field string, messages ...string) *Error {
+	c := e.clone()
+	for _, m := range messages {
+		c.Fields = append(c.Fields, FieldError{Field: field, Message: m})
+	}
+	return c
+}
+
+// WithCause returns a copy of the error wrapping the given cause.
+func (e *Error) WithCause(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	return c
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.Fields = append([]FieldError(nil), e.Fields...)
+	return &c
+}
+
+// AsError finds the first *Error in the chain of err.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	return nil, false
+}
+
+// KindOf returns the kind of the first *Error in the chain of err, or KindUnknown.
+func KindOf(err error) Kind {
+	if e, ok := AsError(err); ok {
+		return e.Kind
+	}
+
+	return KindUnknown
+}
+
+// As finds the first error in err's chain that matches target. See errors.As in the standard library.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
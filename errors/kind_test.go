@@ -0,0 +1,49 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+var errUserNotFound = NotFound("user not found").WithCode(1001)
+
+func TestTypedErrorMessage(t *testing.T) {
+	assert.Equal(t, "user not found", errUserNotFound.Error())
+
+	err := errUserNotFound.WithCause(fmt.Errorf("record not found"))
+	assert.Equal(t, "user not found: record not found", err.Error())
+}
+
+func TestTypedErrorIs(t *testing.T) {
+	err := Wrap(errUserNotFound.WithField("id", "unknown id"), "failed to get user")
+
+	assert.True(t, Is(err, errUserNotFound))
+	assert.False(t, Is(err, NotFound("user not found")))
+	assert.Equal(t, KindNotFound, KindOf(err))
+	assert.Equal(t, KindUnknown, KindOf(fmt.Errorf("plain")))
+
+	e, ok := AsError(err)
+	assert.True(t, ok)
+	assert.Equal(t, []FieldError{{Field: "id", Message: "unknown id"}}, e.Fields)
+	assert.Empty(t, errUserNotFound.Fields, "With* methods must not change the original error")
+}
+
+func TestKindMapping(t *testing.T) {
+	for k := KindUnknown; k <= KindInternal; k++ {
+		t.Run(k.String(), func(t *testing.T) {
+			if k != KindUnknown {
+				assert.Equal(t, k, KindFromGRPCCode(k.GRPCCode()))
+			}
+			if k != KindUnknown && k != KindInternal {
+				assert.Equal(t, k, KindFromHTTPStatus(k.HTTPStatus()))
+			}
+		})
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, Kind(100).HTTPStatus())
+	assert.Equal(t, codes.Unknown, Kind(100).GRPCCode())
+}
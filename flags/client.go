@@ -0,0 +1,235 @@
+package flags
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// DefaultClient is the client used by the package level functions. It loads the flags from the file
+// given by the FEATURE_FLAGS_PATH environment variable, if set, and has no flags otherwise.
+var DefaultClient = NewStatic(nil)
+
+func init() {
+	path := config.String(envvar.FeatureFlagsPath, "")
+	if path == "" {
+		return
+	}
+
+	c, err := New(path)
+	if err != nil {
+		log.Errorf("Failed to load feature flags: %v", err)
+		return
+	}
+
+	DefaultClient = c
+}
+
+// Client evaluates flags. It is safe for concurrent use.
+type Client struct {
+	path string
+
+	mu      sync.RWMutex
+	flags   map[string]*Flag
+	content []byte
+
+	fw      *filewatch.Watcher
+	metrics *flagMetrics
+}
+
+// New loads the flags from a YAML or JSON file, mapping the keys of the flags to their definition, and
+// reloads them when the file changes. An invalid file is rejected as a whole: the error is logged and
+// the previous flags are kept.
+func New(path string) (*Client, error) {
+	c := &Client{
+		path:    path,
+		metrics: defaultFlagMetrics,
+	}
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	logger := log.Logger().With("path", path)
+	fw, err := filewatch.New([]string{filepath.Dir(path)}, func() {
+		if err := c.reload(); err != nil {
+			logger.Errorf("Failed to reload feature flags, keeping the previous ones: %v", err)
+		}
+	}, func(err error) {
+		logger.Errorf("Feature flags watcher error: %v", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.fw = fw
+
+	return c, nil
+}
+
+// NewStatic returns a client evaluating the given flags, e.g. for tests. It panics if a flag is invalid.
+func NewStatic(flags map[string]*Flag) *Client {
+	for key, f := range flags {
+		if err := f.validate(); err != nil {
+			panic(errors.Wrapf(err, "invalid flag %s", key))
+		}
+	}
+
+	return &Client{
+		flags:   flags,
+		metrics: defaultFlagMetrics,
+	}
+}
+
+// Close stops watching the file.
+func (c *Client) Close() error {
+	if c.fw == nil {
+		return nil
+	}
+
+	return c.fw.Close()
+}
+
+// Evaluate evaluates the flag against the evaluation context stored in ctx, or an empty one. The
+// evaluation is counted and the active span, if any, is tagged with the variant.
+func (c *Client) Evaluate(ctx context.Context, key string) Evaluation {
+	ev := c.evaluate(ctx, key)
+	c.record(ctx, ev)
+
+	return ev
+}
+
+// Bool returns the value of a boolean flag, or def if the flag doesn't exist or isn't boolean.
+func (c *Client) Bool(ctx context.Context, key string, def bool) bool {
+	ev := c.evaluate(ctx, key)
+
+	value, ok := ev.Value.(bool)
+	if !ok {
+		ev, value = mismatch(ev), def
+	}
+	c.record(ctx, ev)
+
+	return value
+}
+
+// String returns the value of a flag whose variants are strings, or def if the flag doesn't exist or its
+// value isn't a string.
+func (c *Client) String(ctx context.Context, key string, def string) string {
+	ev := c.evaluate(ctx, key)
+
+	value, ok := ev.Value.(string)
+	if !ok {
+		ev, value = mismatch(ev), def
+	}
+	c.record(ctx, ev)
+
+	return value
+}
+
+func mismatch(ev Evaluation) Evaluation {
+	if ev.Reason != ReasonNotFound {
+		ev.Reason = ReasonTypeMismatch
+	}
+
+	return ev
+}
+
+func (c *Client) evaluate(ctx context.Context, key string) Evaluation {
+	c.mu.RLock()
+	f, ok := c.flags[key]
+	c.mu.RUnlock()
+	if !ok {
+		return Evaluation{Flag: key, Reason: ReasonNotFound}
+	}
+
+	ec, ok := FromContext(ctx)
+	if !ok {
+		ec = &EvalContext{}
+	}
+
+	return f.evaluate(key, ec)
+}
+
+func (c *Client) record(ctx context.Context, ev Evaluation) {
+	c.metrics.evaluated(ev)
+	annotate(ctx, ev)
+}
+
+// reload loads the file if its content changed.
+func (c *Client) reload() error {
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		c.metrics.reloads.Reloaded(false)
+		return errors.Wrapf(err, "failed to read %s", c.path)
+	}
+
+	c.mu.RLock()
+	unchanged := c.content != nil && bytes.Equal(b, c.content)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	flags, err := parse(b)
+	if err != nil {
+		c.metrics.reloads.Reloaded(false)
+		return errors.Wrapf(err, "failed to parse %s", c.path)
+	}
+
+	c.mu.Lock()
+	c.flags = flags
+	c.content = b
+	c.mu.Unlock()
+
+	c.metrics.reloads.Reloaded(true)
+	return nil
+}
+
+// parse parses the flags of a YAML file, JSON files being valid YAML.
+func parse(b []byte) (map[string]*Flag, error) {
+	flags := make(map[string]*Flag)
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&flags); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for key, f := range flags {
+		if f == nil {
+			f = &Flag{}
+			flags[key] = f
+		}
+
+		if err := f.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid flag %s", key)
+		}
+	}
+
+	return flags, nil
+}
+
+// Evaluate evaluates the flag with DefaultClient, see Client.Evaluate.
+func Evaluate(ctx context.Context, key string) Evaluation {
+	return DefaultClient.Evaluate(ctx, key)
+}
+
+// Bool returns the value of a boolean flag of DefaultClient, see Client.Bool.
+func Bool(ctx context.Context, key string, def bool) bool {
+	return DefaultClient.Bool(ctx, key, def)
+}
+
+// String returns the value of a string flag of DefaultClient, see Client.String.
+func String(ctx context.Context, key string, def string) string {
+	return DefaultClient.String(ctx, key, def)
+}
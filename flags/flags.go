@@ -0,0 +1,301 @@
+// Package flags evaluates feature flags against the context of a request: the user, the tenant, the
+// request headers and the environment. Flags are defined in a local YAML or JSON file, reloaded when it
+// changes, and can be rolled out to a percentage of the users. The web middleware and the scrpc
+// interceptors add the evaluation context of the requests to their context.
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+)
+
+// TenantHeader is the header, or gRPC metadata, holding the tenant of a request.
+const TenantHeader = "X-Tenant-Id"
+
+// Attributes of the evaluation context which can be used in rules. The request headers are matched with
+// "header:<name>" and the other attributes by their name.
+const (
+	AttributeUserID      = "user_id"
+	AttributeTenant      = "tenant"
+	AttributeEnvironment = "environment"
+
+	headerAttributePrefix = "header:"
+)
+
+// EvalContext is the context against which the flags are evaluated.
+type EvalContext struct {
+	UserID string
+	Tenant string
+	// Environment defaults to the name of the environment, the STARBOX_ENV environment variable.
+	Environment string
+	Headers     http.Header
+	Attributes  map[string]string
+}
+
+// defaultEnvironment returns the name of the environment, read once.
+var defaultEnvironment = sync.OnceValue(environmentName)
+
+// environmentName reads the name of the environment as config.FetchGoEnv would, without updating the
+// environment of config concurrently.
+func environmentName() string {
+	return config.String(envvar.Env, "development")
+}
+
+// Attribute returns the value of the given attribute, see the Attribute constants.
+func (ec *EvalContext) Attribute(name string) string {
+	switch name {
+	case AttributeUserID:
+		return ec.UserID
+	case AttributeTenant:
+		return ec.Tenant
+	case AttributeEnvironment:
+		if ec.Environment == "" {
+			return defaultEnvironment()
+		}
+		return ec.Environment
+	}
+
+	if header := strings.TrimPrefix(name, headerAttributePrefix); header != name {
+		return ec.Headers.Get(header)
+	}
+
+	return ec.Attributes[name]
+}
+
+type evalContextKey struct{}
+
+// NewContext returns a copy of ctx holding the evaluation context.
+func NewContext(ctx context.Context, ec *EvalContext) context.Context {
+	return context.WithValue(ctx, evalContextKey{}, ec)
+}
+
+// FromContext returns the evaluation context stored in ctx, if any.
+func FromContext(ctx context.Context) (*EvalContext, bool) {
+	ec, ok := ctx.Value(evalContextKey{}).(*EvalContext)
+	return ec, ok
+}
+
+// RequestContext returns the evaluation context of a request with the given headers. The user is the
+// subject of the principal authenticated in ctx, and the tenant is given by the TenantHeader header or,
+// if missing, by the "tenant" claim of the principal.
+func RequestContext(ctx context.Context, headers http.Header) *EvalContext {
+	ec := &EvalContext{
+		Tenant:  headers.Get(TenantHeader),
+		Headers: headers,
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		ec.UserID = p.Subject
+		if tenant, ok := p.Claims["tenant"].(string); ok && ec.Tenant == "" {
+			ec.Tenant = tenant
+		}
+	}
+
+	return ec
+}
+
+// Flag is the definition of a flag. Boolean flags have no variants: their variants are "true" and
+// "false", the latter being the default.
+//
+//	new-checkout:
+//	  rules:
+//	    - match: {tenant: [acme]}
+//	      variant: true
+//	    - rollout: {"true": 20, "false": 80}
+//	checkout-theme:
+//	  variants: {control: blue, dark: black}
+//	  default: control
+//	  rules:
+//	    - match: {environment: [staging]}
+//	      variant: dark
+type Flag struct {
+	// Variants maps the names of the variants to their values.
+	Variants map[string]interface{} `yaml:"variants"`
+	// Default is the variant used when no rule matches.
+	Default string `yaml:"default"`
+	// Rules are evaluated in order, the first matching one gives the variant.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule selects a variant for the evaluation contexts it matches.
+type Rule struct {
+	// Match maps attributes to their accepted values. The rule matches if all the attributes have one of
+	// their values; a rule without Match matches any context.
+	Match map[string][]string `yaml:"match"`
+	// Variant is the variant given by the rule.
+	Variant string `yaml:"variant"`
+	// Rollout gives the variants by percentage instead of Variant, e.g. {"true": 10, "false": 90}. The
+	// contexts are bucketed by hashing the flag and the By attribute, so users keep their variant and
+	// the users of a variant stay in it when its percentage grows.
+	Rollout map[string]float64 `yaml:"rollout"`
+	// By is the attribute the rollout is based on. It defaults to the user id. The rule is skipped for
+	// the contexts where the attribute is empty.
+	By string `yaml:"by"`
+}
+
+var booleanVariants = map[string]interface{}{"true": true, "false": false}
+
+// validate checks the definition of the flag and sets its defaults.
+func (f *Flag) validate() error {
+	if len(f.Variants) == 0 {
+		f.Variants = booleanVariants
+		if f.Default == "" {
+			f.Default = "false"
+		}
+	}
+
+	if _, ok := f.Variants[f.Default]; !ok {
+		return errors.Errorf("unknown default variant %q", f.Default)
+	}
+
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if (r.Variant == "") == (len(r.Rollout) == 0) {
+			return errors.Errorf("rule %d: expected either a variant or a rollout", i)
+		}
+
+		if r.Variant != "" {
+			if _, ok := f.Variants[r.Variant]; !ok {
+				return errors.Errorf("rule %d: unknown variant %q", i, r.Variant)
+			}
+			continue
+		}
+
+		total := 0.0
+		for variant, percentage := range r.Rollout {
+			if _, ok := f.Variants[variant]; !ok {
+				return errors.Errorf("rule %d: unknown rollout variant %q", i, variant)
+			}
+			if percentage < 0 {
+				return errors.Errorf("rule %d: negative percentage for variant %q", i, variant)
+			}
+			total += percentage
+		}
+
+		if math.Abs(total-100) > 1e-6 {
+			return errors.Errorf("rule %d: rollout percentages add up to %v instead of 100", i, total)
+		}
+
+		if r.By == "" {
+			r.By = AttributeUserID
+		}
+	}
+
+	return nil
+}
+
+// Reason explains how the variant of an evaluation was chosen.
+type Reason string
+
+// Reasons of the evaluations.
+const (
+	// ReasonRule is given when a rule with a fixed variant matched.
+	ReasonRule Reason = "rule"
+	// ReasonRollout is given when a rollout rule matched.
+	ReasonRollout Reason = "rollout"
+	// ReasonDefault is given when no rule matched.
+	ReasonDefault Reason = "default"
+	// ReasonNotFound is given for unknown flags, the default value of the caller is used.
+	ReasonNotFound Reason = "not_found"
+	// ReasonTypeMismatch is given when the value of the variant hasn't the type expected by the caller,
+	// whose default value is used.
+	ReasonTypeMismatch Reason = "type_mismatch"
+)
+
+// Evaluation is the result of the evaluation of a flag.
+type Evaluation struct {
+	Flag    string
+	Variant string
+	Value   interface{}
+	Reason  Reason
+}
+
+func (f *Flag) evaluate(key string, ec *EvalContext) Evaluation {
+	for _, r := range f.Rules {
+		if !r.matches(ec) {
+			continue
+		}
+
+		if r.Variant != "" {
+			return Evaluation{Flag: key, Variant: r.Variant, Value: f.Variants[r.Variant], Reason: ReasonRule}
+		}
+
+		value := ec.Attribute(r.By)
+		if value == "" {
+			continue
+		}
+
+		variant := r.rollout(key, value)
+		return Evaluation{Flag: key, Variant: variant, Value: f.Variants[variant], Reason: ReasonRollout}
+	}
+
+	return Evaluation{Flag: key, Variant: f.Default, Value: f.Variants[f.Default], Reason: ReasonDefault}
+}
+
+func (r *Rule) matches(ec *EvalContext) bool {
+	for attribute, accepted := range r.Match {
+		value := ec.Attribute(attribute)
+
+		found := false
+		for _, a := range accepted {
+			if a == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rolloutBuckets is the number of buckets the contexts are spread over, allowing percentages with two
+// decimals.
+const rolloutBuckets = 10000
+
+// rollout returns the variant of the bucket of the value. The variants take consecutive ranges of
+// buckets in the order of their names, so changing the percentages only moves the users at the edges.
+func (r *Rule) rollout(key, value string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key + "/" + value))
+	bucket := float64(h.Sum64() % rolloutBuckets)
+
+	variants := make([]string, 0, len(r.Rollout))
+	for v := range r.Rollout {
+		variants = append(variants, v)
+	}
+	sort.Strings(variants)
+
+	upper := 0.0
+	for _, v := range variants {
+		upper += r.Rollout[v] * rolloutBuckets / 100
+		if bucket < upper {
+			return v
+		}
+	}
+
+	// Only reachable through rounding errors.
+	return variants[len(variants)-1]
+}
+
+// annotate tags the active span with the variant of the flag.
+func annotate(ctx context.Context, ev Evaluation) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("feature_flag."+ev.Flag, ev.Variant)
+	}
+}
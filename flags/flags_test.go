@@ -0,0 +1,195 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+)
+
+func withContext(ec *EvalContext) context.Context {
+	return NewContext(context.Background(), ec)
+}
+
+func TestEvaluate(t *testing.T) {
+	c := NewStatic(map[string]*Flag{
+		"new-checkout": {
+			Rules: []Rule{
+				{Match: map[string][]string{AttributeTenant: {"acme", "globex"}}, Variant: "true"},
+				{Match: map[string][]string{"header:X-Beta": {"1"}, AttributeEnvironment: {"staging"}}, Variant: "true"},
+			},
+		},
+		"theme": {
+			Variants: map[string]interface{}{"control": "blue", "dark": "black"},
+			Default:  "control",
+			Rules: []Rule{
+				{Match: map[string][]string{"plan": {"pro"}}, Variant: "dark"},
+			},
+		},
+	})
+
+	assert.True(t, c.Bool(withContext(&EvalContext{Tenant: "acme"}), "new-checkout", false))
+	assert.False(t, c.Bool(withContext(&EvalContext{Tenant: "initech"}), "new-checkout", true))
+	assert.False(t, c.Bool(context.Background(), "new-checkout", true))
+
+	beta := &EvalContext{Headers: http.Header{"X-Beta": {"1"}}, Environment: "staging"}
+	assert.True(t, c.Bool(withContext(beta), "new-checkout", false))
+	beta.Environment = "production"
+	assert.False(t, c.Bool(withContext(beta), "new-checkout", false))
+
+	assert.Equal(t, "black", c.String(withContext(&EvalContext{Attributes: map[string]string{"plan": "pro"}}), "theme", "white"))
+	assert.Equal(t, "blue", c.String(context.Background(), "theme", "white"))
+
+	// Unknown flags and type mismatches give the default value.
+	assert.True(t, c.Bool(context.Background(), "unknown", true))
+	assert.True(t, c.Bool(context.Background(), "theme", true))
+	assert.Equal(t, "white", c.String(context.Background(), "new-checkout", "white"))
+
+	ev := c.Evaluate(withContext(&EvalContext{Tenant: "globex"}), "new-checkout")
+	assert.Equal(t, Evaluation{Flag: "new-checkout", Variant: "true", Value: true, Reason: ReasonRule}, ev)
+	assert.Equal(t, ReasonNotFound, c.Evaluate(context.Background(), "unknown").Reason)
+}
+
+func TestDefaultEnvironment(t *testing.T) {
+	c := NewStatic(map[string]*Flag{
+		"test-only": {Rules: []Rule{{Match: map[string][]string{AttributeEnvironment: {"test"}}, Variant: "true"}}},
+	})
+
+	// The default environment is resolved again from the environment set by the test.
+	t.Setenv(envvar.Env, "test")
+	defer func(f func() string) { defaultEnvironment = f }(defaultEnvironment)
+	defaultEnvironment = sync.OnceValue(environmentName)
+
+	// The default environment is read concurrently by the requests.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, c.Bool(withContext(&EvalContext{}), "test-only", false))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRollout(t *testing.T) {
+	flag := func(percentage float64) *Flag {
+		return &Flag{
+			Rules: []Rule{{Rollout: map[string]float64{"true": percentage, "false": 100 - percentage}}},
+		}
+	}
+
+	c := NewStatic(map[string]*Flag{"rollout": flag(20)})
+
+	enabled := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		if c.Bool(withContext(&EvalContext{UserID: user}), "rollout", false) {
+			enabled[user] = true
+		}
+	}
+	assert.InDelta(t, 2000, len(enabled), 200)
+
+	// The evaluation is consistent.
+	for user := range enabled {
+		assert.True(t, c.Bool(withContext(&EvalContext{UserID: user}), "rollout", false))
+	}
+
+	// The users keep the flag when the rollout grows.
+	c = NewStatic(map[string]*Flag{"rollout": flag(50)})
+	for user := range enabled {
+		assert.True(t, c.Bool(withContext(&EvalContext{UserID: user}), "rollout", false))
+	}
+
+	// Contexts without user get the default variant.
+	assert.Equal(t, ReasonDefault, c.Evaluate(context.Background(), "rollout").Reason)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		flag Flag
+	}{
+		{"unknown default", Flag{Default: "on"}},
+		{"unknown variant", Flag{Rules: []Rule{{Variant: "on"}}}},
+		{"variant and rollout", Flag{Rules: []Rule{{Variant: "true", Rollout: map[string]float64{"true": 100}}}}},
+		{"empty rule", Flag{Rules: []Rule{{Match: map[string][]string{AttributeTenant: {"acme"}}}}}},
+		{"rollout total", Flag{Rules: []Rule{{Rollout: map[string]float64{"true": 20, "false": 20}}}}},
+		{"unknown rollout variant", Flag{Rules: []Rule{{Rollout: map[string]float64{"on": 100}}}}},
+	}
+
+	for _, tt := range tests {
+		assert.Error(t, tt.flag.validate(), tt.name)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+new-checkout:
+  rules:
+    - match: {tenant: [acme]}
+      variant: true
+`), 0600))
+
+	c, err := New(path)
+	require.NoError(t, err)
+	defer c.Close()
+
+	acme := withContext(&EvalContext{Tenant: "acme"})
+	assert.True(t, c.Bool(acme, "new-checkout", false))
+
+	// Invalid flags are rejected and the previous ones kept.
+	require.NoError(t, os.WriteFile(path, []byte("new-checkout: {default: maybe}"), 0600))
+	time.Sleep(3 * filewatch.Delay)
+	assert.True(t, c.Bool(acme, "new-checkout", false))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"new-checkout": {"default": true}}`), 0600))
+	assert.Eventually(t, func() bool {
+		return c.Bool(context.Background(), "new-checkout", false)
+	}, 5*time.Second, 20*time.Millisecond)
+
+	_, err = New(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestRequestContext(t *testing.T) {
+	ctx := auth.NewContext(context.Background(), &auth.Principal{
+		Subject: "john",
+		Claims:  map[string]interface{}{"tenant": "acme"},
+	})
+
+	ec := RequestContext(ctx, http.Header{})
+	assert.Equal(t, "john", ec.UserID)
+	assert.Equal(t, "acme", ec.Tenant)
+
+	ec = RequestContext(ctx, http.Header{TenantHeader: {"globex"}})
+	assert.Equal(t, "globex", ec.Tenant)
+}
+
+func TestSpanTag(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	ctx := opentracing.ContextWithSpan(withContext(&EvalContext{Tenant: "acme"}), span)
+
+	c := NewStatic(map[string]*Flag{
+		"new-checkout": {Rules: []Rule{{Match: map[string][]string{AttributeTenant: {"acme"}}, Variant: "true"}}},
+	})
+	c.Bool(ctx, "new-checkout", false)
+	span.Finish()
+
+	assert.Equal(t, "true", tracer.FinishedSpans()[0].Tag("feature_flag.new-checkout"))
+}
@@ -0,0 +1,48 @@
+package flags
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/internal/filewatch"
+)
+
+var defaultFlagMetrics = newFlagMetrics()
+
+func init() {
+	if config.Bool(envvar.PrometheusEnabled, false) {
+		defaultFlagMetrics.mustRegister()
+	}
+}
+
+type flagMetrics struct {
+	evaluations *prometheus.CounterVec
+	reloads     filewatch.ReloadCounter
+}
+
+func newFlagMetrics() *flagMetrics {
+	var m flagMetrics
+	m.evaluations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feature_flag_evaluations_total",
+			Help: "The number of feature flag evaluations.",
+		},
+		[]string{"flag", "variant", "reason"},
+	)
+
+	m.reloads = filewatch.NewReloadCounter(
+		"feature_flag_reloads_total",
+		"The number of feature flag file reloads.",
+	)
+
+	return &m
+}
+
+func (m *flagMetrics) mustRegister() {
+	prometheus.MustRegister(m.evaluations, m.reloads)
+}
+
+func (m *flagMetrics) evaluated(ev Evaluation) {
+	m.evaluations.WithLabelValues(ev.Flag, ev.Variant, string(ev.Reason)).Inc()
+}
@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Pinger is implemented by *sql.DB and other clients able to check their connection.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping returns a checker that pings the given client, e.g. a *sql.DB.
+func Ping(p Pinger) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return errors.Wrap(p.PingContext(ctx), "ping failed")
+	})
+}
+
+// GRPCConn returns a checker that reports the connectivity state of a gRPC client connection.
+// Idle connections are considered healthy and are asked to connect.
+func GRPCConn(conn *grpc.ClientConn) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		state := conn.GetState()
+		if state == connectivity.Connecting && conn.WaitForStateChange(ctx, state) {
+			state = conn.GetState()
+		}
+
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+			return nil
+		default:
+			return errors.Errorf("grpc connection to %s is %s", conn.Target(), state)
+		}
+	})
+}
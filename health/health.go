@@ -0,0 +1,297 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// Status is the status of a check or of a whole report.
+type Status string
+
+// Statuses reported by checks.
+const (
+	StatusUp   Status = "ok"
+	StatusDown Status = "unavailable"
+)
+
+// ErrShuttingDown is reported by readiness once the registry is shutting down.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Checker checks the health of a dependency. A nil error means the dependency is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter that allows the use of ordinary functions as checkers.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DefaultRegistry is the registry used by the package level functions and, unless configured
+// otherwise, by the web and scrpc servers.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a named checker to the default registry.
+func Register(name string, c Checker, opts ...CheckOption) {
+	DefaultRegistry.Register(name, c, opts...)
+}
+
+// Shutdown marks the default registry as shutting down.
+func Shutdown() {
+	DefaultRegistry.Shutdown()
+}
+
+// Registry keeps the registered checks and evaluates them.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+
+	shuttingDown atomic.Bool
+}
+
+type check struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+	liveness bool
+	services []string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a named checker to the registry. Registering a name twice replaces the previous checker.
+// Checks are critical, run with a 2s timeout and are only part of the readiness report by default.
+func (r *Registry) Register(name string, c Checker, opts ...CheckOption) {
+	ch := &check{
+		name:     name,
+		checker:  c,
+		timeout:  defaultTimeout,
+		critical: true,
+	}
+
+	for _, o := range opts {
+		o(ch)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.checks {
+		if existing.name == name {
+			r.checks[i] = ch
+			return
+		}
+	}
+
+	r.checks = append(r.checks, ch)
+}
+
+// Unregister removes the checker registered under the given name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.checks {
+		if existing.name == name {
+			r.checks = append(r.checks[:i], r.checks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Shutdown marks the registry as shutting down. From then on, readiness is always reported as down.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown returns true if Shutdown was called.
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Liveness runs the checks registered with the Liveness option.
+func (r *Registry) Liveness(ctx context.Context) Report {
+	return r.run(ctx, func(c *check) bool { return c.liveness }, nil)
+}
+
+// Readiness runs all the registered checks. It reports down if a critical check failed or if the
+// registry is shutting down.
+func (r *Registry) Readiness(ctx context.Context) Report {
+	var err error
+	if r.ShuttingDown() {
+		err = ErrShuttingDown
+	}
+
+	return r.run(ctx, func(*check) bool { return true }, err)
+}
+
+func (r *Registry) run(ctx context.Context, include func(*check) bool, err error) Report {
+	r.mu.RLock()
+	var checks []*check
+	for _, c := range r.checks {
+		if include(c) {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{
+		Checks: make(map[string]CheckResult, len(results)),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	for _, res := range results {
+		report.Checks[res.name] = res
+	}
+	report.Status = report.Service("")
+
+	return report
+}
+
+func (c *check) run(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// The checker runs in its own goroutine so that a checker ignoring ctx cannot block the report
+	// past the timeout.
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+		Critical: c.critical,
+		name:     c.name,
+		services: c.services,
+	}
+
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// Report is the result of running a set of checks.
+type Report struct {
+	Status Status                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// CheckResult is the result of a single check.
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Critical bool   `json:"critical"`
+
+	name     string
+	services []string
+}
+
+// Healthy returns true if the report status is up.
+func (r Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+// Service returns the status of the given gRPC service name, taking into account only the critical
+// checks that apply to it. The empty name stands for the whole server.
+func (r Report) Service(name string) Status {
+	if r.Error != "" {
+		return StatusDown
+	}
+
+	for _, res := range r.Checks {
+		if !res.Critical || res.Status == StatusUp {
+			continue
+		}
+
+		if name == "" || len(res.services) == 0 || contains(res.services, name) {
+			return StatusDown
+		}
+	}
+
+	return StatusUp
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckOption is a functional option for registering checks.
+type CheckOption func(*check)
+
+// WithTimeout sets the maximum duration of a check. A check that exceeds it is reported as down.
+func WithTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// NonCritical marks a check as non critical: its failures are reported but do not change the overall status.
+func NonCritical() CheckOption {
+	return func(c *check) {
+		c.critical = false
+	}
+}
+
+// Liveness includes the check in the liveness report, besides the readiness report.
+// Only checks whose failure requires restarting the process should use it.
+func Liveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+// ForServices restricts the impact of the check to the given gRPC service names. By default, a
+// failing check marks all services as not serving.
+func ForServices(names ...string) CheckOption {
+	return func(c *check) {
+		c.services = names
+	}
+}
@@ -0,0 +1,105 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/health"
+)
+
+func ok(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused") }
+
+func TestReadiness(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register("db", health.CheckerFunc(ok))
+	r.Register("cache", health.CheckerFunc(failing), health.NonCritical())
+
+	report := r.Readiness(context.Background())
+	assert.True(t, report.Healthy())
+	assert.Equal(t, health.StatusUp, report.Checks["db"].Status)
+	assert.Equal(t, health.StatusDown, report.Checks["cache"].Status)
+	assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+
+	r.Register("db", health.CheckerFunc(failing))
+
+	report = r.Readiness(context.Background())
+	assert.False(t, report.Healthy())
+}
+
+func TestLivenessOnlyRunsLivenessChecks(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register("db", health.CheckerFunc(failing))
+	r.Register("deadlock", health.CheckerFunc(ok), health.Liveness())
+
+	report := r.Liveness(context.Background())
+	assert.True(t, report.Healthy())
+	assert.Len(t, report.Checks, 1)
+	assert.Contains(t, report.Checks, "deadlock")
+}
+
+func TestTimeout(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register("slow", health.CheckerFunc(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}), health.WithTimeout(10*time.Millisecond))
+
+	report := r.Readiness(context.Background())
+	assert.False(t, report.Healthy())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestTimeoutIgnoredContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	r := health.NewRegistry()
+	r.Register("stuck", health.CheckerFunc(func(context.Context) error {
+		<-block
+		return nil
+	}), health.WithTimeout(10*time.Millisecond))
+
+	start := time.Now()
+	report := r.Readiness(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, health.StatusDown, report.Checks["stuck"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+}
+
+func TestServiceStatus(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register("payments-api", health.CheckerFunc(failing), health.ForServices("billing.Billing"))
+
+	report := r.Readiness(context.Background())
+	assert.Equal(t, health.StatusDown, report.Service(""))
+	assert.Equal(t, health.StatusDown, report.Service("billing.Billing"))
+	assert.Equal(t, health.StatusUp, report.Service("users.Users"))
+}
+
+func TestShutdown(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register("db", health.CheckerFunc(ok))
+	r.Shutdown()
+
+	report := r.Readiness(context.Background())
+	assert.False(t, report.Healthy())
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"unavailable","error":"shutting down","checks":{"db":{"status":"ok","duration":"`+report.Checks["db"].Duration+`","critical":true}}}`, string(data))
+
+	assert.True(t, r.Liveness(context.Background()).Healthy())
+}
@@ -0,0 +1,216 @@
+// Package httpclient builds instrumented HTTP clients, the HTTP counterpart of scrpc.Dial: requests are
+// traced, measured, retried, and carry the id of the request being served.
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/breaker"
+	"github.com/starclusterteam/go-starbox/tracing"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+type options struct {
+	transport http.RoundTripper
+	tracer    opentracing.Tracer
+	timeout   time.Duration
+	retry     retryPolicy
+	metrics   *clientMetrics
+	breaker   *breaker.Group
+}
+
+// Option represents a functional option for New.
+type Option func(*options)
+
+// WithTransport sets the transport sending the requests. Defaults to http.DefaultTransport.
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = t
+	}
+}
+
+// WithTracer can be used to override the default tracer.
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithTimeout sets the timeout of each attempt of a request, including reading the response body.
+// Use a context deadline to limit the total time of a request, retries included. No timeout by default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithCircuitBreaker adds a circuit breaker per host to the client. While the breaker of a host is open,
+// its requests fail with an error matching breaker.ErrOpen without being sent or retried.
+func WithCircuitBreaker(opts ...breaker.Option) Option {
+	return func(o *options) {
+		o.breaker = breaker.NewGroup("http", opts...)
+	}
+}
+
+// New returns an HTTP client that:
+//   - creates a client span for each request, child of the span of the request context, and injects it
+//     in the request headers;
+//   - reports the outgoing_http_requests_total counter and the outgoing_http_request_latency_milliseconds
+//     histogram per host and route (see ContextWithRoute);
+//   - sets the X-Request-Id header to the id of the request being served (see web.RequestIDFromContext);
+//   - retries the failed requests when enabled with WithRetry;
+//   - stops sending requests to failing hosts when enabled with WithCircuitBreaker.
+func New(opts ...Option) *http.Client {
+	o := options{
+		transport: http.DefaultTransport,
+		tracer:    tracing.Tracer,
+		retry:     defaultRetryPolicy(),
+		metrics:   defaultClientMetrics,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.breaker != nil {
+		o.transport = &breaker.Transport{Group: o.breaker, Base: o.transport}
+	}
+
+	return &http.Client{Transport: &transport{options: o}}
+}
+
+type routeKey struct{}
+
+// ContextWithRoute returns a context labelling the requests made with it with the given route template,
+// e.g. "/api/v1/users/{id}", in metrics and traces. Requests without route are labelled "unknown" in
+// metrics.
+func ContextWithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+func routeFromContext(ctx context.Context) string {
+	if route, ok := ctx.Value(routeKey{}).(string); ok && route != "" {
+		return route
+	}
+
+	return "unknown"
+}
+
+// spanName returns the name of the span of a request, e.g. "GET api.example.com /users/{id}", without
+// the route if none is set.
+func spanName(r *http.Request) string {
+	if route, ok := r.Context().Value(routeKey{}).(string); ok && route != "" {
+		return fmt.Sprintf("%s %s %s", r.Method, r.URL.Host, route)
+	}
+
+	return fmt.Sprintf("%s %s", r.Method, r.URL.Host)
+}
+
+type transport struct {
+	options
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, t.tracer, spanName(r))
+	defer span.Finish()
+
+	r = r.Clone(ctx)
+	if id := web.RequestIDFromContext(ctx); id != "" && r.Header.Get("X-Request-Id") == "" {
+		r.Header.Set("X-Request-Id", id)
+	}
+
+	if _, err := web.TraceableRequestFunc(t.tracer)(span, r); err != nil {
+		span.LogKV("event", "error", "message", err.Error())
+	}
+
+	resp, attempts, err := t.retry.do(r, t.attempt)
+
+	span.SetTag("http.attempts", attempts)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+		return nil, err
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		ext.Error.Set(span, true)
+	}
+
+	return resp, nil
+}
+
+// attempt sends the request once, applying the attempt timeout and reporting the metrics.
+func (t *transport) attempt(r *http.Request) (*http.Response, error) {
+	var cancel context.CancelFunc = func() {}
+	if t.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(r.Context(), t.timeout)
+		r = r.WithContext(ctx)
+	}
+
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(r)
+	t.metrics.observe(r, resp, time.Since(start))
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The attempt context must stay alive until the body is read.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// parseRetryAfter parses the Retry-After header, given in seconds or as an HTTP date.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// drain reads the rest of the body, so the connection can be reused, and closes it.
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+var errBodyNotRewindable = errors.New("request body can't be sent again")
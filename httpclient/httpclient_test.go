@@ -0,0 +1,242 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/breaker"
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+func TestTracingAndRequestID(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer server.Close()
+
+	tracer := mocktracer.New()
+	client := New(WithTracer(tracer))
+
+	parent := tracer.StartSpan("incoming")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	ctx = web.ContextWithRequestID(ctx, "request-1")
+	ctx = ContextWithRoute(ctx, "/users/{id}")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/users/1", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.Finish()
+
+	assert.Equal(t, "request-1", headers.Get("X-Request-Id"))
+	assert.NotEmpty(t, headers.Get("Mockpfx-Ids-Traceid"))
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 2)
+
+	u, _ := url.Parse(server.URL)
+	assert.Equal(t, "GET "+u.Host+" /users/{id}", spans[0].OperationName)
+	assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	assert.Equal(t, uint16(200), spans[0].Tag("http.status_code"))
+	assert.Equal(t, 1, spans[0].Tag("http.attempts"))
+
+	// The requests without route are named after their host only.
+	resp, err = client.Get(server.URL + "/users/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	spans = tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "GET "+u.Host, spans[2].OperationName)
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 4)
+		n, _ := r.Body.Read(body)
+		assert.Equal(t, "ping", string(body[:n]))
+
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("pong"))
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithRetry(3, WithBackoff(time.Millisecond, 10*time.Millisecond)))
+
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader("ping"))
+	require.NoError(t, err)
+	req.Header.Set("Idempotency-Key", "key-1")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestRetryLimits(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/later" {
+			w.Header().Set("Retry-After", "3600")
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(WithRetry(2, WithBackoff(time.Millisecond, time.Millisecond)))
+
+	tests := []struct {
+		method, path string
+		calls        int32
+	}{
+		{"GET", "/", 3},
+		{"POST", "/", 1},
+		{"GET", "/later", 1},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+
+		req, err := http.NewRequest(tt.method, server.URL+tt.path, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, tt.calls, atomic.LoadInt32(&calls), tt.method+" "+tt.path)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	resp, err := New(WithTimeout(50 * time.Millisecond)).Get(server.URL)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+
+	atomic.StoreInt32(&calls, 0)
+	client := New(WithTimeout(50*time.Millisecond), WithRetry(1, WithBackoff(time.Millisecond, time.Millisecond)))
+
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	metrics := newClientMetrics()
+	client := New(func(o *options) { o.metrics = metrics })
+
+	req, err := http.NewRequestWithContext(ContextWithRoute(context.Background(), "/users/{id}"), "GET", server.URL+"/users/1", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	u, _ := url.Parse(server.URL)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.totalRequests.WithLabelValues(u.Host, "/users/{id}", "GET", "404", "4xx")))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.requestLatency))
+}
+
+func TestDecodeJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			web.WriteJSON(w, http.StatusOK, map[string]string{"name": "starbox"})
+		case "/typed":
+			web.HandleError(w, r, errors.NotFound("project not found").WithCode(4040))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+
+	resp, err := client.Get(server.URL + "/ok")
+	require.NoError(t, err)
+
+	var v map[string]string
+	require.NoError(t, DecodeJSON(resp, &v))
+	assert.Equal(t, "starbox", v["name"])
+
+	resp, err = client.Get(server.URL + "/typed")
+	require.NoError(t, err)
+
+	err = DecodeJSON(resp, &v)
+	assert.Equal(t, errors.KindNotFound, errors.KindOf(err))
+	assert.True(t, errors.Is(err, errors.NotFound("project not found").WithCode(4040)))
+
+	resp, err = client.Get(server.URL + "/proxy")
+	require.NoError(t, err)
+
+	err = CheckResponse(resp)
+	assert.Equal(t, errors.KindUnavailable, errors.KindOf(err))
+	assert.Contains(t, err.Error(), "Bad Gateway")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRetry(5, WithBackoff(time.Millisecond, time.Millisecond)),
+		WithCircuitBreaker(breaker.WithTripPolicy(breaker.ConsecutiveFailures(2))),
+	)
+
+	_, err := client.Get(server.URL)
+	assert.True(t, errors.Is(err, breaker.ErrOpen))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+)
+
+var defaultClientMetrics = newClientMetrics()
+
+func init() {
+	if config.Bool(envvar.PrometheusEnabled, false) {
+		defaultClientMetrics.mustRegister()
+	}
+}
+
+type clientMetrics struct {
+	totalRequests  *prometheus.CounterVec
+	requestLatency *prometheus.HistogramVec
+}
+
+func newClientMetrics() *clientMetrics {
+	var m clientMetrics
+	m.totalRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outgoing_http_requests_total",
+			Help: "The number of outgoing HTTP requests.",
+		},
+		[]string{"host", "route", "method", "status", "statusClass"},
+	)
+
+	m.requestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "outgoing_http_request_latency_milliseconds",
+			Help:    "A histogram of the response latency for outgoing HTTP requests in milliseconds.",
+			Buckets: []float64{50, 100, 200, 400, 800, 1600, 3200},
+		},
+		[]string{"host", "route", "method"},
+	)
+
+	return &m
+}
+
+func (m *clientMetrics) mustRegister() {
+	prometheus.MustRegister(m.totalRequests, m.requestLatency)
+}
+
+// observe reports an attempt. Attempts failing without response are reported with the "error" status.
+func (m *clientMetrics) observe(r *http.Request, resp *http.Response, d time.Duration) {
+	route := routeFromContext(r.Context())
+
+	status, statusClass := "error", "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+		statusClass = strconv.Itoa(resp.StatusCode/100) + "xx"
+	}
+
+	m.totalRequests.With(prometheus.Labels{
+		"host":        r.URL.Host,
+		"route":       route,
+		"method":      r.Method,
+		"status":      status,
+		"statusClass": statusClass,
+	}).Inc()
+
+	m.requestLatency.With(prometheus.Labels{
+		"host":   r.URL.Host,
+		"route":  route,
+		"method": r.Method,
+	}).Observe(float64(d / time.Millisecond))
+}
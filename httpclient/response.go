@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+// maxErrorBodySize limits the size of the error bodies read by CheckResponse.
+const maxErrorBodySize = 1 << 20
+
+// CheckResponse returns nil if the response has a 2xx status. Otherwise it reads and closes the body and
+// returns an *errors.Error decoded from the web.ErrorResponse it contains (see web.ParseError), or an
+// error of the kind matching the status if the body is not an error response.
+func CheckResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read response body with status %d", resp.StatusCode)
+	}
+
+	if err := web.ParseError(resp.StatusCode, body); err != nil {
+		if _, ok := errors.AsError(err); ok {
+			return err
+		}
+	}
+
+	return errors.E(errors.KindFromHTTPStatus(resp.StatusCode), http.StatusText(resp.StatusCode))
+}
+
+// DecodeJSON checks the response with CheckResponse and decodes its JSON body into v. The body is closed.
+// Responses without content leave v untouched.
+func DecodeJSON(resp *http.Response, v interface{}) error {
+	if err := CheckResponse(resp); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && err != io.EOF {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	return nil
+}
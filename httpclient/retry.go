@@ -0,0 +1,171 @@
+package httpclient
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/starclusterteam/go-starbox/breaker"
+	"github.com/starclusterteam/go-starbox/errors"
+)
+
+type retryPolicy struct {
+	maxRetries    int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
+	statusCodes   map[int]bool
+	allMethods    bool
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		minBackoff:    100 * time.Millisecond,
+		maxBackoff:    5 * time.Second,
+		maxRetryAfter: time.Minute,
+		statusCodes: map[int]bool{
+			http.StatusTooManyRequests:    true,
+			http.StatusBadGateway:         true,
+			http.StatusServiceUnavailable: true,
+			http.StatusGatewayTimeout:     true,
+		},
+	}
+}
+
+// RetryOption configures the retries enabled with WithRetry.
+type RetryOption func(*retryPolicy)
+
+// WithBackoff sets the bounds of the exponential backoff between retries. Defaults to 100ms and 5s.
+func WithBackoff(min, max time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.minBackoff = min
+		p.maxBackoff = max
+	}
+}
+
+// WithMaxRetryAfter sets the longest Retry-After delay honored. Responses asking to wait longer are not
+// retried. Defaults to 1 minute.
+func WithMaxRetryAfter(d time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.maxRetryAfter = d
+	}
+}
+
+// WithRetryStatusCodes sets the response status codes retried. Defaults to 429, 502, 503 and 504.
+func WithRetryStatusCodes(codes ...int) RetryOption {
+	return func(p *retryPolicy) {
+		p.statusCodes = make(map[int]bool, len(codes))
+		for _, c := range codes {
+			p.statusCodes[c] = true
+		}
+	}
+}
+
+// WithRetryAllMethods retries requests whatever their method. By default, only idempotent requests are
+// retried: GET, HEAD, OPTIONS, PUT and DELETE requests, and requests with an Idempotency-Key header.
+func WithRetryAllMethods() RetryOption {
+	return func(p *retryPolicy) {
+		p.allMethods = true
+	}
+}
+
+// WithRetry retries the requests failing with a network error or a retryable status code up to maxRetries
+// times, waiting with an exponential backoff and full jitter between attempts, or for the duration given by
+// the Retry-After header of the response. Requests with a body are only retried if their GetBody is set,
+// which is the case for requests created by http.NewRequest with common body types.
+func WithRetry(maxRetries int, opts ...RetryOption) Option {
+	return func(o *options) {
+		o.retry.maxRetries = maxRetries
+		for _, opt := range opts {
+			opt(&o.retry)
+		}
+	}
+}
+
+// do sends the request with attempt until it succeeds or can't be retried. It returns the number of attempts.
+func (p retryPolicy) do(r *http.Request, attempt func(*http.Request) (*http.Response, error)) (*http.Response, int, error) {
+	retryable := p.maxRetries > 0 && p.retryableRequest(r)
+
+	for n := 0; ; n++ {
+		if n > 0 {
+			if err := rewind(r); err != nil {
+				return nil, n, err
+			}
+		}
+
+		resp, err := attempt(r)
+		if !retryable || n >= p.maxRetries || r.Context().Err() != nil || errors.Is(err, breaker.ErrOpen) {
+			return resp, n + 1, err
+		}
+
+		wait := p.backoff(n)
+		if err == nil {
+			if !p.statusCodes[resp.StatusCode] {
+				return resp, n + 1, nil
+			}
+
+			if retryAfter, ok := parseRetryAfter(resp, time.Now()); ok {
+				if retryAfter > p.maxRetryAfter {
+					return resp, n + 1, nil
+				}
+				wait = retryAfter
+			}
+
+			drain(resp)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, n + 1, r.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p retryPolicy) retryableRequest(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+
+	if p.allMethods || r.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns a random duration between 0 and min(maxBackoff, minBackoff * 2^n).
+func (p retryPolicy) backoff(n int) time.Duration {
+	ceiling := math.Min(float64(p.maxBackoff), float64(p.minBackoff)*math.Pow(2, float64(n)))
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func rewind(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if r.GetBody == nil {
+		return errBodyNotRewindable
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return errors.Wrap(err, "failed to rewind request body")
+	}
+
+	r.Body = body
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package filewatch reloads files when they change, for the packages watching configuration files and
+// certificates.
+package filewatch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Delay is the time waited after a change before reloading, so that the files written one after the
+// other, e.g. a certificate and its key, are reloaded together.
+const Delay = 100 * time.Millisecond
+
+// Watcher calls a reload function when watched directories change. The directories are watched rather
+// than the files, which are often symlinks replaced on update, e.g. for mounted ConfigMaps and Secrets.
+type Watcher struct {
+	fsw    *fsnotify.Watcher
+	done   chan struct{}
+	closed sync.Once
+}
+
+// New watches the directories, calling reload once the changes stop for Delay, and onError with the
+// errors of the watcher.
+func New(dirs []string, reload func(), onError func(error)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create file watcher")
+	}
+
+	watched := make(map[string]bool)
+	for _, dir := range dirs {
+		if watched[dir] {
+			continue
+		}
+		watched[dir] = true
+
+		if err := fsw.Add(dir); err != nil {
+			fsw.Close()
+			return nil, errors.Wrapf(err, "failed to watch %s", dir)
+		}
+	}
+
+	w := &Watcher{fsw: fsw, done: make(chan struct{})}
+	go w.run(reload, onError)
+
+	return w, nil
+}
+
+// Close stops watching the directories.
+func (w *Watcher) Close() error {
+	var err error
+	w.closed.Do(func() {
+		close(w.done)
+		err = w.fsw.Close()
+	})
+
+	return err
+}
+
+func (w *Watcher) run(reload func(), onError func(error)) {
+	// The timer is reset by every change, so the reload waits for the last one.
+	timer := time.NewTimer(Delay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(Delay)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+
+			onError(err)
+		case <-timer.C:
+			reload()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// ReloadCounter counts the reloads of watched files by result, "success" or "failure".
+type ReloadCounter struct {
+	*prometheus.CounterVec
+}
+
+// NewReloadCounter returns a counter with the given labels followed by the result label.
+func NewReloadCounter(name, help string, labels ...string) ReloadCounter {
+	return ReloadCounter{prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: name, Help: help},
+		append(labels[:len(labels):len(labels)], "result"),
+	)}
+}
+
+// Reloaded counts a reload with the values of the labels of the counter, except the result.
+func (c ReloadCounter) Reloaded(success bool, labels ...string) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+
+	c.WithLabelValues(append(labels[:len(labels):len(labels)], result)...).Inc()
+}
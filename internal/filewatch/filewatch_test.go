@@ -0,0 +1,46 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+
+	var reloads atomic.Int32
+	w, err := New([]string{dir, dir}, func() { reloads.Add(1) }, func(err error) { t.Error(err) })
+	require.NoError(t, err)
+	defer w.Close()
+
+	// The changes made at once are reloaded together.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("cert"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.key"), []byte("key"), 0600))
+	assert.Eventually(t, func() bool { return reloads.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	time.Sleep(3 * Delay)
+	assert.EqualValues(t, 1, reloads.Load())
+
+	// The reload waits for the changes to stop, even if they take longer than Delay.
+	for i := 0; i < 4; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte{byte(i)}, 0600))
+		time.Sleep(Delay / 2)
+	}
+	assert.EqualValues(t, 1, reloads.Load())
+	assert.Eventually(t, func() bool { return reloads.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	time.Sleep(3 * Delay)
+	assert.EqualValues(t, 2, reloads.Load())
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	_, err = New([]string{filepath.Join(dir, "missing")}, func() {}, func(error) {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	zipkin "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	"github.com/sirupsen/logrus"
+	"github.com/uber/jaeger-client-go"
+)
+
+type loggerKey struct{}
+
+// WithContext returns a copy of ctx holding the logger, which is returned by FromContext.
+func WithContext(ctx context.Context, l Interface) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx by WithContext, or the base logger. If ctx holds an
+// opentracing span, the logger has its trace_id and span_id fields.
+func FromContext(ctx context.Context) Interface {
+	l, ok := ctx.Value(loggerKey{}).(Interface)
+	if !ok {
+		l = baseLogger
+	}
+
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return l
+	}
+
+	traceID, spanID := spanIDs(span.Context())
+	if traceID != "" {
+		l = l.With("trace_id", traceID).With("span_id", spanID)
+	}
+
+	return l
+}
+
+// otherSpanIDs returns the trace and span ids of the span contexts of other tracers, e.g. the mock tracer
+// of the tests.
+var otherSpanIDs func(sc opentracing.SpanContext) (traceID, spanID string)
+
+// spanIDs returns the trace and span ids of the span contexts of the supported tracers.
+func spanIDs(sc opentracing.SpanContext) (traceID, spanID string) {
+	switch sc := sc.(type) {
+	case jaeger.SpanContext:
+		return sc.TraceID().String(), sc.SpanID().String()
+	case zipkin.SpanContext:
+		return sc.TraceID.String(), sc.ID.String()
+	default:
+		if otherSpanIDs != nil {
+			return otherSpanIDs(sc)
+		}
+		return "", ""
+	}
+}
+
+// DebugCtx logs a message at DEBUG level with the logger of ctx, see FromContext. The fields are given
+// as alternating keys and values, e.g. DebugCtx(ctx, "cache miss", "key", key).
+func DebugCtx(ctx context.Context, msg string, fields ...interface{}) {
+	withFields(FromContext(ctx), fields).Debug(msg)
+}
+
+// InfoCtx logs a message at INFO level with the logger of ctx, see DebugCtx.
+func InfoCtx(ctx context.Context, msg string, fields ...interface{}) {
+	withFields(FromContext(ctx), fields).Info(msg)
+}
+
+// WarningCtx logs a message at WARN level with the logger of ctx, see DebugCtx.
+func WarningCtx(ctx context.Context, msg string, fields ...interface{}) {
+	withFields(FromContext(ctx), fields).Warn(msg)
+}
+
+// ErrorCtx logs a message at ERROR level with the logger of ctx, see DebugCtx.
+func ErrorCtx(ctx context.Context, msg string, fields ...interface{}) {
+	withFields(FromContext(ctx), fields).Error(msg)
+}
+
+// badKey is the key of a value given without key.
+const badKey = "!BADKEY"
+
+func withFields(l Interface, fields []interface{}) Interface {
+	if len(fields) == 0 {
+		return l
+	}
+
+	f := make(logrus.Fields, (len(fields)+1)/2)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			f[badKey] = fields[i]
+			break
+		}
+
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+		f[key] = fields[i+1]
+	}
+
+	return logger{l.WithFields(f)}
+}
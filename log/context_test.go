@@ -0,0 +1,60 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.LogrusLogger()
+	l.Out = &buf
+
+	formatter := l.Formatter
+	l.SetFormatter(&logrus.JSONFormatter{})
+	defer l.SetFormatter(formatter)
+
+	decode := func() map[string]interface{} {
+		data := make(map[string]interface{})
+		require.NoError(t, json.NewDecoder(&buf).Decode(&data))
+		buf.Reset()
+		return data
+	}
+
+	// Without logger, the base logger is used.
+	log.InfoCtx(context.Background(), "test", "key", 3, "lonely")
+	data := decode()
+	assert.Equal(t, "test", data["msg"])
+	assert.EqualValues(t, 3, data["key"])
+	assert.Equal(t, "lonely", data["!BADKEY"])
+	assert.NotContains(t, data, "trace_id")
+
+	ctx := log.WithContext(context.Background(), log.Logger().With("request_id", "request-1"))
+	log.ErrorCtx(ctx, "failure")
+	data = decode()
+	assert.Equal(t, "request-1", data["request_id"])
+	assert.Equal(t, "error", data["level"])
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	defer span.Finish()
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	log.FromContext(ctx).Info("traced")
+	data = decode()
+	sc := span.Context().(mocktracer.MockSpanContext)
+	assert.Equal(t, "request-1", data["request_id"])
+	assert.Equal(t, strconv.Itoa(sc.TraceID), data["trace_id"])
+	assert.Equal(t, strconv.Itoa(sc.SpanID), data["span_id"])
+}
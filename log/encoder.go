@@ -0,0 +1,140 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Formats accepted by LOG_FORMAT and WithFormat.
+const (
+	// FormatJSON is JSON with the time, level and msg keys.
+	FormatJSON = "json"
+	// FormatGCP is JSON following the conventions of Google Cloud Logging: time, severity and message.
+	FormatGCP = "gcp"
+	// FormatECS is JSON following the Elastic Common Schema: @timestamp, log.level and message.
+	FormatECS = "ecs"
+	// FormatDatadog is JSON following the conventions of Datadog: timestamp, status and message.
+	FormatDatadog = "datadog"
+	// FormatLogfmt is key=value pairs.
+	FormatLogfmt = "logfmt"
+	// FormatConsole is human readable and colored, for development.
+	FormatConsole = "console"
+	// FormatText is the logrus text format, colored when writing to a terminal.
+	FormatText = "text"
+)
+
+// JSONEncoder formats the entries as JSON objects, one per line, with configurable keys.
+type JSONEncoder struct {
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+	// TimeFormat is the layout of the time, time.RFC3339 if empty.
+	TimeFormat string
+	// LevelFormat returns the value of a level, its name if nil.
+	LevelFormat func(logrus.Level) string
+	// StaticFields are added to all the entries.
+	StaticFields map[string]interface{}
+}
+
+// NewEncoder returns the encoder of the given format, see the Format constants.
+func NewEncoder(format string) (logrus.Formatter, error) {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return &JSONEncoder{TimeKey: "time", LevelKey: "level", MessageKey: "msg"}, nil
+	case FormatGCP:
+		return &JSONEncoder{
+			TimeKey:     "time",
+			LevelKey:    "severity",
+			MessageKey:  "message",
+			TimeFormat:  time.RFC3339Nano,
+			LevelFormat: gcpSeverity,
+		}, nil
+	case FormatECS:
+		return &JSONEncoder{
+			TimeKey:      "@timestamp",
+			LevelKey:     "log.level",
+			MessageKey:   "message",
+			TimeFormat:   time.RFC3339Nano,
+			StaticFields: map[string]interface{}{"ecs.version": "1.6.0"},
+		}, nil
+	case FormatDatadog:
+		return &JSONEncoder{
+			TimeKey:    "timestamp",
+			LevelKey:   "status",
+			MessageKey: "message",
+			TimeFormat: time.RFC3339Nano,
+		}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}, nil
+	case FormatConsole:
+		return &logrus.TextFormatter{ForceColors: true, FullTimestamp: true}, nil
+	case FormatText:
+		return &logrus.TextFormatter{}, nil
+	default:
+		return nil, errors.Errorf("unknown log format %q", format)
+	}
+}
+
+func gcpSeverity(level logrus.Level) string {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return "DEBUG"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.WarnLevel:
+		return "WARNING"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	default:
+		return "ALERT"
+	}
+}
+
+// Format implements logrus.Formatter. Fields clashing with the standard keys are prefixed with "fields.".
+func (e *JSONEncoder) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+len(e.StaticFields)+3)
+	for k, v := range e.StaticFields {
+		data[k] = v
+	}
+
+	for k, v := range entry.Data {
+		if k == e.TimeKey || k == e.LevelKey || k == e.MessageKey {
+			k = "fields." + k
+		}
+
+		if err, ok := v.(error); ok {
+			// Errors would be marshaled as empty objects.
+			v = err.Error()
+		}
+		data[k] = v
+	}
+
+	timeFormat := e.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339
+	}
+	data[e.TimeKey] = entry.Time.Format(timeFormat)
+
+	level := entry.Level.String()
+	if e.LevelFormat != nil {
+		level = e.LevelFormat(entry.Level)
+	}
+	data[e.LevelKey] = level
+	data[e.MessageKey] = entry.Message
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(data); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal log entry")
+	}
+
+	return buf.Bytes(), nil
+}
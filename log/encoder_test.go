@@ -0,0 +1,67 @@
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+func TestEncoders(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"error":   errors.New("boom"),
+		"message": "clash",
+	})
+	entry.Time = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry.Level = logrus.WarnLevel
+	entry.Message = "test"
+
+	tests := []struct {
+		format string
+		want   map[string]interface{}
+	}{
+		{log.FormatJSON, map[string]interface{}{
+			"time": "2024-01-02T03:04:05Z", "level": "warning", "msg": "test", "message": "clash",
+		}},
+		{log.FormatGCP, map[string]interface{}{
+			"time": "2024-01-02T03:04:05Z", "severity": "WARNING", "message": "test", "fields.message": "clash",
+		}},
+		{log.FormatECS, map[string]interface{}{
+			"@timestamp": "2024-01-02T03:04:05Z", "log.level": "warning", "message": "test", "ecs.version": "1.6.0",
+		}},
+		{log.FormatDatadog, map[string]interface{}{
+			"timestamp": "2024-01-02T03:04:05Z", "status": "warning", "message": "test",
+		}},
+	}
+
+	for _, tt := range tests {
+		encoder, err := log.NewEncoder(tt.format)
+		require.NoError(t, err)
+
+		b, err := encoder.Format(entry)
+		require.NoError(t, err)
+
+		data := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(b, &data), tt.format)
+		assert.Equal(t, "boom", data["error"], tt.format)
+		for k, v := range tt.want {
+			assert.Equal(t, v, data[k], "%s: %s", tt.format, k)
+		}
+	}
+
+	encoder, err := log.NewEncoder(log.FormatLogfmt)
+	require.NoError(t, err)
+	b, err := encoder.Format(entry)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), `time="2024-01-02T03:04:05Z" level=warning msg=test error=boom`), string(b))
+
+	_, err = log.NewEncoder("xml")
+	assert.Error(t, err)
+}
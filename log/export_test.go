@@ -0,0 +1,17 @@
+package log
+
+import (
+	"strconv"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func init() {
+	otherSpanIDs = func(sc opentracing.SpanContext) (string, string) {
+		if sc, ok := sc.(mocktracer.MockSpanContext); ok {
+			return strconv.Itoa(sc.TraceID), strconv.Itoa(sc.SpanID)
+		}
+		return "", ""
+	}
+}
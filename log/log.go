@@ -1,11 +1,25 @@
 package log
 
 import (
+	"strings"
+	"time"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/starclusterteam/go-starbox/config"
 )
 
+const (
+	formatEnv               = "LOG_FORMAT"
+	outputEnv               = "LOG_OUTPUT"
+	samplingFirstEnv        = "LOG_SAMPLING_FIRST"
+	samplingThereafterEnv   = "LOG_SAMPLING_THEREAFTER"
+	samplingIntervalEnv     = "LOG_SAMPLING_INTERVAL"
+	requestSampleRateEnv    = "LOG_REQUEST_SAMPLE_RATE"
+	requestSlowThresholdEnv = "LOG_REQUEST_SLOW_THRESHOLD"
+	redactKeysEnv           = "LOG_REDACT_KEYS"
+)
+
 var baseLogger = logger{logrus.NewEntry(logrus.StandardLogger())}
 
 // Logger returns underlying logger.
@@ -19,15 +33,62 @@ func LogrusLogger() *logrus.Logger {
 }
 
 func init() {
-	level, err := logrus.ParseLevel(config.String("LOG_SEVERITY", "info"))
+	config.DefaultStore.SetErrorHandler(func(err error) {
+		baseLogger.Errorf("Dynamic config error: %v", err)
+	})
+
+	// The level can be changed at runtime, see config.Store.
+	level, err := config.NewValue(config.DefaultStore, "LOG_SEVERITY", logrus.InfoLevel, logrus.ParseLevel)
 	if err != nil {
 		logrus.Fatalf("Failed to parse level from LOG_SEVERITY environment variable: %v", err)
 	}
 
-	logrus.SetLevel(level)
-	if !config.FetchGoEnv().Development {
-		// Use structured formatter.
-		logrus.SetFormatter(&logrus.JSONFormatter{})
+	logrus.SetLevel(level.Get())
+	level.Subscribe(func(old, new logrus.Level) {
+		logrus.SetLevel(new)
+		baseLogger.Infof("Log level changed from %s to %s", old, new)
+	})
+
+	format := FormatJSON
+	if config.FetchGoEnv().Development {
+		format = FormatText
+	}
+
+	logrus.AddHook(redactHook{})
+	redactor := NewRedactor(
+		RedactKeys(DefaultRedactedKeys...),
+		RedactKeys(strings.Split(config.String(redactKeysEnv, ""), ",")...),
+		RedactPatterns(DefaultRedactedPatterns...),
+	)
+
+	opts := []Option{WithFormat(config.String(formatEnv, format)), WithRedactor(redactor)}
+	if output := config.String(outputEnv, ""); output != "" {
+		opts = append(opts, WithOutput(strings.Split(output, ",")...))
+	}
+
+	if err := Configure(opts...); err != nil {
+		logrus.Fatalf("Failed to configure logger from %s and %s environment variables: %v", formatEnv, outputEnv, err)
+	}
+
+	if first := config.Int(samplingFirstEnv, 0); first > 0 {
+		interval, err := config.Duration(samplingIntervalEnv, time.Second)
+		if err != nil {
+			logrus.Fatalf("Failed to configure log sampling: %v", err)
+		}
+
+		policy := SamplingPolicy{Interval: interval, First: first, Thereafter: config.Int(samplingThereafterEnv, 0)}
+		if err := Configure(WithSampling(policy)); err != nil {
+			logrus.Fatalf("Failed to configure log sampling: %v", err)
+		}
+	}
+
+	if rate := config.Float(requestSampleRateEnv, -1); rate >= 0 {
+		slow, err := config.Duration(requestSlowThresholdEnv, 0)
+		if err != nil {
+			logrus.Fatalf("Failed to configure request log sampling: %v", err)
+		}
+
+		DefaultRequestSampling = &RequestSampling{Rate: rate, SlowThreshold: slow}
 	}
 }
 
@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/starclusterteam/go-starbox/config"
 	"github.com/starclusterteam/go-starbox/log"
 )
 
+// The loggers can be used as logrus loggers.
+var _ logrus.FieldLogger = log.Logger()
+
 func TestWith(t *testing.T) {
 	var buf bytes.Buffer
 	log.LogrusLogger().Out = &buf
@@ -40,3 +45,17 @@ func TestWith(t *testing.T) {
 	assert.NotContains(t, data, "key")
 	assert.NotContains(t, data, "field")
 }
+
+func TestDynamicLevel(t *testing.T) {
+	level := log.LogrusLogger().Level
+	defer config.DefaultStore.Unset("LOG_SEVERITY")
+
+	config.DefaultStore.Set("LOG_SEVERITY", "debug")
+	assert.Equal(t, logrus.DebugLevel, log.LogrusLogger().Level)
+
+	config.DefaultStore.Set("LOG_SEVERITY", "loud")
+	assert.Equal(t, logrus.DebugLevel, log.LogrusLogger().Level)
+
+	config.DefaultStore.Unset("LOG_SEVERITY")
+	assert.Equal(t, level, log.LogrusLogger().Level)
+}
@@ -0,0 +1,264 @@
+package log
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type options struct {
+	format    string
+	formatter logrus.Formatter
+	outputs   []string
+	writers   []io.Writer
+	sampling  map[logrus.Level]SamplingPolicy
+	// redactor is set if WithRedactor is given, possibly to nil.
+	redactor **Redactor
+}
+
+// Option is a functional option for Configure.
+type Option func(*options)
+
+// WithFormat sets the format of the entries, see the Format constants.
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithFormatter sets the formatter of the entries, e.g. a JSONEncoder with custom keys. It takes
+// precedence over WithFormat.
+func WithFormatter(f logrus.Formatter) Option {
+	return func(o *options) {
+		o.formatter = f
+	}
+}
+
+// WithOutput adds outputs given by their name:
+//
+//   - "stdout" and "stderr",
+//   - "file:///var/log/app.log?max_size=100MB&max_age=168h&max_backups=5", a RotatingFile whose options
+//     are all optional,
+//   - "syslog" or "syslog:<tag>", sending the entries with their level to the local syslog daemon.
+func WithOutput(outputs ...string) Option {
+	return func(o *options) {
+		o.outputs = append(o.outputs, outputs...)
+	}
+}
+
+// WithWriter adds a writer to the outputs.
+func WithWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.writers = append(o.writers, w)
+	}
+}
+
+var (
+	sinksMu sync.Mutex
+	// sinkClosers and sinkHooks are the files and syslog connections opened by the last Configure.
+	sinkClosers []io.Closer
+	sinkHooks   []logrus.Hook
+)
+
+// Configure sets the format, the outputs, the sampling and the redaction of the logger, which are otherwise
+// set from the LOG_FORMAT, LOG_OUTPUT (a comma separated list of outputs), LOG_SAMPLING_FIRST,
+// LOG_SAMPLING_THEREAFTER, LOG_SAMPLING_INTERVAL and LOG_REDACT_KEYS environment variables. The settings not given are
+// left unchanged. The outputs opened by the previous configuration are closed when replaced.
+func Configure(opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	formatter := o.formatter
+	if formatter == nil && o.format != "" {
+		var err error
+		formatter, err = NewEncoder(o.format)
+		if err != nil {
+			return err
+		}
+	}
+
+	l := LogrusLogger()
+	if formatter == nil && o.sampling != nil {
+		if _, ok := l.Formatter.(samplingFormatter); !ok {
+			formatter = l.Formatter
+		}
+	}
+	if formatter != nil {
+		l.SetFormatter(samplingFormatter{formatter})
+	}
+
+	if o.sampling != nil {
+		currentSampler.Store(updateSampler(o.sampling))
+	}
+
+	if o.redactor != nil {
+		currentRedactor.Store(*o.redactor)
+	}
+
+	if len(o.outputs) == 0 && len(o.writers) == 0 {
+		return nil
+	}
+
+	var (
+		writers []io.Writer
+		closers []io.Closer
+		hooks   []logrus.Hook
+	)
+	for _, output := range o.outputs {
+		w, hook, closer, err := openOutput(strings.TrimSpace(output))
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return errors.Wrapf(err, "invalid log output %q", output)
+		}
+
+		if w != nil {
+			writers = append(writers, w)
+		}
+		if hook != nil {
+			hooks = append(hooks, samplingHook{hook})
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+	writers = append(writers, o.writers...)
+
+	switch len(writers) {
+	case 0:
+		// Only syslog.
+		l.SetOutput(io.Discard)
+	case 1:
+		l.SetOutput(writers[0])
+	default:
+		l.SetOutput(io.MultiWriter(writers...))
+	}
+
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+
+	levelHooks := make(logrus.LevelHooks)
+	for level, hs := range l.Hooks {
+		for _, h := range hs {
+			if !containsHook(sinkHooks, h) {
+				levelHooks[level] = append(levelHooks[level], h)
+			}
+		}
+	}
+	for _, h := range hooks {
+		levelHooks.Add(h)
+	}
+	l.ReplaceHooks(levelHooks)
+
+	for _, c := range sinkClosers {
+		c.Close()
+	}
+	sinkClosers, sinkHooks = closers, hooks
+
+	return nil
+}
+
+func containsHook(hooks []logrus.Hook, h logrus.Hook) bool {
+	for _, hook := range hooks {
+		if hook == h {
+			return true
+		}
+	}
+
+	return false
+}
+
+// openOutput opens an output, which is either a writer or a hook.
+func openOutput(output string) (io.Writer, logrus.Hook, io.Closer, error) {
+	switch {
+	case output == "stdout":
+		return os.Stdout, nil, nil, nil
+	case output == "stderr":
+		return os.Stderr, nil, nil, nil
+	case output == "syslog" || strings.HasPrefix(output, "syslog:"):
+		hook, closer, err := newSyslogHook(strings.TrimPrefix(strings.TrimPrefix(output, "syslog"), ":"))
+		return nil, hook, closer, err
+	case strings.HasPrefix(output, "file://"):
+		f, err := openFileOutput(strings.TrimPrefix(output, "file://"))
+		return f, nil, f, err
+	default:
+		return nil, nil, nil, errors.New("unknown output")
+	}
+}
+
+func openFileOutput(s string) (*RotatingFile, error) {
+	path, rawQuery, _ := strings.Cut(s, "?")
+	if path == "" {
+		return nil, errors.New("missing file path")
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid file options")
+	}
+
+	var opts []RotatingFileOption
+	if v := query.Get("max_size"); v != "" {
+		size, err := parseSize(v)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithMaxSize(size))
+	}
+
+	if v := query.Get("max_age"); v != "" {
+		age, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid max_age %q", v)
+		}
+		opts = append(opts, WithMaxAge(age))
+	}
+
+	if v := query.Get("max_backups"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid max_backups %q", v)
+		}
+		opts = append(opts, WithMaxBackups(n))
+	}
+
+	return NewRotatingFile(path, opts...)
+}
+
+// parseSize parses a size in bytes, optionally with a KB, MB or GB unit.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	number, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+
+	return n * unit, nil
+}
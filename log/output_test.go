@@ -0,0 +1,40 @@
+package log_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+func TestConfigure(t *testing.T) {
+	defer func() {
+		require.NoError(t, log.Configure(log.WithFormat(log.FormatJSON), log.WithOutput("stderr")))
+	}()
+
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	require.NoError(t, log.Configure(
+		log.WithFormat(log.FormatGCP),
+		log.WithOutput("file://"+path+"?max_size=10MB&max_backups=3"),
+	))
+
+	log.Logger().With("key", "value").Info("test")
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	data := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(b, &data))
+	assert.Equal(t, "test", data["message"])
+	assert.Equal(t, "INFO", data["severity"])
+	assert.Equal(t, "value", data["key"])
+
+	assert.Error(t, log.Configure(log.WithOutput("kafka://localhost")))
+	assert.Error(t, log.Configure(log.WithOutput("file://"+path+"?max_size=big")))
+	assert.Error(t, log.Configure(log.WithFormat("xml")))
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+)
+
+var defaultLogMetrics = newLogMetrics()
+
+func init() {
+	if config.Bool(envvar.PrometheusEnabled, false) {
+		defaultLogMetrics.mustRegister()
+	}
+}
+
+type logMetrics struct {
+	drops *prometheus.CounterVec
+}
+
+func newLogMetrics() *logMetrics {
+	var m logMetrics
+	m.drops = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_entries_dropped_total",
+			Help: "The number of log entries dropped by sampling.",
+		},
+		[]string{"level", "sampler"},
+	)
+
+	return &m
+}
+
+func (m *logMetrics) mustRegister() {
+	prometheus.MustRegister(m.drops)
+}
+
+func (m *logMetrics) dropped(level logrus.Level, sampler string) {
+	m.drops.WithLabelValues(level.String(), sampler).Inc()
+}
@@ -0,0 +1,325 @@
+package log
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces the redacted values.
+const Redacted = "[REDACTED]"
+
+// Patterns of sensitive data redacted from the strings by default.
+var (
+	EmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	CreditCardPattern = regexp.MustCompile(`\b(?:4\d{3}|5[1-5]\d{2}|6011|3[47]\d{2})(?:[ \-]?\d{4}){2}[ \-]?\d{1,4}\b`)
+	JWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+)
+
+// DefaultRedactedKeys are the field keys and query params redacted by default, "s" being the signature of
+// the signed URLs.
+var DefaultRedactedKeys = []string{
+	"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token", "id_token",
+	"api_key", "apikey", "authorization", "cookie", "set-cookie", "signature", "s",
+}
+
+// DefaultRedactedPatterns are the patterns redacted by default.
+var DefaultRedactedPatterns = []*regexp.Regexp{EmailPattern, CreditCardPattern, JWTPattern}
+
+// Redactor replaces sensitive data by Redacted:
+//
+//   - the values of the fields and query params whose key is redacted, case insensitively,
+//   - the values of the key=value pairs and of the "key": value JSON members found in strings whose key
+//     is redacted,
+//   - the matches of the patterns in strings and errors,
+//   - the struct fields tagged with `log:"redact"`.
+//
+// A nil Redactor redacts nothing.
+type Redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	// pairs matches the key=value pairs of the redacted keys, and members their JSON members with a
+	// string, number, boolean or null value.
+	pairs   *regexp.Regexp
+	members *regexp.Regexp
+}
+
+// RedactorOption is a functional option for NewRedactor.
+type RedactorOption func(*Redactor)
+
+// RedactKeys redacts the fields and query params with the given keys.
+func RedactKeys(keys ...string) RedactorOption {
+	return func(r *Redactor) {
+		for _, k := range keys {
+			if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+				r.keys[k] = true
+			}
+		}
+	}
+}
+
+// RedactPatterns redacts the matches of the patterns.
+func RedactPatterns(patterns ...*regexp.Regexp) RedactorOption {
+	return func(r *Redactor) {
+		r.patterns = append(r.patterns, patterns...)
+	}
+}
+
+// NewRedactor returns a Redactor, redacting nothing without options.
+func NewRedactor(opts ...RedactorOption) *Redactor {
+	r := &Redactor{keys: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if len(r.keys) > 0 {
+		keys := make([]string, 0, len(r.keys))
+		for k := range r.keys {
+			keys = append(keys, regexp.QuoteMeta(k))
+		}
+		r.pairs = regexp.MustCompile(`(?i)\b(` + strings.Join(keys, "|") + `)=[^&\s"',;]+`)
+		r.members = regexp.MustCompile(`(?i)"(` + strings.Join(keys, "|") + `)"(\s*:\s*)(?:"(?:[^"\\]|\\.)*"|[^\s"{\[,}\]][^\s,}\]]*)`)
+	}
+
+	return r
+}
+
+// String returns s with the key=value pairs and the JSON members of the redacted keys, and the matches of
+// the patterns redacted.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	if r.pairs != nil {
+		s = r.pairs.ReplaceAllString(s, "${1}="+Redacted)
+		s = r.members.ReplaceAllString(s, `"${1}"${2}"`+Redacted+`"`)
+	}
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, Redacted)
+	}
+
+	return s
+}
+
+// URL returns u as a string with the password, the values of the redacted query params and the matches
+// of the patterns in the path and the query values redacted.
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if r == nil {
+		return u.String()
+	}
+
+	redacted := *u
+	if path := r.String(u.Path); path != u.Path {
+		redacted.Path, redacted.RawPath = path, r.String(u.EscapedPath())
+	}
+
+	if u.RawQuery != "" {
+		pairs := strings.Split(u.RawQuery, "&")
+		for i, pair := range pairs {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+
+			if k, err := url.QueryUnescape(key); err == nil && r.keys[strings.ToLower(k)] {
+				pairs[i] = key + "=" + Redacted
+				continue
+			}
+
+			if v, err := url.QueryUnescape(value); err == nil {
+				if rv := r.String(v); rv != v {
+					pairs[i] = key + "=" + strings.ReplaceAll(url.QueryEscape(rv), url.QueryEscape(Redacted), Redacted)
+				}
+			}
+		}
+		redacted.RawQuery = strings.Join(pairs, "&")
+	}
+
+	return redacted.Redacted()
+}
+
+// Value returns the value of the field with the given key, redacted. The structs having fields tagged
+// with `log:"redact"` and the maps with string keys are returned as maps, and the errors with sensitive
+// data as strings.
+func (r *Redactor) Value(key string, v interface{}) interface{} {
+	if r == nil || v == nil {
+		return v
+	}
+
+	if r.keys[strings.ToLower(key)] {
+		return Redacted
+	}
+
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case error:
+		msg := v.Error()
+		if redacted := r.String(msg); redacted != msg {
+			return redacted
+		}
+		return v
+	}
+
+	return r.reflectValue(reflect.ValueOf(v), v)
+}
+
+func (r *Redactor) reflectValue(rv reflect.Value, v interface{}) interface{} {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return v
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		if !hasRedactTags(rv.Type()) {
+			return v
+		}
+
+		m := make(map[string]interface{}, rv.NumField())
+		// The fields of the embedded structs are promoted, as by encoding/json.
+		for _, f := range reflect.VisibleFields(rv.Type()) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+
+			name, skip := fieldName(f)
+			if skip {
+				continue
+			}
+
+			if f.Tag.Get("log") == "redact" {
+				m[name] = Redacted
+				continue
+			}
+
+			fv, err := rv.FieldByIndexErr(f.Index)
+			if err != nil {
+				// Field of a nil embedded struct pointer.
+				continue
+			}
+			m[name] = r.Value(name, fv.Interface())
+		}
+		return m
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			m[k] = r.Value(k, iter.Value().Interface())
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// fieldName returns the name of a struct field, its JSON name if any.
+func fieldName(f reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", true
+	case "":
+		return f.Name, false
+	default:
+		return name, false
+	}
+}
+
+// redactTags caches whether the struct types have fields tagged with `log:"redact"`.
+var redactTags sync.Map
+
+func hasRedactTags(t reflect.Type) bool {
+	if has, ok := redactTags.Load(t); ok {
+		return has.(bool)
+	}
+
+	// Recursive types are considered without tags while being inspected.
+	redactTags.Store(t, false)
+
+	has := false
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Tag.Get("log") == "redact" {
+			has = true
+			break
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.IsExported() && ft.Kind() == reflect.Struct && hasRedactTags(ft) {
+			has = true
+			break
+		}
+	}
+
+	redactTags.Store(t, has)
+	return has
+}
+
+// currentRedactor redacts the entries of the logger, nil if disabled.
+var currentRedactor atomic.Pointer[Redactor]
+
+// WithRedactor sets the Redactor applied to the messages and the fields of all the entries, and by
+// RedactString, RedactURL and RedactValue. A nil Redactor disables the redaction. By default, the
+// DefaultRedactedKeys, the keys of the LOG_REDACT_KEYS environment variable (a comma separated list) and
+// the DefaultRedactedPatterns are redacted.
+func WithRedactor(r *Redactor) Option {
+	return func(o *options) {
+		o.redactor = &r
+	}
+}
+
+// RedactString redacts s with the Redactor of the logger, see Redactor.String.
+func RedactString(s string) string {
+	return currentRedactor.Load().String(s)
+}
+
+// RedactURL redacts u with the Redactor of the logger, see Redactor.URL.
+func RedactURL(u *url.URL) string {
+	return currentRedactor.Load().URL(u)
+}
+
+// RedactValue redacts the value of a field with the Redactor of the logger, see Redactor.Value.
+func RedactValue(key string, v interface{}) interface{} {
+	return currentRedactor.Load().Value(key, v)
+}
+
+// redactHook redacts the entries before they are formatted and sent to the outputs.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	r := currentRedactor.Load()
+	if r == nil {
+		return nil
+	}
+
+	// The entry is a copy made for this log, its data can be modified.
+	entry.Message = r.String(entry.Message)
+	for k, v := range entry.Data {
+		entry.Data[k] = r.Value(k, v)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupTimeFormat is the layout of the time in the names of the rotated files.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is a sink writing to a file which is rotated when it reaches a maximum size. The rotated
+// files are renamed after their rotation time in UTC, e.g. app-2024-01-02T15-04-05.000.log, and removed
+// when they are too old or too many. It is safe for concurrent use.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// rotationFailed is set once a failed rotation is reported to errOutput, until a rotation succeeds.
+	rotationFailed bool
+	errOutput      io.Writer
+	now            func() time.Time
+}
+
+// RotatingFileOption is a functional option for NewRotatingFile.
+type RotatingFileOption func(*RotatingFile)
+
+// WithMaxSize sets the size in bytes above which the file is rotated. The file isn't rotated by default.
+func WithMaxSize(size int64) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxSize = size
+	}
+}
+
+// WithMaxAge sets the age above which the rotated files are removed. They are kept by default.
+func WithMaxAge(age time.Duration) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxAge = age
+	}
+}
+
+// WithMaxBackups sets the maximum number of rotated files kept, the oldest ones being removed. They are
+// all kept by default.
+func WithMaxBackups(n int) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxBackups = n
+	}
+}
+
+// NewRotatingFile opens the file, creating it and its directory if needed, for appending.
+func NewRotatingFile(path string, opts ...RotatingFileOption) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:      path,
+		errOutput: os.Stderr,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create log directory")
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write writes to the file, rotating it first if p would make it exceed the maximum size.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, errors.New("log file is closed")
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to stat log file")
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate rotates the file. If the rotation fails, the file is reopened to keep logging, the error being
+// reported once to the standard error, and the rotation is retried after the maximum size is written again.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+
+	if err != nil {
+		err = errors.Wrap(err, "failed to close log file")
+	} else if err = os.Rename(f.path, f.backupName()); err != nil {
+		err = errors.Wrap(err, "failed to rename log file")
+	} else {
+		err = f.open()
+	}
+
+	if err != nil {
+		if reopenErr := f.open(); reopenErr != nil {
+			return err
+		}
+
+		if !f.rotationFailed {
+			fmt.Fprintf(f.errOutput, "Failed to rotate log file %s: %v\n", f.path, err)
+			f.rotationFailed = true
+		}
+		f.size = 0
+		return nil
+	}
+
+	f.rotationFailed = false
+	f.removeBackups()
+	return nil
+}
+
+// backupName returns the name of the file rotated now, which doesn't exist yet.
+func (f *RotatingFile) backupName() string {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+
+	t := f.now().UTC()
+	for {
+		name := prefix + t.Format(backupTimeFormat) + ext
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// removeBackups removes the rotated files exceeding the maximum age or number. Failures are ignored, the
+// files being removed at the next rotation.
+func (f *RotatingFile) removeBackups() {
+	if f.maxAge <= 0 && f.maxBackups <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		name string
+		time time.Time
+	}
+
+	var backups []backup
+	for _, name := range matches {
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name, t})
+	}
+
+	// Newest first.
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+
+	now := f.now()
+	for i, b := range backups {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && now.Sub(b.time) > f.maxAge) {
+			os.Remove(b.name)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	f, err := NewRotatingFile(path, WithMaxSize(10), WithMaxAge(time.Hour), WithMaxBackups(2))
+	require.NoError(t, err)
+	f.now = func() time.Time { return now }
+	defer f.Close()
+
+	write := func(s string) {
+		_, err := f.Write([]byte(s))
+		require.NoError(t, err)
+	}
+
+	write("12345")
+	write("67890")
+	// Rotated before exceeding the maximum size.
+	write("abc")
+
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+	assert.Equal(t, []string{"app-2024-01-02T03-04-05.000.log", "app.log"}, files())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+
+	// Rotations at the same time get distinct names.
+	write("0123456789")
+	assert.Equal(t, []string{"app-2024-01-02T03-04-05.000.log", "app-2024-01-02T03-04-05.001.log", "app.log"}, files())
+
+	// The oldest backups are removed.
+	now = now.Add(30 * time.Minute)
+	write("0123456789")
+	assert.Equal(t, []string{"app-2024-01-02T03-04-05.001.log", "app-2024-01-02T03-34-05.000.log", "app.log"}, files())
+
+	now = now.Add(2 * time.Hour)
+	write("0123456789")
+	assert.Equal(t, []string{"app-2024-01-02T05-34-05.000.log", "app.log"}, files())
+}
+
+func TestRotatingFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	f, err := NewRotatingFile(path, WithMaxSize(10))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var errs bytes.Buffer
+	f.errOutput = &errs
+
+	write := func(s string) {
+		_, err := f.Write([]byte(s))
+		require.NoError(t, err)
+	}
+
+	// The file removed by another process can't be renamed, it is reopened to keep logging.
+	write("12345")
+	require.NoError(t, os.Remove(path))
+	write("0123456789")
+	require.NoError(t, os.Remove(path))
+	write("abc")
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+	assert.Equal(t, 1, strings.Count(errs.String(), "Failed to rotate log file"))
+
+	write("0123456789")
+	b, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{"512": 512, "10KB": 10 << 10, "100 mb": 100 << 20, "1GB": 1 << 30} {
+		size, err := parseSize(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, size, s)
+	}
+
+	_, err := parseSize("-1MB")
+	assert.Error(t, err)
+	_, err = parseSize("big")
+	assert.Error(t, err)
+}
@@ -0,0 +1,266 @@
+package log
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SamplingPolicy limits the entries logged with the same level and message template, i.e. the format of
+// the formatted methods of Logger, e.g. Errorf, or the message of the other entries: in every interval, the
+// first entries are logged, then one out of Thereafter.
+type SamplingPolicy struct {
+	Interval time.Duration
+	First    int
+	// Thereafter is the sampling rate after the first entries. If 0, they are all dropped.
+	Thereafter int
+}
+
+func (p SamplingPolicy) enabled() bool {
+	return p.Interval > 0
+}
+
+// WithSampling samples the entries of the given levels, all the levels if none, using the policy. A zero
+// policy disables the sampling of the levels. Fatal and panic entries are never dropped. The dropped entries
+// are counted by the log_entries_dropped_total Prometheus counter.
+//
+// The entries are dropped by the formatter of LogrusLogger, so they are sampled whichever logger logs them,
+// e.g. the ones returned by WithField. Formatters must therefore be set with WithFormatter.
+func WithSampling(policy SamplingPolicy, levels ...logrus.Level) Option {
+	return func(o *options) {
+		if len(levels) == 0 {
+			levels = logrus.AllLevels
+		}
+
+		if o.sampling == nil {
+			o.sampling = make(map[logrus.Level]SamplingPolicy)
+		}
+
+		for _, level := range levels {
+			o.sampling[level] = policy
+		}
+	}
+}
+
+// maxSampledTemplates is the number of message templates above which the expired counts are removed.
+const maxSampledTemplates = 10000
+
+type sampler struct {
+	policies map[logrus.Level]SamplingPolicy
+
+	mu     sync.Mutex
+	counts map[samplingKey]*samplingCount
+}
+
+type samplingKey struct {
+	level    logrus.Level
+	template string
+}
+
+type samplingCount struct {
+	start time.Time
+	n     int
+}
+
+// currentSampler is the sampler of the logger, nil if no level is sampled.
+var currentSampler atomic.Pointer[sampler]
+
+// updateSampler returns the sampler with the policies of the current one updated by the given ones.
+func updateSampler(policies map[logrus.Level]SamplingPolicy) *sampler {
+	merged := make(map[logrus.Level]SamplingPolicy)
+	if current := currentSampler.Load(); current != nil {
+		for level, p := range current.policies {
+			merged[level] = p
+		}
+	}
+
+	for level, p := range policies {
+		if p.enabled() {
+			merged[level] = p
+		} else {
+			delete(merged, level)
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return &sampler{policies: merged, counts: make(map[samplingKey]*samplingCount)}
+}
+
+// allow reports whether an entry is logged.
+func (s *sampler) allow(level logrus.Level, template string, now time.Time) bool {
+	p, ok := s.policies[level]
+	if !ok {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := samplingKey{level, template}
+	c, ok := s.counts[key]
+	if !ok || now.Sub(c.start) >= p.Interval {
+		if !ok && len(s.counts) >= maxSampledTemplates {
+			s.removeExpired(now)
+		}
+
+		c = &samplingCount{start: now}
+		s.counts[key] = c
+	}
+
+	c.n++
+	if c.n <= p.First {
+		return true
+	}
+
+	return p.Thereafter > 0 && (c.n-p.First)%p.Thereafter == 0
+}
+
+func (s *sampler) removeExpired(now time.Time) {
+	for key, c := range s.counts {
+		if now.Sub(c.start) >= s.policies[key.level].Interval {
+			delete(s.counts, key)
+		}
+	}
+}
+
+// samples reports whether the entries of the level are sampled. Fatal and panic entries never are.
+func (s *sampler) samples(level logrus.Level) bool {
+	_, ok := s.policies[level]
+	return ok && level > logrus.FatalLevel
+}
+
+type (
+	templateKey struct{}
+	sampledKey  struct{}
+)
+
+// sampleEntry reports whether an entry is logged, counting the dropped ones. The decision is kept in the
+// context of the entry, so that it is made once for the hooks and the formatter.
+func sampleEntry(e *logrus.Entry) bool {
+	s := currentSampler.Load()
+	if s == nil || !s.samples(e.Level) {
+		return true
+	}
+
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if keep, ok := ctx.Value(sampledKey{}).(bool); ok {
+		return keep
+	}
+
+	template, ok := ctx.Value(templateKey{}).(string)
+	if !ok {
+		template = e.Message
+	}
+
+	keep := s.allow(e.Level, template, time.Now())
+	if !keep {
+		defaultLogMetrics.dropped(e.Level, "message")
+	}
+
+	e.Context = context.WithValue(ctx, sampledKey{}, keep)
+	return keep
+}
+
+// samplingFormatter drops the entries which are not sampled, see WithSampling.
+type samplingFormatter struct {
+	logrus.Formatter
+}
+
+// Format implements logrus.Formatter.
+func (f samplingFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	if !sampleEntry(e) {
+		return nil, nil
+	}
+
+	return f.Formatter.Format(e)
+}
+
+// samplingHook is a hook only fired for the sampled entries, e.g. the syslog output.
+type samplingHook struct {
+	logrus.Hook
+}
+
+// Fire implements logrus.Hook.
+func (h samplingHook) Fire(e *logrus.Entry) error {
+	if !sampleEntry(e) {
+		return nil
+	}
+
+	return h.Hook.Fire(e)
+}
+
+// withTemplate returns the entry to log a formatted message, holding the format as message template if
+// the level is sampled.
+func (l logger) withTemplate(level logrus.Level, format string) *logrus.Entry {
+	if s := currentSampler.Load(); s == nil || !s.samples(level) || !l.Logger.IsLevelEnabled(level) {
+		return l.Entry
+	}
+
+	ctx := l.Entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return l.Entry.WithContext(context.WithValue(ctx, templateKey{}, format))
+}
+
+// The formatted logging methods of logrus.FieldLogger, sampled by message template.
+
+func (l logger) Debugf(format string, args ...interface{}) {
+	l.withTemplate(logrus.DebugLevel, format).Debugf(format, args...)
+}
+
+func (l logger) Infof(format string, args ...interface{}) {
+	l.withTemplate(logrus.InfoLevel, format).Infof(format, args...)
+}
+
+func (l logger) Printf(format string, args ...interface{}) {
+	l.withTemplate(logrus.InfoLevel, format).Printf(format, args...)
+}
+
+func (l logger) Warnf(format string, args ...interface{}) {
+	l.withTemplate(logrus.WarnLevel, format).Warnf(format, args...)
+}
+
+func (l logger) Warningf(format string, args ...interface{}) {
+	l.withTemplate(logrus.WarnLevel, format).Warningf(format, args...)
+}
+
+func (l logger) Errorf(format string, args ...interface{}) {
+	l.withTemplate(logrus.ErrorLevel, format).Errorf(format, args...)
+}
+
+// RequestSampling samples the logs of the requests served by the web and scrpc servers: the failed and
+// slow requests are always logged, while only a fraction of the successful ones are.
+type RequestSampling struct {
+	// Rate is the fraction of the successful requests logged, between 0 and 1.
+	Rate float64
+	// SlowThreshold is the latency above which the requests are always logged. 0 disables it.
+	SlowThreshold time.Duration
+}
+
+// DefaultRequestSampling is the request sampling used by the servers unless configured otherwise. It is
+// set from the LOG_REQUEST_SAMPLE_RATE and LOG_REQUEST_SLOW_THRESHOLD environment variables, and is nil,
+// keeping all the requests, if the rate isn't set.
+var DefaultRequestSampling *RequestSampling
+
+// Sample reports whether the log of a request is kept, counting the dropped ones. All the requests are
+// kept by a nil RequestSampling.
+func (s *RequestSampling) Sample(failed bool, latency time.Duration) bool {
+	if s == nil || failed || (s.SlowThreshold > 0 && latency >= s.SlowThreshold) || rand.Float64() < s.Rate {
+		return true
+	}
+
+	defaultLogMetrics.dropped(logrus.InfoLevel, "request")
+	return false
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	s := updateSampler(map[logrus.Level]SamplingPolicy{
+		logrus.ErrorLevel: {Interval: time.Second, First: 2, Thereafter: 3},
+	})
+
+	now := time.Now()
+	var allowed []int
+	for i := 1; i <= 10; i++ {
+		if s.allow(logrus.ErrorLevel, "failed: %v", now) {
+			allowed = append(allowed, i)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 5, 8}, allowed)
+
+	// The templates and the levels are sampled separately.
+	assert.True(t, s.allow(logrus.ErrorLevel, "other: %v", now))
+	assert.True(t, s.allow(logrus.InfoLevel, "failed: %v", now))
+
+	// The count is reset after the interval.
+	assert.True(t, s.allow(logrus.ErrorLevel, "failed: %v", now.Add(time.Second)))
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.Out = &buf
+	l.Formatter = samplingFormatter{&logrus.TextFormatter{DisableColors: true}}
+	entry := logger{logrus.NewEntry(l)}
+
+	hook := &recordingHook{}
+	l.AddHook(samplingHook{hook})
+
+	require.NoError(t, Configure(WithSampling(SamplingPolicy{Interval: time.Minute, First: 3}, logrus.ErrorLevel)))
+	defer Configure(WithSampling(SamplingPolicy{}))
+
+	for i := 0; i < 10; i++ {
+		entry.With("attempt", i).Errorf("failed to connect: %d", i)
+		entry.Infof("connecting: %d", i)
+	}
+
+	assert.Equal(t, 3, strings.Count(buf.String(), "failed to connect"))
+	assert.Equal(t, 10, strings.Count(buf.String(), "connecting"))
+
+	// The loggers with fields are sampled too, by message.
+	for i := 0; i < 10; i++ {
+		entry.WithField("attempt", i).WithError(errors.New("timeout")).Error("failed to send")
+		entry.WithField("attempt", i).Errorf("failed to receive: %d", i%2)
+	}
+	assert.Equal(t, 3, strings.Count(buf.String(), "failed to send"))
+	assert.Equal(t, 6, strings.Count(buf.String(), "failed to receive"))
+
+	// The hooks get the same entries as the formatter.
+	assert.Equal(t, strings.Count(buf.String(), "\n"), hook.n)
+
+	require.NoError(t, Configure(WithSampling(SamplingPolicy{}, logrus.ErrorLevel)))
+	assert.Nil(t, currentSampler.Load())
+}
+
+// recordingHook counts the entries it is fired for.
+type recordingHook struct {
+	n int
+}
+
+func (h *recordingHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *recordingHook) Fire(*logrus.Entry) error {
+	h.n++
+	return nil
+}
+
+// countingStringer counts the times it is formatted.
+type countingStringer struct {
+	n *int
+}
+
+func (s countingStringer) String() string {
+	*s.n++
+	return "value"
+}
+
+func TestSamplingMessage(t *testing.T) {
+	l := logrus.New()
+	l.Out = io.Discard
+	l.Formatter = samplingFormatter{l.Formatter}
+	entry := logger{logrus.NewEntry(l)}
+
+	require.NoError(t, Configure(WithSampling(SamplingPolicy{Interval: time.Minute, First: 3}, logrus.ErrorLevel)))
+	defer Configure(WithSampling(SamplingPolicy{}))
+
+	// The message is built once, and not at all for the disabled levels.
+	var n int
+	entry.Debug(countingStringer{&n})
+	assert.Equal(t, 0, n)
+	entry.Info(countingStringer{&n})
+	assert.Equal(t, 1, n)
+	entry.Error(countingStringer{&n})
+	assert.Equal(t, 2, n)
+}
+
+func TestSamplingFatal(t *testing.T) {
+	s := updateSampler(map[logrus.Level]SamplingPolicy{
+		logrus.FatalLevel: {Interval: time.Second},
+		logrus.ErrorLevel: {Interval: time.Second},
+	})
+
+	assert.False(t, s.samples(logrus.FatalLevel))
+	assert.True(t, s.samples(logrus.ErrorLevel))
+}
+
+func TestRequestSampling(t *testing.T) {
+	var s *RequestSampling
+	assert.True(t, s.Sample(false, time.Millisecond))
+
+	s = &RequestSampling{Rate: 0, SlowThreshold: time.Second}
+	assert.False(t, s.Sample(false, time.Millisecond))
+	assert.True(t, s.Sample(true, time.Millisecond))
+	assert.True(t, s.Sample(false, 2*time.Second))
+
+	s.Rate = 1
+	assert.True(t, s.Sample(false, time.Millisecond))
+}
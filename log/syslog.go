@@ -0,0 +1,22 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+)
+
+// newSyslogHook connects to the local syslog daemon. The tag defaults to the name of the program.
+func newSyslogHook(tag string) (logrus.Hook, io.Closer, error) {
+	hook, err := lsyslog.NewSyslogHook("", "", syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to connect to syslog")
+	}
+
+	return hook, hook.Writer, nil
+}
@@ -14,8 +14,9 @@ import (
 )
 
 const (
-	prometheusPortEnv = "PROMETHEUS_TARGET_PORT"
-	prometheusPathEnv = "PROMETHEUS_TARGET_PATH"
+	prometheusPortEnv     = "PROMETHEUS_TARGET_PORT"
+	prometheusPathEnv     = "PROMETHEUS_TARGET_PATH"
+	dynamicConfigAdminEnv = "DYNAMIC_CONFIG_ADMIN_PATH"
 )
 
 func init() {
@@ -39,7 +40,8 @@ func init() {
 
 // resolveOptions looks at the PROMETHEUS_TARGET_PORT and PROMETHEUS_TARGET_PATH environment variables
 // to determine the prometheus endpoint configuration. It returns a list of PrometheusOptions
-// accepted by the NewPrometheusServer function.
+// accepted by the NewPrometheusServer function. If DYNAMIC_CONFIG_ADMIN_PATH is set, the admin endpoint
+// of config.DefaultStore is served on that path.
 func resolveOptions() ([]metrics.PrometheusOption, error) {
 	var opts []metrics.PrometheusOption
 
@@ -56,5 +58,9 @@ func resolveOptions() ([]metrics.PrometheusOption, error) {
 		opts = append(opts, metrics.PrometheusPath(prometheusPath))
 	}
 
+	if adminPath, ok := os.LookupEnv(dynamicConfigAdminEnv); ok {
+		opts = append(opts, metrics.PrometheusHandler(adminPath, config.DefaultStore.AdminHandler()))
+	}
+
 	return opts, nil
 }
@@ -17,8 +17,9 @@ const (
 
 type prometheusServer struct {
 	*http.Server
-	port int
-	path string
+	port     int
+	path     string
+	handlers map[string]http.Handler
 }
 
 // NewPrometheusServer returns a metrics.Server that, when run, exposes an endpoint with Prometheus-specific metrics.
@@ -34,6 +35,9 @@ func NewPrometheusServer(opts ...PrometheusOption) Server {
 
 	mux := http.NewServeMux()
 	mux.Handle(s.path, promhttp.Handler())
+	for pattern, h := range s.handlers {
+		mux.Handle(pattern, h)
+	}
 
 	s.Server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", s.port),
@@ -76,3 +80,14 @@ func PrometheusPath(path string) PrometheusOption {
 		s.path = path
 	}
 }
+
+// PrometheusHandler is a functional option for serving another handler on the Prometheus webserver, e.g.
+// an admin endpoint which must not be exposed on the public port.
+func PrometheusHandler(pattern string, handler http.Handler) PrometheusOption {
+	return func(s *prometheusServer) {
+		if s.handlers == nil {
+			s.handlers = make(map[string]http.Handler)
+		}
+		s.handlers[pattern] = handler
+	}
+}
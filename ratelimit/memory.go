@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping the rate limit state in memory. Limits are not shared between
+// instances, use RedisStore for that.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+
+	// sliding window
+	start      time.Time
+	prev, curr int
+
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Allow implements Store.
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now, limit.Period)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{tokens: float64(limit.burst()), last: now}
+		s.entries[key] = e
+	}
+
+	if limit.Algorithm == SlidingWindow {
+		return s.slidingWindow(e, limit, now), nil
+	}
+
+	return s.tokenBucket(e, limit, now), nil
+}
+
+func (s *MemoryStore) tokenBucket(e *memoryEntry, limit Limit, now time.Time) Result {
+	rate := float64(limit.Rate) / float64(limit.Period)
+	e.tokens = math.Min(float64(limit.burst()), e.tokens+float64(now.Sub(e.last))*rate)
+	e.last = now
+
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	}
+
+	res := tokenBucketResult(limit, e.tokens, allowed)
+	e.expiresAt = now.Add(res.ResetAfter)
+
+	return res
+}
+
+func (s *MemoryStore) slidingWindow(e *memoryEntry, limit Limit, now time.Time) Result {
+	start := now.Truncate(limit.Period)
+	if !e.start.Equal(start) {
+		if e.start.Equal(start.Add(-limit.Period)) {
+			e.prev = e.curr
+		} else {
+			e.prev = 0
+		}
+		e.curr = 0
+		e.start = start
+	}
+
+	count := float64(e.prev)*(1-float64(now.Sub(start))/float64(limit.Period)) + float64(e.curr)
+
+	allowed := count+1 <= float64(limit.Rate)
+	if allowed {
+		e.curr++
+	}
+
+	e.expiresAt = start.Add(2 * limit.Period)
+
+	return slidingWindowResult(limit, now, start, e.prev, e.curr, allowed)
+}
+
+// sweep removes the expired entries, at most once per period.
+func (s *MemoryStore) sweep(now time.Time, period time.Duration) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+
+	for key, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+
+	s.nextSweep = now.Add(period)
+}
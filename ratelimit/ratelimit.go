@@ -0,0 +1,199 @@
+// Package ratelimit implements rate limiters backed by pluggable stores, used by the web middleware and
+// the scrpc interceptors to protect endpoints from abusive clients.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm is the algorithm used to limit the requests.
+type Algorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit.Burst requests, refilled at Limit.Rate requests per Limit.Period.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit.Rate requests in any window of Limit.Period, approximated by weighting the
+	// count of the previous fixed window.
+	SlidingWindow
+)
+
+// Limit describes how many requests are allowed in a period of time.
+type Limit struct {
+	Rate      int
+	Period    time.Duration
+	Burst     int
+	Algorithm Algorithm
+}
+
+// PerSecond returns a token bucket limit of n requests per second.
+func PerSecond(n int) Limit {
+	return Limit{Rate: n, Period: time.Second}
+}
+
+// PerMinute returns a token bucket limit of n requests per minute.
+func PerMinute(n int) Limit {
+	return Limit{Rate: n, Period: time.Minute}
+}
+
+// PerHour returns a token bucket limit of n requests per hour.
+func PerHour(n int) Limit {
+	return Limit{Rate: n, Period: time.Hour}
+}
+
+// WithBurst returns a copy of the limit allowing bursts of b requests. It only applies to TokenBucket
+// limits, which otherwise allow bursts of Rate requests.
+func (l Limit) WithBurst(b int) Limit {
+	l.Burst = b
+	return l
+}
+
+// WithAlgorithm returns a copy of the limit using the given algorithm.
+func (l Limit) WithAlgorithm(a Algorithm) Limit {
+	l.Algorithm = a
+	return l
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Rate
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return errors.Errorf("invalid limit of %d requests per %s", l.Rate, l.Period)
+	}
+
+	return nil
+}
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	Allowed bool
+	// Limit is the maximum number of requests allowed.
+	Limit int
+	// Remaining is the number of requests still allowed.
+	Remaining int
+	// RetryAfter is the time to wait before the next request is allowed, zero when the request is allowed.
+	RetryAfter time.Duration
+	// ResetAfter is the time until the limit is fully reset.
+	ResetAfter time.Duration
+}
+
+// Store keeps the rate limit state of keys.
+type Store interface {
+	// Allow consumes one request from the limit of key and reports whether it is allowed.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter limits the requests per key using a store.
+type Limiter struct {
+	store  Store
+	limit  Limit
+	prefix string
+}
+
+// Option configures a Limiter.
+type Option func(*Limiter)
+
+// WithPrefix sets the prefix added to the keys in the store. Defaults to "ratelimit:". Limiters sharing a
+// store must use different prefixes to keep separate limits for the same key.
+func WithPrefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+// New returns a new limiter allowing limit requests per key.
+func New(store Store, limit Limit, opts ...Option) *Limiter {
+	l := &Limiter{
+		store:  store,
+		limit:  limit,
+		prefix: "ratelimit:",
+	}
+
+	for _, o := range opts {
+		o(l)
+	}
+
+	return l
+}
+
+// Limit returns the limit applied by the limiter.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow consumes one request from the limit of key and reports whether it is allowed.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if err := l.limit.validate(); err != nil {
+		return Result{}, err
+	}
+
+	res, err := l.store.Allow(ctx, l.prefix+key, l.limit)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "failed to check rate limit of %q", key)
+	}
+
+	return res, nil
+}
+
+// tokenBucketResult returns the result of a token bucket check, given the tokens left after the check.
+func tokenBucketResult(l Limit, tokens float64, allowed bool) Result {
+	perToken := float64(l.Period) / float64(l.Rate)
+
+	res := Result{
+		Allowed:    allowed,
+		Limit:      l.burst(),
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration(math.Ceil((float64(l.burst()) - tokens) * perToken)),
+	}
+
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) * perToken))
+	}
+
+	return res
+}
+
+// slidingWindowResult returns the result of a sliding window check, given the counts of the previous
+// and current windows after the check.
+func slidingWindowResult(l Limit, now, start time.Time, prev, curr int, allowed bool) Result {
+	elapsed := now.Sub(start)
+	count := float64(prev)*(1-float64(elapsed)/float64(l.Period)) + float64(curr)
+
+	res := Result{
+		Allowed:    allowed,
+		Limit:      l.Rate,
+		Remaining:  int(math.Max(0, float64(l.Rate)-math.Ceil(count))),
+		ResetAfter: l.Period - elapsed,
+	}
+
+	if !allowed {
+		// The weighted count decreases as the previous window slides out. When the current window alone
+		// exceeds the limit, the next request is allowed in the next window.
+		free := float64(l.Rate - 1 - curr)
+		if prev > 0 && free >= 0 {
+			wait := time.Duration(float64(l.Period)*(1-free/float64(prev))) - elapsed
+			res.RetryAfter = maxDuration(wait, time.Millisecond)
+		} else {
+			res.RetryAfter = l.Period - elapsed
+		}
+	}
+
+	return res
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
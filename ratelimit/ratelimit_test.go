@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+type storeFactory func(t *testing.T, c *clock) Store
+
+var stores = map[string]storeFactory{
+	"memory": func(t *testing.T, c *clock) Store {
+		s := NewMemoryStore()
+		s.now = c.Now
+		return s
+	},
+	"redis": func(t *testing.T, c *clock) Store {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		s := NewRedisStore(client)
+		s.now = c.Now
+		return s
+	},
+}
+
+func allow(t *testing.T, l *Limiter, key string) Result {
+	res, err := l.Allow(context.Background(), key)
+	require.NoError(t, err)
+	return res
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := newClock()
+			l := New(newStore(t, c), PerSecond(2).WithBurst(3))
+
+			for i := 2; i >= 0; i-- {
+				res := allow(t, l, "a")
+				assert.True(t, res.Allowed)
+				assert.Equal(t, 3, res.Limit)
+				assert.Equal(t, i, res.Remaining)
+			}
+
+			res := allow(t, l, "a")
+			assert.False(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+			assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+			assert.Equal(t, 1500*time.Millisecond, res.ResetAfter)
+
+			// Other keys have their own bucket.
+			assert.True(t, allow(t, l, "b").Allowed)
+
+			c.Add(500 * time.Millisecond)
+			assert.True(t, allow(t, l, "a").Allowed)
+			assert.False(t, allow(t, l, "a").Allowed)
+
+			c.Add(10 * time.Second)
+			res = allow(t, l, "a")
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 2, res.Remaining)
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := newClock()
+			l := New(newStore(t, c), PerMinute(4).WithAlgorithm(SlidingWindow))
+
+			for i := 3; i >= 0; i-- {
+				res := allow(t, l, "a")
+				assert.True(t, res.Allowed)
+				assert.Equal(t, 4, res.Limit)
+				assert.Equal(t, i, res.Remaining)
+			}
+
+			res := allow(t, l, "a")
+			assert.False(t, res.Allowed)
+			assert.Equal(t, time.Minute, res.RetryAfter)
+
+			// Halfway through the next window, the previous window counts for 2 requests.
+			c.Add(90 * time.Second)
+			assert.True(t, allow(t, l, "a").Allowed)
+			assert.True(t, allow(t, l, "a").Allowed)
+
+			res = allow(t, l, "a")
+			assert.False(t, res.Allowed)
+			assert.Equal(t, 15*time.Second, res.RetryAfter)
+
+			c.Add(15 * time.Second)
+			assert.True(t, allow(t, l, "a").Allowed)
+		})
+	}
+}
+
+func TestLimiterPrefix(t *testing.T) {
+	store := NewMemoryStore()
+	a := New(store, PerMinute(1), WithPrefix("a:"))
+	b := New(store, PerMinute(1), WithPrefix("b:"))
+
+	assert.True(t, allow(t, a, "key").Allowed)
+	assert.False(t, allow(t, a, "key").Allowed)
+	assert.True(t, allow(t, b, "key").Allowed)
+}
+
+func TestLimiterInvalidLimit(t *testing.T) {
+	l := New(NewMemoryStore(), Limit{Rate: 0, Period: time.Second})
+
+	_, err := l.Allow(context.Background(), "key")
+	assert.Error(t, err)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	c := newClock()
+	s := NewMemoryStore()
+	s.now = c.Now
+	l := New(s, PerSecond(1))
+
+	allow(t, l, "a")
+	allow(t, l, "b")
+	assert.Len(t, s.entries, 2)
+
+	c.Add(2 * time.Second)
+	allow(t, l, "c")
+	assert.Len(t, s.entries, 1)
+}
+
+func TestRedisStoreError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	l := New(NewRedisStore(client), PerSecond(1))
+	mr.Close()
+
+	_, err := l.Allow(context.Background(), "key")
+	assert.Error(t, err)
+}
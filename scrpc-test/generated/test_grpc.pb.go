@@ -23,6 +23,9 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TestServiceClient interface {
 	Test(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	ServerStream(ctx context.Context, in *Empty, opts ...grpc.CallOption) (TestService_ServerStreamClient, error)
+	ClientStream(ctx context.Context, opts ...grpc.CallOption) (TestService_ClientStreamClient, error)
+	BidiStream(ctx context.Context, opts ...grpc.CallOption) (TestService_BidiStreamClient, error)
 }
 
 type testServiceClient struct {
@@ -42,11 +45,111 @@ func (c *testServiceClient) Test(ctx context.Context, in *Empty, opts ...grpc.Ca
 	return out, nil
 }
 
+func (c *testServiceClient) ServerStream(ctx context.Context, in *Empty, opts ...grpc.CallOption) (TestService_ServerStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TestService_ServiceDesc.Streams[0], "/scrpc_test.TestService/ServerStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServiceServerStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TestService_ServerStreamClient interface {
+	Recv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type testServiceServerStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServiceServerStreamClient) Recv() (*Empty, error) {
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *testServiceClient) ClientStream(ctx context.Context, opts ...grpc.CallOption) (TestService_ClientStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TestService_ServiceDesc.Streams[1], "/scrpc_test.TestService/ClientStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServiceClientStreamClient{stream}
+	return x, nil
+}
+
+type TestService_ClientStreamClient interface {
+	Send(*Empty) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type testServiceClientStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServiceClientStreamClient) Send(m *Empty) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *testServiceClientStreamClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *testServiceClient) BidiStream(ctx context.Context, opts ...grpc.CallOption) (TestService_BidiStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TestService_ServiceDesc.Streams[2], "/scrpc_test.TestService/BidiStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServiceBidiStreamClient{stream}
+	return x, nil
+}
+
+type TestService_BidiStreamClient interface {
+	Send(*Empty) error
+	Recv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type testServiceBidiStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServiceBidiStreamClient) Send(m *Empty) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *testServiceBidiStreamClient) Recv() (*Empty, error) {
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TestServiceServer is the server API for TestService service.
 // All implementations must embed UnimplementedTestServiceServer
 // for forward compatibility
 type TestServiceServer interface {
 	Test(context.Context, *Empty) (*Empty, error)
+	ServerStream(*Empty, TestService_ServerStreamServer) error
+	ClientStream(TestService_ClientStreamServer) error
+	BidiStream(TestService_BidiStreamServer) error
 	mustEmbedUnimplementedTestServiceServer()
 }
 
@@ -57,6 +160,15 @@ type UnimplementedTestServiceServer struct {
 func (UnimplementedTestServiceServer) Test(context.Context, *Empty) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Test not implemented")
 }
+func (UnimplementedTestServiceServer) ServerStream(*Empty, TestService_ServerStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ServerStream not implemented")
+}
+func (UnimplementedTestServiceServer) ClientStream(TestService_ClientStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ClientStream not implemented")
+}
+func (UnimplementedTestServiceServer) BidiStream(TestService_BidiStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method BidiStream not implemented")
+}
 func (UnimplementedTestServiceServer) mustEmbedUnimplementedTestServiceServer() {}
 
 // UnsafeTestServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +200,79 @@ func _TestService_Test_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TestService_ServerStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TestServiceServer).ServerStream(m, &testServiceServerStreamServer{stream})
+}
+
+type TestService_ServerStreamServer interface {
+	Send(*Empty) error
+	grpc.ServerStream
+}
+
+type testServiceServerStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceServerStreamServer) Send(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TestService_ClientStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TestServiceServer).ClientStream(&testServiceClientStreamServer{stream})
+}
+
+type TestService_ClientStreamServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*Empty, error)
+	grpc.ServerStream
+}
+
+type testServiceClientStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceClientStreamServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *testServiceClientStreamServer) Recv() (*Empty, error) {
+	m := new(Empty)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TestService_BidiStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TestServiceServer).BidiStream(&testServiceBidiStreamServer{stream})
+}
+
+type TestService_BidiStreamServer interface {
+	Send(*Empty) error
+	Recv() (*Empty, error)
+	grpc.ServerStream
+}
+
+type testServiceBidiStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceBidiStreamServer) Send(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *testServiceBidiStreamServer) Recv() (*Empty, error) {
+	m := new(Empty)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TestService_ServiceDesc is the grpc.ServiceDesc for TestService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,6 +285,23 @@ var TestService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _TestService_Test_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ServerStream",
+			Handler:       _TestService_ServerStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ClientStream",
+			Handler:       _TestService_ClientStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BidiStream",
+			Handler:       _TestService_BidiStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "test.proto",
 }
@@ -0,0 +1,64 @@
+package scrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestServerAuth(t *testing.T) {
+	authenticator := auth.NewBasicAuthenticator(auth.StaticBasicCredentials(map[string]string{"john": "secret"}))
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	}, scrpc.WithPort(18449), scrpc.WithAuth(authenticator))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18449", scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	require.NoError(t, err)
+
+	client := pb.NewTestServiceClient(conn)
+
+	tests := []struct {
+		authorization string
+		code          codes.Code
+	}{
+		{"Basic am9objpzZWNyZXQ=", codes.OK},
+		{"Basic am9objp3cm9uZw==", codes.Unauthenticated},
+		{"Bearer token", codes.Unauthenticated},
+		{"", codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+		}
+
+		_, err = client.Test(ctx, &pb.Empty{})
+		assert.Equal(t, tt.code, status.Code(err), tt.authorization)
+	}
+
+	// Health checks are not authenticated.
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
@@ -0,0 +1,89 @@
+package scrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/breaker"
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestClientCircuitBreaker(t *testing.T) {
+	server := &testServer{err: errors.Unavailable("overloaded")}
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, server)
+	}, scrpc.WithPort(18450))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18450",
+		scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		scrpc.WithCircuitBreaker(breaker.WithTripPolicy(breaker.ConsecutiveFailures(2)), breaker.WithCoolDown(100*time.Millisecond)),
+	)
+	require.NoError(t, err)
+
+	client := pb.NewTestServiceClient(conn)
+
+	for i := 0; i < 2; i++ {
+		_, err = client.Test(context.Background(), &pb.Empty{})
+		require.Error(t, err)
+		assert.Equal(t, "overloaded", status.Convert(err).Message())
+	}
+
+	// The circuit is open: the call fails without reaching the server.
+	server.err = nil
+	_, err = client.Test(context.Background(), &pb.Empty{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.True(t, errors.Is(err, breaker.ErrOpen))
+
+	// After the cool-down, a successful probe closes the circuit.
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.Test(context.Background(), &pb.Empty{})
+	require.NoError(t, err)
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
+
+func TestClientCircuitBreakerCanceled(t *testing.T) {
+	g := breaker.NewGroup("canceled", breaker.WithTripPolicy(breaker.ConsecutiveFailures(1)), breaker.WithCoolDown(time.Millisecond))
+	interceptor := scrpc.CircuitBreakerUnaryClientInterceptor(g)
+
+	invoke := func(err error) grpc.UnaryInvoker {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return err
+		}
+	}
+
+	require.Error(t, interceptor(context.Background(), "/test", nil, nil, nil, invoke(errors.Unavailable("overloaded"))))
+	time.Sleep(2 * time.Millisecond)
+	assert.Equal(t, breaker.StateHalfOpen, g.Get("/test").State())
+
+	// A canceled probe doesn't close the circuit, and frees its slot for the next probe.
+	canceled := status.Error(codes.Canceled, "context canceled")
+	assert.Equal(t, canceled, interceptor(context.Background(), "/test", nil, nil, nil, invoke(canceled)))
+	assert.Equal(t, breaker.StateHalfOpen, g.Get("/test").State())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, interceptor(ctx, "/test", nil, nil, nil, invoke(errors.Unavailable("canceled"))))
+	assert.Equal(t, breaker.StateHalfOpen, g.Get("/test").State())
+
+	require.NoError(t, interceptor(context.Background(), "/test", nil, nil, nil, invoke(nil)))
+	assert.Equal(t, breaker.StateClosed, g.Get("/test").State())
+}
@@ -0,0 +1,46 @@
+package scrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestTypedErrorRoundTrip(t *testing.T) {
+	expected := errors.InvalidArgument("invalid request").WithCode(4001).WithField("email", "is required")
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{err: errors.Wrap(expected, "failed to validate")})
+	}, scrpc.WithPort(18447))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18447", scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	require.NoError(t, err)
+
+	_, err = pb.NewTestServiceClient(conn).Test(context.Background(), &pb.Empty{})
+	require.Error(t, err)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.True(t, errors.Is(err, errors.InvalidArgument("invalid request").WithCode(4001)))
+
+	typed, ok := errors.AsError(err)
+	require.True(t, ok)
+	assert.Equal(t, expected, typed)
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
@@ -0,0 +1,66 @@
+package scrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/flags"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestServerFeatureFlags(t *testing.T) {
+	authenticator := auth.NewBasicAuthenticator(auth.StaticBasicCredentials(map[string]string{"john": "secret"}))
+
+	contexts := make(chan *flags.EvalContext, 1)
+	capture := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ec, _ := flags.FromContext(ctx)
+		contexts <- ec
+		return handler(ctx, req)
+	}
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	},
+		scrpc.WithPort(18454),
+		scrpc.WithAuth(authenticator),
+		scrpc.WithFeatureFlags(func(_ context.Context, fullMethod string, ec *flags.EvalContext) {
+			ec.Attributes = map[string]string{"method": fullMethod}
+		}),
+		scrpc.WithUnaryInterceptorsAfter(capture),
+	)
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18454", scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	require.NoError(t, err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Basic am9objpzZWNyZXQ=",
+		"x-tenant-id", "acme",
+		"x-beta", "1",
+	)
+	_, err = pb.NewTestServiceClient(conn).Test(ctx, &pb.Empty{})
+	require.NoError(t, err)
+
+	ec := <-contexts
+	require.NotNil(t, ec)
+	assert.Equal(t, "john", ec.UserID)
+	assert.Equal(t, "acme", ec.Tenant)
+	assert.Equal(t, "1", ec.Attribute("header:X-Beta"))
+	assert.Equal(t, "/scrpc_test.TestService/Test", ec.Attribute("method"))
+
+	require.NoError(t, conn.Close())
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
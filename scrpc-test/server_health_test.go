@@ -0,0 +1,79 @@
+package scrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/starclusterteam/go-starbox/health"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestServerHealth(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Register("downstream", health.CheckerFunc(func(context.Context) error {
+		return errors.New("unavailable")
+	}), health.ForServices("other.Service"))
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	}, scrpc.WithPort(18446), scrpc.WithHealthRegistry(registry), scrpc.WithHealthCheckInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := grpc.Dial("localhost:18446", grpc.WithInsecure())
+	require.NoError(t, err)
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	status := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	require.Eventually(t, func() bool {
+		return status("") == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, status("scrpc_test.TestService"))
+
+	registry.Unregister("downstream")
+	require.Eventually(t, func() bool {
+		return status("") == grpc_health_v1.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
+
+func TestServerHealthDefaults(t *testing.T) {
+	// The invalid health options are ignored.
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	}, scrpc.WithPort(18455), scrpc.WithHealthRegistry(nil), scrpc.WithHealthCheckInterval(0))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := grpc.Dial("localhost:18455", grpc.WithInsecure())
+	require.NoError(t, err)
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
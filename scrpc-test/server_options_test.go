@@ -0,0 +1,139 @@
+package scrpc_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *callRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, name)
+}
+
+func (r *callRecorder) reset() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func (r *callRecorder) unaryServer(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r.record(name)
+		return handler(ctx, req)
+	}
+}
+
+func (r *callRecorder) unaryClient(name string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		r.record(name)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	var recorder callRecorder
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	},
+		scrpc.WithPort(18452),
+		scrpc.WithoutTracing(),
+		scrpc.WithoutLogging(),
+		scrpc.WithoutMetrics(),
+		scrpc.WithUnaryInterceptorsBefore(recorder.unaryServer("server before 1"), recorder.unaryServer("server before 2")),
+		scrpc.WithUnaryInterceptorsAfter(recorder.unaryServer("server after")),
+		scrpc.WithUnaryInterceptorsAfter(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if info.FullMethod == "/grpc.health.v1.Health/Check" {
+				return handler(ctx, req)
+			}
+			return nil, errors.PermissionDenied("denied by interceptor")
+		}),
+		scrpc.WithGRPCServerOptions(grpc.MaxRecvMsgSize(1<<10)),
+		scrpc.WithReflection(),
+		scrpc.WithChannelz(),
+	)
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18452",
+		scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		scrpc.WithDialOptions(grpc.WithChainUnaryInterceptor(recorder.unaryClient("client dial option"))),
+		scrpc.WithUnaryClientInterceptorsBefore(recorder.unaryClient("client before")),
+		scrpc.WithUnaryClientInterceptorsAfter(recorder.unaryClient("client after")),
+	)
+	require.NoError(t, err)
+
+	// The errors returned by the interceptors added after the built-in ones are converted.
+	_, err = pb.NewTestServiceClient(conn).Test(context.Background(), &pb.Empty{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, errors.KindPermissionDenied, errors.KindOf(err))
+
+	assert.Equal(t, []string{
+		"client before",
+		"client after",
+		"client dial option",
+		"server before 1",
+		"server before 2",
+		"server after",
+	}, recorder.reset())
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.CloseSend())
+
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	sort.Strings(services)
+	assert.Equal(t, []string{
+		"grpc.channelz.v1.Channelz",
+		"grpc.health.v1.Health",
+		"grpc.reflection.v1.ServerReflection",
+		"grpc.reflection.v1alpha.ServerReflection",
+		"scrpc_test.TestService",
+	}, services)
+
+	// The health of the reflection and channelz services is not reported.
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+		Service: "grpc.channelz.v1.Channelz",
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
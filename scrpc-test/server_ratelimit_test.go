@@ -0,0 +1,88 @@
+package scrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/ratelimit"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+func TestServerRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1))
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	}, scrpc.WithPort(18448), scrpc.WithRateLimit(limiter, scrpc.RateLimitByPeer))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18448", scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	require.NoError(t, err)
+
+	client := pb.NewTestServiceClient(conn)
+
+	var header metadata.MD
+	_, err = client.Test(context.Background(), &pb.Empty{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, header.Get("ratelimit-limit"))
+	assert.Equal(t, []string{"0"}, header.Get("ratelimit-remaining"))
+
+	_, err = client.Test(context.Background(), &pb.Empty{}, grpc.Header(&header))
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get("retry-after"))
+
+	st, _ := status.FromError(err)
+	require.Len(t, st.Details(), 1)
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.InDelta(t, time.Minute, retry.RetryDelay.AsDuration(), float64(time.Second))
+
+	// Health checks are not limited.
+	for i := 0; i < 3; i++ {
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	assert.Equal(t, "10.0.0.1", scrpc.RateLimitByPeer(ctx, "/scrpc_test.TestService/Test"))
+	assert.Equal(t, "", scrpc.RateLimitByPrincipal(ctx, "/scrpc_test.TestService/Test"))
+
+	ctx = auth.NewContext(ctx, &auth.Principal{Subject: "user-1"})
+	assert.Equal(t, "user-1", scrpc.RateLimitByPrincipal(ctx, "/scrpc_test.TestService/Test"))
+
+	// A nil key func limits per peer.
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1))
+	interceptor := scrpc.RateLimitUnaryInterceptor(limiter, nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/scrpc_test.TestService/Test"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	_, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,175 @@
+package scrpc_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/scrpc"
+	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+)
+
+// flakyServer fails the first server streams with an Unavailable status.
+type flakyServer struct {
+	testServer
+
+	failures int32
+}
+
+func (s *flakyServer) ServerStream(in *pb.Empty, stream pb.TestService_ServerStreamServer) error {
+	if atomic.AddInt32(&s.failures, -1) >= 0 {
+		return status.Error(codes.Unavailable, "not ready")
+	}
+
+	return s.testServer.ServerStream(in, stream)
+}
+
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := labels[l.GetName()]; ok && v != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
+
+func TestServerStreams(t *testing.T) {
+	tracer := mocktracer.New()
+	server := &flakyServer{failures: 1}
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, server)
+	}, scrpc.WithPort(18451), scrpc.WithServerTracer(tracer))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	conn, err := scrpc.Dial("localhost:18451",
+		scrpc.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		scrpc.WithTracer(tracer),
+		scrpc.WithRetry(grpc_retry.WithMax(2)),
+	)
+	require.NoError(t, err)
+
+	client := pb.NewTestServiceClient(conn)
+	sentBefore := counterValue(t, "incoming_grpc_stream_messages_sent_total", map[string]string{"method": "/scrpc_test.TestService/ServerStream"})
+
+	t.Run("server stream", func(t *testing.T) {
+		// The first attempt fails and is retried.
+		stream, err := client.ServerStream(context.Background(), &pb.Empty{})
+		require.NoError(t, err)
+
+		var n int
+		for ; ; n++ {
+			_, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, n)
+
+		sent := counterValue(t, "incoming_grpc_stream_messages_sent_total", map[string]string{"method": "/scrpc_test.TestService/ServerStream"})
+		assert.Equal(t, 3.0, sent-sentBefore)
+	})
+
+	t.Run("client stream", func(t *testing.T) {
+		// Client streams are not retried, so they don't fail with retries enabled.
+		stream, err := client.ClientStream(context.Background())
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, stream.Send(&pb.Empty{}))
+		}
+
+		_, err = stream.CloseAndRecv()
+		require.NoError(t, err)
+	})
+
+	t.Run("bidi stream", func(t *testing.T) {
+		stream, err := client.BidiStream(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&pb.Empty{}))
+		_, err = stream.Recv()
+		require.NoError(t, err)
+
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server.err = errors.NotFound("project not found")
+		defer func() { server.err = nil }()
+
+		stream, err := client.BidiStream(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, errors.KindNotFound, errors.KindOf(err))
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		server.panics = true
+		defer func() { server.panics = false }()
+
+		_, err := client.Test(context.Background(), &pb.Empty{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		stream, err := client.ServerStream(context.Background(), &pb.Empty{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	s.GracefulStop()
+	require.NoError(t, g.Wait())
+
+	var serverSpans, clientSpans int
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName != "/scrpc_test.TestService/BidiStream" {
+			continue
+		}
+
+		if span.Tag(string(ext.SpanKind)) == ext.SpanKindRPCServerEnum {
+			serverSpans++
+		} else {
+			clientSpans++
+		}
+	}
+	assert.Equal(t, 2, serverSpans)
+	assert.Equal(t, 2, clientSpans)
+}
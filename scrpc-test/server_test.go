@@ -2,6 +2,7 @@ package scrpc_test
 
 import (
 	"context"
+	"io"
 	"testing"
 
 	"github.com/starclusterteam/go-starbox/scrpc"
@@ -18,10 +19,68 @@ const (
 
 type testServer struct {
 	pb.UnsafeTestServiceServer
+
+	err    error
+	panics bool
 }
 
 func (s *testServer) Test(context.Context, *pb.Empty) (*pb.Empty, error) {
-	return &pb.Empty{}, nil
+	if s.panics {
+		panic("test panic")
+	}
+
+	return &pb.Empty{}, s.err
+}
+
+// ServerStream sends three messages.
+func (s *testServer) ServerStream(_ *pb.Empty, stream pb.TestService_ServerStreamServer) error {
+	if s.panics {
+		panic("test panic")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&pb.Empty{}); err != nil {
+			return err
+		}
+	}
+
+	return s.err
+}
+
+// ClientStream receives all the messages before responding.
+func (s *testServer) ClientStream(stream pb.TestService_ClientStreamServer) error {
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.err != nil {
+		return s.err
+	}
+
+	return stream.SendAndClose(&pb.Empty{})
+}
+
+// BidiStream echoes the messages received.
+func (s *testServer) BidiStream(stream pb.TestService_BidiStreamServer) error {
+	for {
+		m, err := stream.Recv()
+		if err == io.EOF {
+			return s.err
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(m); err != nil {
+			return err
+		}
+	}
 }
 
 func TestServer(t *testing.T) {
@@ -4,11 +4,14 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/starclusterteam/go-starbox/scrpc"
 	pb "github.com/starclusterteam/go-starbox/scrpc-test/generated"
+	"github.com/starclusterteam/go-starbox/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
@@ -16,32 +19,55 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
-const (
-	serverCert = "testdata/certs/server.pem"
-	serverKey  = "testdata/certs/server-key.pem"
-	serverCa   = "testdata/certs/server-ca.pem"
+// tlsCerts holds the paths of the certificates used by the TLS tests. Each party has its own CA.
+type tlsCerts struct {
+	serverCert, serverKey, serverCa    string
+	client1Cert, client1Key, client1Ca string
+	client2Cert, client2Key, client2Ca string
+}
 
-	client1Ca   = "testdata/certs/client1-ca.pem"
-	client1Cert = "testdata/certs/client1.pem"
-	client1Key  = "testdata/certs/client1-key.pem"
+func newTLSCerts(t *testing.T) tlsCerts {
+	dir := t.TempDir()
 
-	client2Ca   = "testdata/certs/client2-ca.pem"
-	client2Cert = "testdata/certs/client2.pem"
-	client2Key  = "testdata/certs/client2-key.pem"
-)
+	issue := func(name string, hosts ...string) (cert, key, ca string) {
+		authority, err := testutil.NewCertificateAuthority(name + "-ca")
+		require.NoError(t, err)
+
+		certPEM, keyPEM, err := authority.Issue(name, hosts...)
+		require.NoError(t, err)
+
+		cert = filepath.Join(dir, name+".pem")
+		key = filepath.Join(dir, name+"-key.pem")
+		ca = filepath.Join(dir, name+"-ca.pem")
+		require.NoError(t, os.WriteFile(cert, certPEM, 0600))
+		require.NoError(t, os.WriteFile(key, keyPEM, 0600))
+		require.NoError(t, os.WriteFile(ca, authority.CertPEM, 0600))
+
+		return cert, key, ca
+	}
+
+	var c tlsCerts
+	c.serverCert, c.serverKey, c.serverCa = issue("server", serverName, "localhost")
+	c.client1Cert, c.client1Key, c.client1Ca = issue("client1")
+	c.client2Cert, c.client2Key, c.client2Ca = issue("client2")
+
+	return c
+}
 
 func TestServerWithTLS(t *testing.T) {
+	c := newTLSCerts(t)
+
 	s, err := scrpc.NewServer(func(s *grpc.Server) {
 		pb.RegisterTestServiceServer(s, &testServer{})
-	}, scrpc.WithServerTLSFromParams(serverCert, serverKey, []string{client1Ca, client2Ca}))
+	}, scrpc.WithServerTLSFromParams(c.serverCert, c.serverKey, []string{c.client1Ca, c.client2Ca}))
 	require.NoError(t, err)
 
 	// Run grpc server in a separate goroutine.
 	var g errgroup.Group
 	g.Go(s.Run)
 
-	client1 := dialTLSClient(t, "localhost:18555", client1Cert, client1Key, serverCa)
-	client2 := dialTLSClient(t, "localhost:18555", client2Cert, client2Key, serverCa)
+	client1 := dialTLSClient(t, "localhost:18555", c.client1Cert, c.client1Key, c.serverCa)
+	client2 := dialTLSClient(t, "localhost:18555", c.client2Cert, c.client2Key, c.serverCa)
 
 	_, err = client1.Test(context.Background(), &pb.Empty{})
 	assert.NoError(t, err)
@@ -58,17 +84,19 @@ func TestServerWithTLS(t *testing.T) {
 }
 
 func TestClientWithTLS(t *testing.T) {
+	c := newTLSCerts(t)
+
 	s, err := scrpc.NewServer(func(s *grpc.Server) {
 		pb.RegisterTestServiceServer(s, &testServer{})
-	}, scrpc.WithServerTLSFromParams(serverCert, serverKey, []string{client1Ca, client2Ca}))
+	}, scrpc.WithServerTLSFromParams(c.serverCert, c.serverKey, []string{c.client1Ca, c.client2Ca}))
 	require.NoError(t, err)
 
 	// Run grpc server in a separate goroutine.
 	var g errgroup.Group
 	g.Go(s.Run)
 
-	client1 := scrpcDialTLSClient(t, "localhost:18555", client1Cert, client1Key, serverCa)
-	client2 := scrpcDialTLSClient(t, "localhost:18555", client2Cert, client2Key, serverCa)
+	client1 := scrpcDialTLSClient(t, "localhost:18555", c.client1Cert, c.client1Key, c.serverCa)
+	client2 := scrpcDialTLSClient(t, "localhost:18555", c.client2Cert, c.client2Key, c.serverCa)
 
 	_, err = client1.Test(context.Background(), &pb.Empty{})
 	assert.NoError(t, err)
@@ -91,7 +119,7 @@ func dialTLSClient(t *testing.T, addr, clientCert, clientKey, serverCa string) p
 
 	// Create a certificate pool from the certificate authority.
 	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(serverCa)
+	ca, err := os.ReadFile(serverCa)
 	require.NoError(t, err)
 
 	// Append the certificates from the CA.
@@ -114,5 +142,52 @@ func dialTLSClient(t *testing.T, addr, clientCert, clientKey, serverCa string) p
 func scrpcDialTLSClient(t *testing.T, addr, clientCert, clientKey, serverCa string) pb.TestServiceClient {
 	conn, err := scrpc.Dial(addr, scrpc.WithClientTLSFromParams(serverName, clientCert, clientKey, serverCa))
 	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
 	return pb.NewTestServiceClient(conn)
 }
+
+func TestServerTLSReload(t *testing.T) {
+	c := newTLSCerts(t)
+
+	s, err := scrpc.NewServer(func(s *grpc.Server) {
+		pb.RegisterTestServiceServer(s, &testServer{})
+	}, scrpc.WithServerTLSFromParams(c.serverCert, c.serverKey, []string{c.client1Ca}), scrpc.WithPort(18453))
+	require.NoError(t, err)
+
+	var g errgroup.Group
+	g.Go(s.Run)
+
+	call := func(cert, key string) error {
+		conn, err := scrpc.Dial("localhost:18453", scrpc.WithClientTLSFromParams(serverName, cert, key, c.serverCa))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err = pb.NewTestServiceClient(conn).Test(ctx, &pb.Empty{})
+		return err
+	}
+
+	assert.NoError(t, call(c.client1Cert, c.client1Key))
+	assert.Error(t, call(c.client2Cert, c.client2Key))
+
+	// Trust the CA of the second client without restarting the server.
+	ca1, err := os.ReadFile(c.client1Ca)
+	require.NoError(t, err)
+	ca2, err := os.ReadFile(c.client2Ca)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.client1Ca, append(ca1, ca2...), 0600))
+
+	assert.Eventually(t, func() bool {
+		return call(c.client2Cert, c.client2Key) == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.NoError(t, call(c.client1Cert, c.client1Key))
+
+	s.GracefulStop()
+	err = g.Wait()
+	if err != grpc.ErrServerStopped {
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,87 @@
+package scrpc
+
+import (
+	"context"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/auth"
+)
+
+// metadataCredentials adapts the incoming metadata to auth.Credentials.
+type metadataCredentials metadata.MD
+
+func (md metadataCredentials) Get(name string) string {
+	values := metadata.MD(md).Get(name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// AuthUnaryInterceptor is a gRPC server-side interceptor authenticating the calls using the given
+// authenticator and the "authorization" metadata (or any other metadata read by the authenticator).
+// The principal is added to the context and can be retrieved with auth.FromContext. Authentication
+// failures are returned as Unauthenticated statuses. Methods for which skip returns true are not
+// authenticated; skip may be nil.
+func AuthUnaryInterceptor(authenticator auth.Authenticator, skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if skip != nil && skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the stream counterpart of AuthUnaryInterceptor.
+func AuthStreamInterceptor(authenticator auth.Authenticator, skip func(fullMethod string) bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip != nil && skip(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticate(ss.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+func authenticate(ctx context.Context, authenticator auth.Authenticator) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	p, err := authenticator.Authenticate(ctx, metadataCredentials(md))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrMissingCredentials):
+			return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+		case errors.Is(err, auth.ErrMalformedCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		default:
+			GetLogger(ctx).Errorf("Failed to authenticate call: %v", err)
+			return nil, status.Error(codes.Internal, "failed to authenticate")
+		}
+	}
+
+	return auth.NewContext(ctx, p), nil
+}
@@ -0,0 +1,65 @@
+package scrpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/starclusterteam/go-starbox/breaker"
+)
+
+// CircuitBreakerUnaryClientInterceptor is a gRPC client-side interceptor that fails the calls with an
+// Unavailable status matching breaker.ErrOpen while the breaker of their method is open. Calls failing
+// with the Unavailable, DeadlineExceeded, Internal or Unknown codes are failures. Canceled calls are
+// neither successes nor failures.
+func CircuitBreakerUnaryClientInterceptor(g *breaker.Group) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, release, err := g.Get(method).AllowWithRelease()
+		if err != nil {
+			return FromStatus(ToStatus(err))
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		breakerOutcome(ctx, err, done, release)
+
+		return err
+	}
+}
+
+// CircuitBreakerStreamClientInterceptor is the stream counterpart of CircuitBreakerUnaryClientInterceptor.
+// Only the errors opening the streams are failures.
+func CircuitBreakerStreamClientInterceptor(g *breaker.Group) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done, release, err := g.Get(method).AllowWithRelease()
+		if err != nil {
+			return nil, FromStatus(ToStatus(err))
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		breakerOutcome(ctx, err, done, release)
+
+		return stream, err
+	}
+}
+
+// breakerOutcome records the outcome of a call, or releases it when the caller canceled it.
+func breakerOutcome(ctx context.Context, err error, done func(success bool), release func()) {
+	if status.Code(err) == codes.Canceled || errors.Is(ctx.Err(), context.Canceled) {
+		release()
+		return
+	}
+
+	done(!breakerFailure(err))
+}
+
+func breakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
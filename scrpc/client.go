@@ -1,9 +1,7 @@
 package scrpc
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
+	"context"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
@@ -11,8 +9,11 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 
+	"github.com/starclusterteam/go-starbox/breaker"
+	"github.com/starclusterteam/go-starbox/certwatch"
 	"github.com/starclusterteam/go-starbox/config"
 	"github.com/starclusterteam/go-starbox/tracing"
 )
@@ -23,7 +24,8 @@ const (
 )
 
 // Dial wraps grpc.Dial with the possibility of overriding tracing or adding retry interceptor.
-// It is possible to add grpc.DialOptions with WithDialOptions().
+// Unary calls and streams are traced, and their errors converted with FromStatus. Only unary calls and
+// server streams are retried. It is possible to add grpc.DialOptions with WithDialOptions().
 func Dial(target string, opts ...DialOption) (*grpc.ClientConn, error) {
 	options := dialOptions{
 		tracer: tracing.Tracer,
@@ -33,52 +35,93 @@ func Dial(target string, opts ...DialOption) (*grpc.ClientConn, error) {
 		o(&options)
 	}
 
-	unaryInterceptor := grpc_middleware.ChainUnaryClient(
-		otgrpc.OpenTracingClientInterceptor(options.tracer, options.tracingOpts...),
+	unaryInterceptors := append([]grpc.UnaryClientInterceptor{}, options.unaryBefore...)
+	unaryInterceptors = append(unaryInterceptors, otgrpc.OpenTracingClientInterceptor(options.tracer, options.tracingOpts...))
+
+	streamInterceptors := append([]grpc.StreamClientInterceptor{}, options.streamBefore...)
+
+	if options.breakerOpts != nil {
+		// The breaker wraps the retries, so calls are not retried while the circuit is open.
+		g := breaker.NewGroup(target, options.breakerOpts...)
+		unaryInterceptors = append(unaryInterceptors, CircuitBreakerUnaryClientInterceptor(g))
+		streamInterceptors = append(streamInterceptors, CircuitBreakerStreamClientInterceptor(g))
+	}
+
+	unaryInterceptors = append(unaryInterceptors,
 		grpc_retry.UnaryClientInterceptor(options.retryOpts...),
+		ErrorClientInterceptor,
+	)
+	// The retries of a stream replace its underlying stream, so they wrap the tracing and each attempt
+	// is traced on its own stream.
+	streamInterceptors = append(streamInterceptors,
+		retryStreamClientInterceptor(options.retryOpts...),
+		otgrpc.OpenTracingStreamClientInterceptor(options.tracer, options.tracingOpts...),
+		ErrorStreamClientInterceptor,
 	)
 
-	tc, err := resolveTransportCredentials(options.tlsConfig)
+	unaryInterceptors = append(unaryInterceptors, options.unaryAfter...)
+	streamInterceptors = append(streamInterceptors, options.streamAfter...)
+
+	tc, certs, err := resolveTransportCredentials(options.tlsConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to resolve transport credentials")
 	}
 
-	grpcOptions := []grpc.DialOption{grpc.WithUnaryInterceptor(unaryInterceptor), grpc.WithTransportCredentials(tc)}
+	grpcOptions := []grpc.DialOption{
+		grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(unaryInterceptors...)),
+		grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(streamInterceptors...)),
+		grpc.WithTransportCredentials(tc),
+	}
 	grpcOptions = append(grpcOptions, options.opts...)
 
-	return grpc.Dial(target, grpcOptions...)
+	conn, err := grpc.Dial(target, grpcOptions...)
+	if certs != nil {
+		if err != nil {
+			certs.Close()
+		} else {
+			go closeOnShutdown(conn, certs)
+		}
+	}
+
+	return conn, err
 }
 
-func resolveTransportCredentials(c *clientTLSConfig) (credentials.TransportCredentials, error) {
-	if c == nil {
-		return nil, nil
+// retryStreamClientInterceptor retries the server streams only, until their first message is received.
+// Client streams are never retried since the messages already sent can't be replayed.
+func retryStreamClientInterceptor(opts ...grpc_retry.CallOption) grpc.StreamClientInterceptor {
+	retry := grpc_retry.StreamClientInterceptor(opts...)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if desc.ClientStreams {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+
+		return retry(ctx, desc, cc, method, streamer, callOpts...)
 	}
+}
 
-	// Load the client certificates from disk.
-	certificate, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to load server key pair")
+// resolveTransportCredentials returns the client credentials, whose certificate and server CA are
+// reloaded when their files change, and the watcher of the files to close with the connection.
+func resolveTransportCredentials(c *clientTLSConfig) (credentials.TransportCredentials, *certwatch.Watcher, error) {
+	if c == nil {
+		return nil, nil, nil
 	}
 
-	// Create a certificate pool from the certificate authority.
-	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(c.serverCAPath)
+	watcher, err := certwatch.New(c.certPath, c.keyPath, []string{c.serverCAPath})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read ca cert")
+		return nil, nil, errors.Wrap(err, "failed to load client certificates")
 	}
 
-	// Append the certificates from the CA.
-	if ok := certPool.AppendCertsFromPEM(ca); !ok {
-		return nil, errors.Wrap(err, "failed to append ca cert")
-	}
+	return credentials.NewTLS(watcher.ClientTLSConfig(c.serverName)), watcher, nil
+}
 
-	creds := credentials.NewTLS(&tls.Config{
-		ServerName:   c.serverName,
-		Certificates: []tls.Certificate{certificate},
-		RootCAs:      certPool,
-	})
+// closeOnShutdown closes the watcher once the connection is closed.
+func closeOnShutdown(conn *grpc.ClientConn, watcher *certwatch.Watcher) {
+	for state := conn.GetState(); state != connectivity.Shutdown; state = conn.GetState() {
+		conn.WaitForStateChange(context.Background(), state)
+	}
 
-	return creds, nil
+	watcher.Close()
 }
 
 // DialOption represents a functional option for Dial.
@@ -92,6 +135,13 @@ type dialOptions struct {
 
 	retryOpts []grpc_retry.CallOption
 
+	breakerOpts []breaker.Option
+
+	unaryBefore  []grpc.UnaryClientInterceptor
+	unaryAfter   []grpc.UnaryClientInterceptor
+	streamBefore []grpc.StreamClientInterceptor
+	streamAfter  []grpc.StreamClientInterceptor
+
 	tlsConfig *clientTLSConfig
 }
 
@@ -117,10 +167,49 @@ func WithRetry(opts ...grpc_retry.CallOption) DialOption {
 	}
 }
 
-// WithDialOptions sets the grpc.DialOptions for the underlying grpc.Dial.
+// WithCircuitBreaker is a grpc dial option that adds a circuit breaker per method to the requests. While
+// the breaker of a method is open, its calls fail with an Unavailable status without being sent or retried.
+func WithCircuitBreaker(opts ...breaker.Option) DialOption {
+	return func(o *dialOptions) {
+		o.breakerOpts = append([]breaker.Option{}, opts...)
+	}
+}
+
+// WithDialOptions adds grpc.DialOptions to the underlying grpc.Dial. They are applied after the built-in
+// options, in the order they are given.
 func WithDialOptions(opts ...grpc.DialOption) DialOption {
 	return func(o *dialOptions) {
-		o.opts = opts
+		o.opts = append(o.opts, opts...)
+	}
+}
+
+// WithUnaryClientInterceptorsBefore adds unary interceptors running before the built-in ones, in the
+// given order. They are called once per call, whatever the number of retries.
+func WithUnaryClientInterceptorsBefore(interceptors ...grpc.UnaryClientInterceptor) DialOption {
+	return func(o *dialOptions) {
+		o.unaryBefore = append(o.unaryBefore, interceptors...)
+	}
+}
+
+// WithUnaryClientInterceptorsAfter adds unary interceptors running after the built-in ones, just before
+// the calls are sent. They are called for each attempt and receive the errors as gRPC statuses.
+func WithUnaryClientInterceptorsAfter(interceptors ...grpc.UnaryClientInterceptor) DialOption {
+	return func(o *dialOptions) {
+		o.unaryAfter = append(o.unaryAfter, interceptors...)
+	}
+}
+
+// WithStreamClientInterceptorsBefore is the stream counterpart of WithUnaryClientInterceptorsBefore.
+func WithStreamClientInterceptorsBefore(interceptors ...grpc.StreamClientInterceptor) DialOption {
+	return func(o *dialOptions) {
+		o.streamBefore = append(o.streamBefore, interceptors...)
+	}
+}
+
+// WithStreamClientInterceptorsAfter is the stream counterpart of WithUnaryClientInterceptorsAfter.
+func WithStreamClientInterceptorsAfter(interceptors ...grpc.StreamClientInterceptor) DialOption {
+	return func(o *dialOptions) {
+		o.streamAfter = append(o.streamAfter, interceptors...)
 	}
 }
 
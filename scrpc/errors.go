@@ -0,0 +1,175 @@
+package scrpc
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/starclusterteam/go-starbox/apm"
+	"github.com/starclusterteam/go-starbox/errors"
+)
+
+// errorDomain is the domain set in the ErrorInfo details of statuses created from *errors.Error.
+const errorDomain = "starbox"
+
+// ToStatus converts an *errors.Error into a gRPC status. The kind is mapped to the status code, the
+// public message becomes the status message, while the application code and the field errors are sent
+// as ErrorInfo and BadRequest details. Internal errors only expose their public message.
+// Errors that are not *errors.Error are converted with status.Convert.
+func ToStatus(err error) *status.Status {
+	e, ok := errors.AsError(err)
+	if !ok {
+		return status.Convert(err)
+	}
+
+	st := status.New(e.Kind.GRPCCode(), e.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason: e.Kind.String(),
+		Domain: errorDomain,
+	}
+	if e.Code != 0 {
+		info.Metadata = map[string]string{"code": strconv.Itoa(e.Code)}
+	}
+
+	details := []protoadapt.MessageV1{info}
+	if len(e.Fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range e.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+			})
+		}
+		details = append(details, br)
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+
+	return withDetails
+}
+
+// FromStatus converts a gRPC status into an *errors.Error, the inverse of ToStatus. Statuses without
+// details get the kind matching their code. It returns nil for an OK status.
+func FromStatus(st *status.Status) error {
+	if st.Code() == codes.OK {
+		return nil
+	}
+
+	e := errors.E(errors.KindFromGRPCCode(st.Code()), st.Message())
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Domain != errorDomain {
+				continue
+			}
+
+			if code, err := strconv.Atoi(d.Metadata["code"]); err == nil {
+				e.Code = code
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				e.Fields = append(e.Fields, errors.FieldError{Field: v.Field, Message: v.Description})
+			}
+		}
+	}
+
+	return &statusError{err: e, st: st}
+}
+
+// statusError is returned by the client interceptor. It unwraps to the *errors.Error and still
+// carries the original status, so status.Code and status.FromError keep working.
+type statusError struct {
+	err *errors.Error
+	st  *status.Status
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.st
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// ErrorInterceptor is a gRPC server-side interceptor that converts *errors.Error returned by
+// handlers into statuses using ToStatus. Internal errors are reported before being converted.
+func ErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, serverError(ctx, err)
+}
+
+// ErrorStreamInterceptor is the stream counterpart of ErrorInterceptor.
+func ErrorStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return serverError(ss.Context(), handler(srv, ss))
+}
+
+func serverError(ctx context.Context, err error) error {
+	e, ok := errors.AsError(err)
+	if !ok {
+		return err
+	}
+
+	if e.Kind == errors.KindInternal || e.Kind == errors.KindUnknown {
+		apm.Report(ctx, err)
+	}
+
+	return ToStatus(e).Err()
+}
+
+// ErrorClientInterceptor is a gRPC client-side interceptor that converts the error statuses received
+// into *errors.Error using FromStatus.
+func ErrorClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return clientError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// ErrorStreamClientInterceptor is the stream counterpart of ErrorClientInterceptor. It converts the
+// errors opening the streams and receiving messages.
+func ErrorStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, clientError(err)
+	}
+
+	return &errorClientStream{ClientStream: stream}, nil
+}
+
+type errorClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		return err
+	}
+
+	return clientError(err)
+}
+
+func clientError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	return FromStatus(st)
+}
@@ -0,0 +1,54 @@
+package scrpc
+
+import (
+	"context"
+	"net/http"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/starclusterteam/go-starbox/flags"
+)
+
+// FlagsContextFunc completes the feature flags evaluation context of a call, e.g. with attributes read
+// from the request metadata.
+type FlagsContextFunc func(ctx context.Context, fullMethod string, ec *flags.EvalContext)
+
+// FeatureFlagsUnaryInterceptor is a gRPC server-side interceptor storing the feature flags evaluation
+// context of the calls in their context, so the flags evaluated by the handlers depend on the call. The
+// context is built by flags.RequestContext, from the authenticated principal and the incoming metadata,
+// then completed by the given functions.
+func FeatureFlagsUnaryInterceptor(fns ...FlagsContextFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withFlagsContext(ctx, info.FullMethod, fns), req)
+	}
+}
+
+// FeatureFlagsStreamInterceptor is the stream counterpart of FeatureFlagsUnaryInterceptor.
+func FeatureFlagsStreamInterceptor(fns ...FlagsContextFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = withFlagsContext(ss.Context(), info.FullMethod, fns)
+		return handler(srv, wrapped)
+	}
+}
+
+func withFlagsContext(ctx context.Context, fullMethod string, fns []FlagsContextFunc) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// The metadata keys are canonicalized, so they are matched like HTTP headers.
+	headers := make(http.Header, len(md))
+	for k, values := range md {
+		for _, v := range values {
+			headers.Add(k, v)
+		}
+	}
+
+	ec := flags.RequestContext(ctx, headers)
+	for _, fn := range fns {
+		fn(ctx, fullMethod, ec)
+	}
+
+	return flags.NewContext(ctx, ec)
+}
@@ -0,0 +1,78 @@
+package scrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/starclusterteam/go-starbox/health"
+)
+
+const defaultHealthCheckInterval = 5 * time.Second
+
+// healthSync periodically evaluates the health registry and publishes the result as the
+// grpc_health_v1 serving status of the server and of each registered service.
+type healthSync struct {
+	registry *health.Registry
+	server   *grpchealth.Server
+	interval time.Duration
+	services []string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newHealthSync(registry *health.Registry, interval time.Duration) *healthSync {
+	return &healthSync{
+		registry: registry,
+		server:   grpchealth.NewServer(),
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (h *healthSync) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.update()
+
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthSync) update() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+
+	report := h.registry.Readiness(ctx)
+
+	h.server.SetServingStatus("", servingStatus(report.Service("")))
+	for _, s := range h.services {
+		h.server.SetServingStatus(s, servingStatus(report.Service(s)))
+	}
+}
+
+// shutdown stops the updates and sets all the services as not serving.
+func (h *healthSync) shutdown() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		h.server.Shutdown()
+	})
+}
+
+func servingStatus(s health.Status) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	if s == health.StatusUp {
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	}
+
+	return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/starclusterteam/go-starbox/log"
@@ -17,11 +19,30 @@ type key int
 // Context keys
 const (
 	_ key = iota
+	// Deprecated: the logger is stored with log.WithContext.
 	LOGGERKEY
 )
 
 // LoggerInterceptor is a gRPC server-side interceptor that logs requests.
 func LoggerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return logUnary(ctx, req, info, handler, nil)
+}
+
+// SampledLoggerInterceptor is a LoggerInterceptor logging only the calls kept by the sampling, see
+// log.RequestSampling.
+func SampledLoggerInterceptor(sampling *log.RequestSampling) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return logUnary(ctx, req, info, handler, sampling)
+	}
+}
+
+func logUnary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+	sampling *log.RequestSampling,
+) (interface{}, error) {
 	if info.FullMethod == "/grpc.health.v1.Health/Check" {
 		return handler(ctx, req)
 	}
@@ -38,9 +59,14 @@ func LoggerInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySer
 
 	resp, err := handler(ctx, req)
 
+	latency := time.Since(start)
+	if !sampling.Sample(err != nil, latency) {
+		return resp, err
+	}
+
 	// logger can contain data altered by request
 	logger := GetLogger(ctx).
-		With("latency", time.Since(start).String())
+		With("latency", latency.String())
 
 	if err != nil {
 		logger = logger.
@@ -53,18 +79,76 @@ func LoggerInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySer
 	return resp, err
 }
 
-// GetLogger returns a logger scoped to request
-func GetLogger(ctx context.Context) log.Interface {
-	rv := ctx.Value(LOGGERKEY)
-	if rv != nil {
-		return rv.(log.Interface)
+// LoggerStreamInterceptor is a gRPC server-side interceptor that logs streams, the stream counterpart of
+// LoggerInterceptor. The numbers of messages received and sent are logged when the stream ends.
+func LoggerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return logStream(srv, ss, info, handler, nil)
+}
+
+// SampledLoggerStreamInterceptor is the stream counterpart of SampledLoggerInterceptor.
+func SampledLoggerStreamInterceptor(sampling *log.RequestSampling) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return logStream(srv, ss, info, handler, sampling)
+	}
+}
+
+func logStream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+	sampling *log.RequestSampling,
+) error {
+	if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.Health/") {
+		return handler(srv, ss)
+	}
+
+	start := time.Now()
+
+	var received, sent int64
+	stream := &observedServerStream{
+		ServerStream: ss,
+		ctx: SetLogger(
+			ss.Context(),
+			GetLogger(ss.Context()).
+				With("request_id", generateID()).
+				With("method", info.FullMethod),
+		),
+		onRecv: func() { atomic.AddInt64(&received, 1) },
+		onSend: func() { atomic.AddInt64(&sent, 1) },
+	}
+
+	err := handler(srv, stream)
+
+	latency := time.Since(start)
+	if !sampling.Sample(err != nil, latency) {
+		return err
+	}
+
+	logger := GetLogger(stream.ctx).
+		With("latency", latency.String()).
+		With("messages_received", atomic.LoadInt64(&received)).
+		With("messages_sent", atomic.LoadInt64(&sent))
+
+	if err != nil {
+		logger = logger.
+			With("error", err).
+			With("code", grpc.Code(err))
 	}
-	return log.Logger()
+
+	logger.Info("stream")
+
+	return err
+}
+
+// GetLogger returns a logger scoped to request, see log.FromContext.
+func GetLogger(ctx context.Context) log.Interface {
+	return log.FromContext(ctx)
 }
 
-// SetLogger sets logger in a context
+// SetLogger sets logger in a context, see log.WithContext.
 func SetLogger(ctx context.Context, logger log.Interface) context.Context {
-	return context.WithValue(ctx, LOGGERKEY, logger)
+	return log.WithContext(ctx, logger)
 }
 
 func generateID() string {
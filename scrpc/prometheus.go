@@ -2,6 +2,7 @@ package scrpc
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,9 +17,12 @@ func init() {
 }
 
 type serverMetrics struct {
-	totalRequests         *prometheus.CounterVec
-	totalRequestsPerRoute *prometheus.CounterVec
-	requestLatency        *prometheus.HistogramVec
+	totalRequests          *prometheus.CounterVec
+	totalRequestsPerRoute  *prometheus.CounterVec
+	requestLatency         *prometheus.HistogramVec
+	streamDuration         *prometheus.HistogramVec
+	streamMessagesReceived *prometheus.CounterVec
+	streamMessagesSent     *prometheus.CounterVec
 }
 
 func newServerMetrics() *serverMetrics {
@@ -48,11 +52,43 @@ func newServerMetrics() *serverMetrics {
 		[]string{"method"},
 	)
 
+	s.streamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "incoming_grpc_stream_duration_milliseconds",
+			Help:    "A histogram of the duration of gRPC streams in milliseconds.",
+			Buckets: prometheus.ExponentialBuckets(25, 4, 8),
+		},
+		[]string{"method"},
+	)
+
+	s.streamMessagesReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "incoming_grpc_stream_messages_received_total",
+			Help: "The number of messages received on gRPC streams.",
+		},
+		[]string{"method"},
+	)
+
+	s.streamMessagesSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "incoming_grpc_stream_messages_sent_total",
+			Help: "The number of messages sent on gRPC streams.",
+		},
+		[]string{"method"},
+	)
+
 	return &s
 }
 
 func (m *serverMetrics) mustRegister() {
-	prometheus.MustRegister(m.totalRequests, m.totalRequestsPerRoute, m.requestLatency)
+	prometheus.MustRegister(
+		m.totalRequests,
+		m.totalRequestsPerRoute,
+		m.requestLatency,
+		m.streamDuration,
+		m.streamMessagesReceived,
+		m.streamMessagesSent,
+	)
 }
 
 func (m *serverMetrics) UnaryServerInterceptor() func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
@@ -81,3 +117,38 @@ func (m *serverMetrics) UnaryServerInterceptor() func(ctx context.Context, req i
 		return resp, err
 	}
 }
+
+func (m *serverMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.Health/") {
+			return handler(srv, ss)
+		}
+
+		received := m.streamMessagesReceived.With(prometheus.Labels{"method": info.FullMethod})
+		sent := m.streamMessagesSent.With(prometheus.Labels{"method": info.FullMethod})
+
+		start := time.Now()
+		err := handler(srv, &observedServerStream{
+			ServerStream: ss,
+			ctx:          ss.Context(),
+			onRecv:       received.Inc,
+			onSend:       sent.Inc,
+		})
+		st, _ := status.FromError(err)
+
+		m.streamDuration.With(prometheus.Labels{
+			"method": info.FullMethod,
+		}).Observe(float64(time.Since(start) / time.Millisecond))
+
+		m.totalRequests.With(prometheus.Labels{
+			"status": st.Code().String(),
+		}).Inc()
+
+		m.totalRequestsPerRoute.With(prometheus.Labels{
+			"method": info.FullMethod,
+			"status": st.Code().String(),
+		}).Inc()
+
+		return err
+	}
+}
@@ -0,0 +1,148 @@
+package scrpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/ratelimit"
+)
+
+// RateLimitKeyFunc returns the key whose limit is consumed by a call. Calls with an empty key are not limited.
+type RateLimitKeyFunc func(ctx context.Context, fullMethod string) string
+
+// RateLimitByPeer limits the calls per client IP address.
+func RateLimitByPeer(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+// RateLimitByMetadata limits the calls per value of the given metadata key, e.g. an API key.
+func RateLimitByMetadata(key string) RateLimitKeyFunc {
+	return func(ctx context.Context, _ string) string {
+		values := metadata.ValueFromIncomingContext(ctx, key)
+		if len(values) == 0 {
+			return ""
+		}
+
+		return values[0]
+	}
+}
+
+// RateLimitByPrincipal limits the calls per authenticated principal, see auth.FromContext and WithAuth.
+func RateLimitByPrincipal(ctx context.Context, _ string) string {
+	p, ok := auth.FromContext(ctx)
+	if !ok || p == nil {
+		return ""
+	}
+
+	return p.String()
+}
+
+// RateLimitByMethod limits the calls per method, sharing the limit between all the clients of the method.
+func RateLimitByMethod(_ context.Context, fullMethod string) string {
+	return fullMethod
+}
+
+// RateLimitUnaryInterceptor is a gRPC server-side interceptor limiting the calls using the limiter and
+// the key returned by keyFunc. Calls over the limit fail with ResourceExhausted and a RetryInfo detail.
+// The ratelimit-limit, ratelimit-remaining and ratelimit-reset headers are sent with every response.
+// Calls are let through if the limiter fails. A nil keyFunc limits the calls per client IP address, see
+// RateLimitByPeer.
+func RateLimitUnaryInterceptor(limiter *ratelimit.Limiter, keyFunc RateLimitKeyFunc) grpc.UnaryServerInterceptor {
+	if keyFunc == nil {
+		keyFunc = RateLimitByPeer
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkRateLimit(ctx, limiter, keyFunc, info.FullMethod, grpc.SetHeader); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor is the stream counterpart of RateLimitUnaryInterceptor. The limit is
+// checked once, when the stream is opened.
+func RateLimitStreamInterceptor(limiter *ratelimit.Limiter, keyFunc RateLimitKeyFunc) grpc.StreamServerInterceptor {
+	if keyFunc == nil {
+		keyFunc = RateLimitByPeer
+	}
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		setHeader := func(_ context.Context, md metadata.MD) error {
+			return ss.SetHeader(md)
+		}
+
+		if err := checkRateLimit(ss.Context(), limiter, keyFunc, info.FullMethod, setHeader); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func checkRateLimit(
+	ctx context.Context,
+	limiter *ratelimit.Limiter,
+	keyFunc RateLimitKeyFunc,
+	fullMethod string,
+	setHeader func(context.Context, metadata.MD) error,
+) error {
+	key := keyFunc(ctx, fullMethod)
+	if key == "" {
+		return nil
+	}
+
+	res, err := limiter.Allow(ctx, key)
+	if err != nil {
+		GetLogger(ctx).Errorf("Rate limit check failed: %v", err)
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", strconv.Itoa(ceilSeconds(res.ResetAfter)),
+	)
+	if !res.Allowed {
+		md.Set("retry-after", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+	_ = setHeader(ctx, md)
+
+	if res.Allowed {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "too many requests")
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)}); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
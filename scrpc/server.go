@@ -1,12 +1,13 @@
 package scrpc
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"net"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -14,12 +15,19 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/certwatch"
 	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/health"
 	"github.com/starclusterteam/go-starbox/log"
+	"github.com/starclusterteam/go-starbox/ratelimit"
 	"github.com/starclusterteam/go-starbox/tracing"
 
 	// This metrics import is used to initialize Prometheus HTTP endpoint server.
@@ -43,41 +51,113 @@ const (
 type Server struct {
 	addr   string
 	server *grpc.Server
+	health *healthSync
+	certs  *certwatch.Watcher
 }
 
-// NewServer creates a new server for gRPC
+// NewServer creates a new server for gRPC. The calls go through the interceptors added with
+// WithUnaryInterceptorsBefore, then through the built-in tracing, logging, metrics, authentication, rate
+// limit, feature flags, recovery and error interceptors, and finally through the ones added with
+// WithUnaryInterceptorsAfter.
 func NewServer(cb func(*grpc.Server), opts ...ServerOption) (*Server, error) {
 	options := options{
 		addr:    fmt.Sprintf(":%d", config.Int(portEnv, defaultPort)),
 		tlsAddr: fmt.Sprintf(":%d", config.Int(tlsPortEnv, defaultTLSPort)),
 		tracer:  tracing.Tracer,
+
+		healthRegistry:      health.DefaultRegistry,
+		healthCheckInterval: defaultHealthCheckInterval,
+
+		requestSampling: log.DefaultRequestSampling,
 	}
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	creds, err := resolveTLSConfig(options.tlsConfig)
+	creds, certs, err := resolveTLSConfig(options.tlsConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to resolve TLS config")
 	}
 
-	server := grpc.NewServer(
-		grpc.ChainStreamInterceptor(grpc_prometheus.StreamServerInterceptor),
-		grpc.ChainUnaryInterceptor(
-			tracingInterceptor(options.tracer, options.traceHealthCheck),
-			LoggerInterceptor,
-			defaultServerMetrics.UnaryServerInterceptor(),
-			recovery.UnaryServerInterceptor(),
-		),
+	streamInterceptors := append([]grpc.StreamServerInterceptor{}, options.streamBefore...)
+	unaryInterceptors := append([]grpc.UnaryServerInterceptor{}, options.unaryBefore...)
+
+	if !options.disableMetrics {
+		streamInterceptors = append(streamInterceptors, grpc_prometheus.StreamServerInterceptor)
+	}
+
+	if !options.disableTracing {
+		streamInterceptors = append(streamInterceptors, tracingStreamInterceptor(options.tracer, options.traceHealthCheck))
+		unaryInterceptors = append(unaryInterceptors, tracingInterceptor(options.tracer, options.traceHealthCheck))
+	}
+
+	if !options.disableLogging {
+		streamInterceptors = append(streamInterceptors, SampledLoggerStreamInterceptor(options.requestSampling))
+		unaryInterceptors = append(unaryInterceptors, SampledLoggerInterceptor(options.requestSampling))
+	}
+
+	if !options.disableMetrics {
+		streamInterceptors = append(streamInterceptors, defaultServerMetrics.StreamServerInterceptor())
+		unaryInterceptors = append(unaryInterceptors, defaultServerMetrics.UnaryServerInterceptor())
+	}
+
+	if options.authenticator != nil {
+		skip := func(fullMethod string) bool {
+			return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") || options.publicMethods[fullMethod]
+		}
+
+		streamInterceptors = append(streamInterceptors, AuthStreamInterceptor(options.authenticator, skip))
+		unaryInterceptors = append(unaryInterceptors, AuthUnaryInterceptor(options.authenticator, skip))
+	}
+
+	if options.rateLimiter != nil {
+		streamInterceptors = append(streamInterceptors, RateLimitStreamInterceptor(options.rateLimiter, options.rateLimitKey))
+		unaryInterceptors = append(unaryInterceptors, RateLimitUnaryInterceptor(options.rateLimiter, options.rateLimitKey))
+	}
+
+	if options.featureFlags {
+		streamInterceptors = append(streamInterceptors, FeatureFlagsStreamInterceptor(options.flagsContext...))
+		unaryInterceptors = append(unaryInterceptors, FeatureFlagsUnaryInterceptor(options.flagsContext...))
+	}
+
+	recoveryHandler := recovery.WithRecoveryHandlerContext(recoverPanic)
+	streamInterceptors = append(streamInterceptors, recovery.StreamServerInterceptor(recoveryHandler), ErrorStreamInterceptor)
+	unaryInterceptors = append(unaryInterceptors, recovery.UnaryServerInterceptor(recoveryHandler), ErrorInterceptor)
+
+	streamInterceptors = append(streamInterceptors, options.streamAfter...)
+	unaryInterceptors = append(unaryInterceptors, options.unaryAfter...)
+
+	grpcOptions := []grpc.ServerOption{
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
 		grpc.Creds(creds),
-	)
+	}
+	grpcOptions = append(grpcOptions, options.grpcOptions...)
 
-	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	server := grpc.NewServer(grpcOptions...)
+
+	hs := newHealthSync(options.healthRegistry, options.healthCheckInterval)
+	grpc_health_v1.RegisterHealthServer(server, hs.server)
 
 	// Run callback to add services
 	cb(server)
 
+	for name := range server.GetServiceInfo() {
+		if name != grpc_health_v1.Health_ServiceDesc.ServiceName {
+			hs.services = append(hs.services, name)
+		}
+	}
+
+	// Registered after listing the services, so their health is not reported.
+	if options.reflection {
+		reflection.Register(server)
+	}
+
+	if options.channelz {
+		channelz.RegisterChannelzServiceToServer(server)
+	}
+
 	var addr string
 	if options.tlsConfig != nil {
 		addr = options.tlsAddr
@@ -88,24 +168,64 @@ func NewServer(cb func(*grpc.Server), opts ...ServerOption) (*Server, error) {
 	return &Server{
 		server: server,
 		addr:   addr,
+		health: hs,
+		certs:  certs,
 	}, nil
 }
 
-// Run starts gRPC server.
+// Run starts gRPC server. It returns the error returned by grpc.Server.Serve, except if
+// that error is grpc.ErrServerStopped.
 func (s *Server) Run() error {
 	l, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return err
 	}
 
+	go s.health.run()
+
 	log.Infof("Running gRPC server on %s", l.Addr())
-	return s.server.Serve(l)
+	if err := s.server.Serve(l); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+
+	return nil
 }
 
-// GracefulStop stops the gRPC server gracefully. It stops the server to accept new
-// connections and RPCs and blocks until all the pending RPCs are finished.
+// GracefulStop stops the gRPC server gracefully. It sets all the services as not serving, stops the
+// server to accept new connections and RPCs and blocks until all the pending RPCs are finished.
 func (s *Server) GracefulStop() {
+	s.health.shutdown()
 	s.server.GracefulStop()
+	s.closeCerts()
+}
+
+// Stop gracefully stops the gRPC server, waiting for the pending RPCs to finish. If the
+// given context expires before that, all the remaining connections are closed forcefully
+// and the context error is returned.
+func (s *Server) Stop(ctx context.Context) error {
+	s.health.shutdown()
+	defer s.closeCerts()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Server) closeCerts() {
+	if s.certs != nil {
+		s.certs.Close()
+	}
 }
 
 func tracingInterceptor(tracer opentracing.Tracer, traceHealthCheck bool) grpc.UnaryServerInterceptor {
@@ -116,44 +236,39 @@ func tracingInterceptor(tracer opentracing.Tracer, traceHealthCheck bool) grpc.U
 	return otgrpc.OpenTracingServerInterceptor(tracer, otgrpc.IncludingSpans(excludeHealthCheckFromTrace()))
 }
 
-func resolveTLSConfig(tlsConfig *serverTLSConfig) (credentials.TransportCredentials, error) {
-	if tlsConfig == nil {
-		return nil, nil
+func tracingStreamInterceptor(tracer opentracing.Tracer, traceHealthCheck bool) grpc.StreamServerInterceptor {
+	if traceHealthCheck {
+		return otgrpc.OpenTracingStreamServerInterceptor(tracer)
 	}
 
-	var (
-		keyFile       = tlsConfig.keyFile
-		certFile      = tlsConfig.certFile
-		clientCaFiles = tlsConfig.clientCaFiles
-	)
+	return otgrpc.OpenTracingStreamServerInterceptor(tracer, otgrpc.IncludingSpans(excludeHealthCheckFromTrace()))
+}
 
-	// Load the certificates from disk.
-	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to load server key pair")
-	}
+// recoverPanic logs the panics recovered from handlers and fails the calls with an Internal status.
+func recoverPanic(ctx context.Context, p interface{}) error {
+	stack := make([]byte, 1<<16)
+	stackSize := runtime.Stack(stack, false)
 
-	// Create a certificate pool from the certificate authority.
-	certPool := x509.NewCertPool()
-	for _, clientCaFile := range clientCaFiles {
-		c, err := ioutil.ReadFile(clientCaFile)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to read ca cert")
-		}
+	GetLogger(ctx).
+		With("stack", string(stack[:stackSize])).
+		Errorf("grpc handler panic: %v", p)
 
-		// Append the client certificates from the CA.
-		if ok := certPool.AppendCertsFromPEM(c); !ok {
-			return nil, errors.Wrap(err, "failed to append ca certs")
-		}
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// resolveTLSConfig returns the server credentials, whose certificate and client CAs are reloaded when
+// their files change, and the watcher of the files to close with the server.
+func resolveTLSConfig(tlsConfig *serverTLSConfig) (credentials.TransportCredentials, *certwatch.Watcher, error) {
+	if tlsConfig == nil {
+		return nil, nil, nil
 	}
 
-	creds := credentials.NewTLS(&tls.Config{
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{certificate},
-		ClientCAs:    certPool,
-	})
+	watcher, err := certwatch.New(tlsConfig.certFile, tlsConfig.keyFile, tlsConfig.clientCaFiles)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load server certificates")
+	}
 
-	return creds, nil
+	return credentials.NewTLS(watcher.ServerTLSConfig(tls.RequireAndVerifyClientCert)), watcher, nil
 }
 
 type serverTLSConfig struct {
@@ -168,6 +283,32 @@ type options struct {
 	tlsAddr          string
 	tracer           opentracing.Tracer
 	tlsConfig        *serverTLSConfig
+
+	healthRegistry      *health.Registry
+	healthCheckInterval time.Duration
+
+	rateLimiter  *ratelimit.Limiter
+	rateLimitKey RateLimitKeyFunc
+
+	authenticator auth.Authenticator
+	publicMethods map[string]bool
+
+	featureFlags bool
+	flagsContext []FlagsContextFunc
+
+	unaryBefore  []grpc.UnaryServerInterceptor
+	unaryAfter   []grpc.UnaryServerInterceptor
+	streamBefore []grpc.StreamServerInterceptor
+	streamAfter  []grpc.StreamServerInterceptor
+	grpcOptions  []grpc.ServerOption
+
+	disableTracing  bool
+	disableLogging  bool
+	disableMetrics  bool
+	requestSampling *log.RequestSampling
+
+	reflection bool
+	channelz   bool
 }
 
 // ServerOption define a functional options used when creating a grpc server.
@@ -236,15 +377,163 @@ func WithServerTLS() ServerOption {
 	return WithServerTLSFromParams(tlsServerCert, tlsServerKey, tlsClientCAs)
 }
 
+// WithHealthRegistry sets the registry whose checks determine the grpc_health_v1 serving status.
+// It defaults to health.DefaultRegistry, a nil registry is ignored.
+func WithHealthRegistry(registry *health.Registry) ServerOption {
+	return func(o *options) {
+		if registry != nil {
+			o.healthRegistry = registry
+		}
+	}
+}
+
+// WithHealthCheckInterval sets how often the health checks are run to update the serving status. Defaults to 5s,
+// non-positive intervals are ignored.
+func WithHealthCheckInterval(d time.Duration) ServerOption {
+	return func(o *options) {
+		if d > 0 {
+			o.healthCheckInterval = d
+		}
+	}
+}
+
+// WithRateLimit limits the calls to the server using the limiter and the key returned by keyFunc,
+// see RateLimitUnaryInterceptor. A nil keyFunc limits per client IP address. Health checks are not limited.
+func WithRateLimit(limiter *ratelimit.Limiter, keyFunc RateLimitKeyFunc) ServerOption {
+	if keyFunc == nil {
+		keyFunc = RateLimitByPeer
+	}
+
+	return func(o *options) {
+		o.rateLimiter = limiter
+		o.rateLimitKey = func(ctx context.Context, fullMethod string) string {
+			if strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") {
+				return ""
+			}
+			return keyFunc(ctx, fullMethod)
+		}
+	}
+}
+
+// WithAuth authenticates the calls to the server using the given authenticator, see AuthUnaryInterceptor.
+// Health checks and the methods given to WithPublicMethods are not authenticated. Rate limits are
+// applied after authentication.
+func WithAuth(authenticator auth.Authenticator) ServerOption {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithPublicMethods opts the given methods out of the authentication enabled with WithAuth. Methods are
+// given by their full name, e.g. "/package.Service/Method".
+func WithPublicMethods(methods ...string) ServerOption {
+	return func(o *options) {
+		if o.publicMethods == nil {
+			o.publicMethods = make(map[string]bool)
+		}
+
+		for _, m := range methods {
+			o.publicMethods[m] = true
+		}
+	}
+}
+
+// WithFeatureFlags adds the feature flags evaluation context to the calls, see
+// FeatureFlagsUnaryInterceptor. It is added after authentication, so the flags can depend on the principal.
+func WithFeatureFlags(fns ...FlagsContextFunc) ServerOption {
+	return func(o *options) {
+		o.featureFlags = true
+		o.flagsContext = fns
+	}
+}
+
+// WithUnaryInterceptorsBefore adds unary interceptors running before the built-in ones, in the given order.
+// They see all the calls, including the ones rejected by the authentication and the rate limits.
+func WithUnaryInterceptorsBefore(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(o *options) {
+		o.unaryBefore = append(o.unaryBefore, interceptors...)
+	}
+}
+
+// WithUnaryInterceptorsAfter adds unary interceptors running after the built-in ones, just before the
+// handlers. Their panics are recovered and the *errors.Error they return are converted to statuses.
+func WithUnaryInterceptorsAfter(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(o *options) {
+		o.unaryAfter = append(o.unaryAfter, interceptors...)
+	}
+}
+
+// WithStreamInterceptorsBefore is the stream counterpart of WithUnaryInterceptorsBefore.
+func WithStreamInterceptorsBefore(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(o *options) {
+		o.streamBefore = append(o.streamBefore, interceptors...)
+	}
+}
+
+// WithStreamInterceptorsAfter is the stream counterpart of WithUnaryInterceptorsAfter.
+func WithStreamInterceptorsAfter(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(o *options) {
+		o.streamAfter = append(o.streamAfter, interceptors...)
+	}
+}
+
+// WithGRPCServerOptions adds options to the underlying grpc.NewServer, e.g. keepalive parameters or
+// message size limits. They are applied after the built-in options.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(o *options) {
+		o.grpcOptions = append(o.grpcOptions, opts...)
+	}
+}
+
+// WithoutTracing disables the built-in tracing interceptors.
+func WithoutTracing() ServerOption {
+	return func(o *options) {
+		o.disableTracing = true
+	}
+}
+
+// WithoutLogging disables the built-in logging interceptors.
+func WithoutLogging() ServerOption {
+	return func(o *options) {
+		o.disableLogging = true
+	}
+}
+
+// WithRequestLogSampling samples the logs of the calls, see log.RequestSampling. It defaults to
+// log.DefaultRequestSampling.
+func WithRequestLogSampling(sampling *log.RequestSampling) ServerOption {
+	return func(o *options) {
+		o.requestSampling = sampling
+	}
+}
+
+// WithoutMetrics disables the built-in Prometheus interceptors.
+func WithoutMetrics() ServerOption {
+	return func(o *options) {
+		o.disableMetrics = true
+	}
+}
+
+// WithReflection registers the gRPC server reflection service, used by tools like grpcurl.
+func WithReflection() ServerOption {
+	return func(o *options) {
+		o.reflection = true
+	}
+}
+
+// WithChannelz registers the channelz service, exposing runtime information about the server.
+func WithChannelz() ServerOption {
+	return func(o *options) {
+		o.channelz = true
+	}
+}
+
 func excludeHealthCheckFromTrace() otgrpc.SpanInclusionFunc {
 	return func(
 		parentSpanCtx opentracing.SpanContext,
 		method string,
 		req, resp interface{}) bool {
 
-		if method == "/grpc.health.v1.Health/Check" {
-			return false
-		}
-		return true
+		return !strings.HasPrefix(method, "/grpc.health.v1.Health/")
 	}
 }
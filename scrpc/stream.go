@@ -0,0 +1,39 @@
+package scrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// observedServerStream overrides the context of a server stream and calls the given hooks for each
+// message received or sent.
+type observedServerStream struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	onRecv func()
+	onSend func()
+}
+
+func (s *observedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *observedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.onRecv != nil {
+		s.onRecv()
+	}
+
+	return err
+}
+
+func (s *observedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil && s.onSend != nil {
+		s.onSend()
+	}
+
+	return err
+}
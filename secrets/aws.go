@@ -0,0 +1,244 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/config"
+)
+
+const (
+	awsRegionEnv          = "AWS_REGION"
+	awsAccessKeyIDEnv     = "AWS_ACCESS_KEY_ID"
+	awsSecretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	awsSessionTokenEnv    = "AWS_SESSION_TOKEN"
+)
+
+// AWSCredentials are the credentials used to sign the requests to AWS.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSSecretsManager reads the secrets of AWS Secrets Manager, or of a service implementing its
+// GetSecretValue API. The references have the form "<secret id>#<key>", the key selecting a value of a
+// JSON secret. Without key, the whole secret string is returned.
+type AWSSecretsManager struct {
+	region      string
+	credentials AWSCredentials
+	endpoint    string
+	client      *http.Client
+
+	now func() time.Time
+}
+
+// AWSOption is a functional option for NewAWSSecretsManager.
+type AWSOption func(*AWSSecretsManager)
+
+// WithAWSEndpoint sets the URL of the service. Defaults to https://secretsmanager.<region>.amazonaws.com.
+func WithAWSEndpoint(endpoint string) AWSOption {
+	return func(m *AWSSecretsManager) {
+		m.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithAWSHTTPClient sets the client used for the requests. Defaults to a client with a 10 seconds timeout.
+func WithAWSHTTPClient(client *http.Client) AWSOption {
+	return func(m *AWSSecretsManager) {
+		m.client = client
+	}
+}
+
+// NewAWSSecretsManager returns a provider reading the secrets from the given region, signing the
+// requests with the credentials.
+func NewAWSSecretsManager(region string, credentials AWSCredentials, opts ...AWSOption) *AWSSecretsManager {
+	m := &AWSSecretsManager{
+		region:      region,
+		credentials: credentials,
+		endpoint:    fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	return m
+}
+
+// AWSSecretsManagerFromEnv returns a provider configured from the AWS_REGION, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func AWSSecretsManagerFromEnv(opts ...AWSOption) (*AWSSecretsManager, error) {
+	region := config.String(awsRegionEnv, "")
+	if region == "" {
+		return nil, errors.Errorf("%s must be set", awsRegionEnv)
+	}
+
+	secretAccessKey, err := config.LookupSecret(context.Background(), awsSecretAccessKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionToken, err := config.LookupSecret(context.Background(), awsSessionTokenEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAWSSecretsManager(region, AWSCredentials{
+		AccessKeyID:     config.String(awsAccessKeyIDEnv, ""),
+		SecretAccessKey: secretAccessKey.Value(),
+		SessionToken:    sessionToken.Value(),
+	}, opts...), nil
+}
+
+// GetSecret implements config.SecretProvider.
+func (m *AWSSecretsManager) GetSecret(ctx context.Context, ref string) (string, error) {
+	id, key := splitRef(ref)
+
+	payload, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	m.sign(req, payload)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to call secrets manager")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read secrets manager response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type string `json:"__type"`
+		}
+		json.Unmarshal(body, &apiErr)
+
+		if strings.HasSuffix(apiErr.Type, "ResourceNotFoundException") {
+			return "", errors.Wrapf(ErrNotFound, "aws secret %s", id)
+		}
+
+		return "", errors.Errorf("secrets manager returned status %d (%s) for secret %s", resp.StatusCode, apiErr.Type, id)
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", errors.Wrap(err, "failed to decode secrets manager response")
+	}
+
+	if key == "" {
+		return out.SecretString, nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(out.SecretString), &values); err != nil {
+		return "", errors.Errorf("aws secret %s is not a JSON object, no key can be selected", id)
+	}
+
+	return secretKey(values, key, id)
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+func (m *AWSSecretsManager) sign(req *http.Request, payload []byte) {
+	now := m.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if m.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", m.credentials.SessionToken)
+	}
+
+	// The canonical and the signed headers are sorted by name.
+	headers := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if m.credentials.SessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	sort.Strings(headers)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(payload),
+	}, "\n")
+
+	scope := date + "/" + m.region + "/secretsmanager/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+m.credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, m.region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		m.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	// url.Values.Encode sorts by key, and escapes spaces as "+" where AWS expects "%20".
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
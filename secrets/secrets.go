@@ -0,0 +1,87 @@
+// Package secrets implements config.SecretProvider for secret stores, so that configuration values can
+// reference secrets instead of holding them:
+//
+//	vault, err := secrets.VaultFromEnv()
+//	...
+//	config.RegisterSecretProvider("vault", secrets.NewCache(vault, 5*time.Minute))
+//
+//	// DATABASE_URL=vault://secret/app#database_url
+//	url, err := config.LookupSecret(ctx, "DATABASE_URL")
+package secrets
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// ErrNotFound is returned by the providers when the secret, or its key, does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// splitRef splits a reference into its path and the key after '#', if any.
+func splitRef(ref string) (path, key string) {
+	path, key, _ = strings.Cut(ref, "#")
+	return path, key
+}
+
+// Cache caches the secrets returned by a provider. It is safe for concurrent use.
+type Cache struct {
+	provider config.SecretProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// NewCache returns a provider caching the secrets of p for the given duration. Once expired, a secret is
+// fetched again on its next use; if that fails, the expired secret is still used and the error logged.
+func NewCache(p config.SecretProvider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: p,
+		ttl:      ttl,
+		entries:  make(map[string]cacheEntry),
+		now:      time.Now,
+	}
+}
+
+// GetSecret implements config.SecretProvider.
+func (c *Cache) GetSecret(ctx context.Context, ref string) (string, error) {
+	c.mu.Lock()
+	entry, cached := c.entries[ref]
+	c.mu.Unlock()
+
+	now := c.now()
+	if cached && now.Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := c.provider.GetSecret(ctx, ref)
+	if err != nil {
+		if cached && !errors.Is(err, ErrNotFound) {
+			path, _ := splitRef(ref)
+			log.FromContext(ctx).With("secret", path).Warningf("failed to refresh secret, using the cached value: %v", err)
+			return entry.value, nil
+		}
+
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[ref] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return value, nil
+}
@@ -0,0 +1,206 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/config"
+)
+
+type fakeProvider struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+
+	v, ok := p.values[ref]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return v, nil
+}
+
+func TestCache(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"app#password": "v1"}}
+	c := NewCache(p, time.Minute)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	get := func() string {
+		v, err := c.GetSecret(context.Background(), "app#password")
+		require.NoError(t, err)
+		return v
+	}
+
+	assert.Equal(t, "v1", get())
+	assert.Equal(t, "v1", get())
+	assert.Equal(t, 1, p.calls)
+
+	// Refreshed once expired.
+	p.values["app#password"] = "v2"
+	now = now.Add(time.Minute)
+	assert.Equal(t, "v2", get())
+	assert.Equal(t, 2, p.calls)
+
+	// The expired value is kept if the refresh fails.
+	p.err = errors.New("connection refused")
+	now = now.Add(time.Minute)
+	assert.Equal(t, "v2", get())
+
+	_, err := c.GetSecret(context.Background(), "other")
+	assert.Error(t, err)
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/app/db":
+			w.Write([]byte(`{"data": {"data": {"url": "mysql://db", "port": 3306}, "metadata": {"version": 2}}}`))
+		case "/v1/secret/data/app/sentry":
+			w.Write([]byte(`{"data": {"data": {"dsn": "https://sentry"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	v := NewVault(server.URL, "root")
+
+	tests := []struct {
+		ref   string
+		value string
+		err   error
+	}{
+		{"secret/app/db#url", "mysql://db", nil},
+		{"secret/app/db#port", "3306", nil},
+		{"secret/app/sentry", "https://sentry", nil},
+		{"secret/app/db#user", "", ErrNotFound},
+		{"secret/app/missing#url", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		value, err := v.GetSecret(context.Background(), tt.ref)
+		if tt.err != nil {
+			assert.True(t, errors.Is(err, tt.err), tt.ref)
+			continue
+		}
+
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.value, value, tt.ref)
+	}
+
+	_, err := v.GetSecret(context.Background(), "secret/app/db")
+	assert.Error(t, err, "several keys")
+
+	_, err = NewVault(server.URL, "invalid").GetSecret(context.Background(), "secret/app/db#url")
+	assert.Error(t, err)
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "20240102T030405Z", r.Header.Get("X-Amz-Date"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature="))
+
+		var in struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+
+		switch in.SecretId {
+		case "prod/db":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"username": "app", "password": "p4ss"}`})
+		case "prod/token":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "t0ken"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	m := NewAWSSecretsManager("eu-west-1", AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, WithAWSEndpoint(server.URL))
+	m.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	value, err := m.GetSecret(context.Background(), "prod/db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "p4ss", value)
+
+	value, err = m.GetSecret(context.Background(), "prod/token")
+	require.NoError(t, err)
+	assert.Equal(t, "t0ken", value)
+
+	_, err = m.GetSecret(context.Background(), "prod/missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestResolveWithProvider(t *testing.T) {
+	config.RegisterSecretProvider("fake", NewCache(&fakeProvider{values: map[string]string{"db#url": "mysql://db"}}, time.Minute))
+
+	t.Setenv("SECRETS_TEST_DATABASE_URL", "fake://db#url")
+
+	secret, err := config.LookupSecret(context.Background(), "SECRETS_TEST_DATABASE_URL")
+	require.NoError(t, err)
+	assert.Equal(t, "mysql://db", secret.Value())
+}
+
+func TestAWSSign(t *testing.T) {
+	// The signatures are computed following the AWS Signature Version 4 specification.
+	tests := []struct {
+		name          string
+		token         string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name:          "without session token",
+			signedHeaders: "content-type;host;x-amz-date;x-amz-target",
+			signature:     "f65a3f12e43e6ebe286939e31c5b1f92093adf039ce6e900bac95c9eb4c8724e",
+		},
+		{
+			name:          "with session token",
+			token:         "T0KEN",
+			signedHeaders: "content-type;host;x-amz-date;x-amz-security-token;x-amz-target",
+			signature:     "0f8eb9d9e4307b0974005645dd9a3e0121f9f9c399faa79dd20b54c8bd62db44",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewAWSSecretsManager("eu-west-1", AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: tt.token})
+			m.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+			payload := []byte(`{"SecretId":"prod/db"}`)
+			req, err := http.NewRequest("POST", "https://secretsmanager.eu-west-1.amazonaws.com/", strings.NewReader(string(payload)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+			req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+			m.sign(req, payload)
+
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request, SignedHeaders="+
+				tt.signedHeaders+", Signature="+tt.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
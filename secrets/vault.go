@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/config"
+)
+
+const (
+	vaultAddrEnv      = "VAULT_ADDR"
+	vaultTokenEnv     = "VAULT_TOKEN"
+	vaultNamespaceEnv = "VAULT_NAMESPACE"
+)
+
+// Vault reads the secrets of a Vault KV version 2 secrets engine. The references have the form
+// "<mount>/<path>#<key>", e.g. "secret/app#password" for the password key of the app secret of the engine
+// mounted at secret/. The key can be omitted if the secret has a single key.
+type Vault struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// VaultOption is a functional option for NewVault.
+type VaultOption func(*Vault)
+
+// WithVaultNamespace sets the Vault Enterprise namespace of the requests.
+func WithVaultNamespace(namespace string) VaultOption {
+	return func(v *Vault) {
+		v.namespace = namespace
+	}
+}
+
+// WithVaultHTTPClient sets the client used for the requests. Defaults to a client with a 10 seconds timeout.
+func WithVaultHTTPClient(client *http.Client) VaultOption {
+	return func(v *Vault) {
+		v.client = client
+	}
+}
+
+// NewVault returns a provider reading the secrets from the Vault server at addr, e.g.
+// "https://vault:8200", authenticated with the given token.
+func NewVault(addr, token string, opts ...VaultOption) *Vault {
+	v := &Vault{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, o := range opts {
+		o(v)
+	}
+
+	return v
+}
+
+// VaultFromEnv returns a provider configured from the VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE
+// environment variables. The token can itself be a file:// or env: reference, see config.ResolveSecret.
+func VaultFromEnv(opts ...VaultOption) (*Vault, error) {
+	addr := config.String(vaultAddrEnv, "")
+	if addr == "" {
+		return nil, errors.Errorf("%s must be set", vaultAddrEnv)
+	}
+
+	token, err := config.LookupSecret(context.Background(), vaultTokenEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if ns := config.String(vaultNamespaceEnv, ""); ns != "" {
+		opts = append([]VaultOption{WithVaultNamespace(ns)}, opts...)
+	}
+
+	return NewVault(addr, token.Value(), opts...), nil
+}
+
+// GetSecret implements config.SecretProvider.
+func (v *Vault) GetSecret(ctx context.Context, ref string) (string, error) {
+	path, key := splitRef(ref)
+
+	mount, secretPath, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok {
+		return "", errors.Errorf("invalid vault secret %q, expected <mount>/<path>", path)
+	}
+
+	u := fmt.Sprintf("%s/v1/%s/data/%s", v.addr, url.PathEscape(mount), escapePath(secretPath))
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to call vault")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", errors.Wrapf(ErrNotFound, "vault secret %s", path)
+	case resp.StatusCode != http.StatusOK:
+		return "", errors.Errorf("vault returned status %d for secret %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrap(err, "failed to decode vault response")
+	}
+
+	return secretKey(body.Data.Data, key, path)
+}
+
+// secretKey returns the value of the key of a secret holding several values, or its single value if
+// no key is given.
+func secretKey(values map[string]interface{}, key, path string) (string, error) {
+	if key == "" {
+		if len(values) != 1 {
+			return "", errors.Errorf("secret %s has %d keys, one must be given", path, len(values))
+		}
+
+		for k := range values {
+			key = k
+		}
+	}
+
+	value, ok := values[key]
+	if !ok {
+		return "", errors.Wrapf(ErrNotFound, "key %s of secret %s", key, path)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	b, err := json.Marshal(value)
+	return string(b), errors.Wrapf(err, "failed to encode key %s of secret %s", key, path)
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	return strings.Join(segments, "/")
+}
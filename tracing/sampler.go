@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// dynamicSampler is a probabilistic sampler whose rate follows the TRACING_SAMPLE_RATE dynamic value.
+type dynamicSampler struct {
+	sampler     atomic.Pointer[jaeger.ProbabilisticSampler]
+	unsubscribe func()
+}
+
+// parseSampleRate parses a sample rate, which must be between 0 and 1.
+func parseSampleRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if rate < 0 || rate > 1 {
+		return 0, errors.Errorf("sample rate %v is not between 0 and 1", rate)
+	}
+
+	return rate, nil
+}
+
+func newDynamicSampler(rate *config.Value[float64]) (*dynamicSampler, error) {
+	sampler, err := jaeger.NewProbabilisticSampler(rate.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &dynamicSampler{}
+	s.sampler.Store(sampler)
+
+	s.unsubscribe = rate.Subscribe(func(old, new float64) {
+		sampler, err := jaeger.NewProbabilisticSampler(new)
+		if err != nil {
+			log.Errorf("Invalid tracing sample rate, keeping %v: %v", old, err)
+			return
+		}
+
+		s.sampler.Store(sampler)
+		log.Infof("Tracing sample rate changed from %v to %v", old, new)
+	})
+
+	return s, nil
+}
+
+func (s *dynamicSampler) IsSampled(id jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	return s.sampler.Load().IsSampled(id, operation)
+}
+
+func (s *dynamicSampler) Close() {
+	s.unsubscribe()
+}
+
+func (s *dynamicSampler) Equal(other jaeger.Sampler) bool {
+	return s == other
+}
@@ -0,0 +1,18 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSampleRate(t *testing.T) {
+	rate, err := parseSampleRate("0.25")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, rate)
+
+	for _, s := range []string{"often", "-0.1", "1.5"} {
+		_, err := parseSampleRate(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/starclusterteam/go-starbox/config"
 	"github.com/starclusterteam/go-starbox/log"
-	"github.com/uber/jaeger-client-go"
 	jaegerconfig "github.com/uber/jaeger-client-go/config"
 	"github.com/uber/jaeger-client-go/zipkin"
 )
 
+const sampleRateEnv = "TRACING_SAMPLE_RATE"
+
 // Tracer is a global opentracing tracer.
 // If the TRACING_ENABLED environment variable is set to false, it will default to a noop tracer.
 var Tracer opentracing.Tracer = &opentracing.NoopTracer{}
@@ -36,21 +37,38 @@ func NewTracer() (opentracing.Tracer, error) {
 		return nil, errors.Wrap(err, "failed to build jaeger config from env")
 	}
 
-	if cfg.Sampler.Type == "" {
-		cfg.Sampler.Type = jaeger.SamplerTypeProbabilistic
-		if cfg.Sampler.Param == 0 {
-			cfg.Sampler.Param = 0.01
-		}
-	}
-
 	zipkinPropagator := zipkin.NewZipkinB3HTTPHeaderPropagator()
-
-	tracer, _, err := cfg.NewTracer(
+	opts := []jaegerconfig.Option{
 		jaegerconfig.Injector(opentracing.HTTPHeaders, zipkinPropagator),
 		jaegerconfig.Injector(opentracing.TextMap, zipkinPropagator),
 		jaegerconfig.Extractor(opentracing.HTTPHeaders, zipkinPropagator),
 		jaegerconfig.Extractor(opentracing.TextMap, zipkinPropagator),
-	)
+	}
+
+	if cfg.Sampler.Type == "" {
+		// Without sampler configured for jaeger, traces are sampled with the TRACING_SAMPLE_RATE probability,
+		// which can be changed at runtime, see config.Store.
+		defaultRate := cfg.Sampler.Param
+		if defaultRate == 0 {
+			defaultRate = 0.01
+		}
+
+		rate, err := config.NewValue(config.DefaultStore, sampleRateEnv, defaultRate, parseSampleRate)
+		if rate == nil {
+			return nil, errors.Wrap(err, "failed to create sample rate")
+		}
+		if err != nil {
+			log.Warningf("Failed to configure the tracing sample rate: %v", err)
+		}
+
+		sampler, err := newDynamicSampler(rate)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create sampler")
+		}
+		opts = append(opts, jaegerconfig.Sampler(sampler))
+	}
+
+	tracer, _, err := cfg.NewTracer(opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize jaeger tracer")
 	}
@@ -0,0 +1,289 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// Formats of the access log.
+const (
+	// AccessLogStructured logs the requests with the logger of the request, see GetLogger.
+	AccessLogStructured = "structured"
+	// AccessLogCombined writes the requests in the Apache/NCSA combined log format.
+	AccessLogCombined = "combined"
+)
+
+type accessLogOptions struct {
+	format    string
+	writer    io.Writer
+	sampling  *log.RequestSampling
+	bodyLimit int
+}
+
+// AccessLogOption is a functional option for AccessLogMiddleware and WithAccessLog.
+type AccessLogOption func(*accessLogOptions)
+
+// WithAccessLogFormat sets the format of the access log, AccessLogStructured by default.
+func WithAccessLogFormat(format string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.format = format
+	}
+}
+
+// WithAccessLogWriter sets the writer of the AccessLogCombined format, os.Stdout by default.
+func WithAccessLogWriter(w io.Writer) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.writer = w
+	}
+}
+
+// WithAccessLogSampling samples the requests logged, see log.RequestSampling. All the requests are logged
+// by default.
+func WithAccessLogSampling(sampling *log.RequestSampling) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.sampling = sampling
+	}
+}
+
+// WithAccessLogBodies captures up to limit bytes of the request and response bodies, logged redacted with
+// the AccessLogStructured format only. Use the CaptureBodies route option to capture the bodies of
+// selected routes of a server.
+func WithAccessLogBodies(limit int) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.bodyLimit = limit
+	}
+}
+
+// AccessLogMiddleware logs the requests of the given route, e.g. Route.String(), with their client IP (see
+// ClientIP), user agent, sizes, protocol, TLS version, request id, trace id and authenticated principal. The sensitive
+// query params are redacted, see log.RedactURL. The requests answered with a status other than 2xx are
+// considered failed by the sampling.
+func AccessLogMiddleware(route string, opts ...AccessLogOption) Middleware {
+	o := accessLogOptions{format: AccessLogStructured, writer: os.Stdout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The combined log lines are written at once.
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			SetLogger(
+				r,
+				GetLogger(r).
+					With("method", r.Method).
+					With("url", requestURL(r)),
+			)
+
+			// The inner middlewares pass copies of the request, the principal is set in the state by
+			// AuthMiddleware.
+			state := &accessLogState{}
+			r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, state))
+
+			body := &accessLogBody{ReadCloser: r.Body, limit: o.bodyLimit}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+
+			var response *limitedBuffer
+			if o.bodyLimit > 0 {
+				response = &limitedBuffer{limit: o.bodyLimit}
+				w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+					Write: func(write httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+						return func(b []byte) (int, error) {
+							response.Write(b)
+							return write(b)
+						}
+					},
+				})
+			}
+
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			if !o.sampling.Sample(m.Code < 200 || m.Code >= 300, m.Duration) {
+				return
+			}
+
+			e := accessLogEntry{
+				start:        start,
+				route:        route,
+				remoteIP:     ClientIP(r),
+				requestSize:  r.ContentLength,
+				responseSize: m.Written,
+				status:       m.Code,
+				latency:      m.Duration,
+				traceID:      w.Header().Get("X-Trace-Id"),
+			}
+			if e.requestSize < 0 {
+				e.requestSize = body.n
+			}
+			if state.principal != nil {
+				e.principal = state.principal.String()
+			}
+
+			if o.format == AccessLogCombined {
+				line := e.combined(r)
+				mu.Lock()
+				io.WriteString(o.writer, line)
+				mu.Unlock()
+				return
+			}
+
+			logger := e.structured(r)
+			if o.bodyLimit > 0 {
+				logger = logger.
+					With("request_body", body.captured.String()).
+					With("response_body", response.String())
+			}
+			logger.Info("request")
+		})
+	}
+}
+
+type accessLogKey struct{}
+
+// accessLogState is the state of a request updated by the inner middlewares.
+type accessLogState struct {
+	principal *auth.Principal
+}
+
+// setAccessLogPrincipal sets the principal of the request logged by AccessLogMiddleware, if any.
+func setAccessLogPrincipal(ctx context.Context, p *auth.Principal) {
+	if state, ok := ctx.Value(accessLogKey{}).(*accessLogState); ok {
+		state.principal = p
+	}
+}
+
+type accessLogEntry struct {
+	start        time.Time
+	route        string
+	remoteIP     string
+	principal    string
+	traceID      string
+	requestSize  int64
+	responseSize int64
+	status       int
+	latency      time.Duration
+}
+
+func (e *accessLogEntry) structured(r *http.Request) log.Interface {
+	// The method and the URL are already fields of the logger.
+	logger := GetLogger(r).
+		With("route", e.route).
+		With("remote_ip", e.remoteIP).
+		With("user_agent", r.UserAgent()).
+		With("proto", r.Proto).
+		With("request_size", e.requestSize).
+		With("response_size", e.responseSize).
+		With("latency", e.latency.String()).
+		With("status", e.status)
+
+	if r.TLS != nil {
+		logger = logger.With("tls_version", tls.VersionName(r.TLS.Version))
+	}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if e.traceID != "" {
+		logger = logger.With("trace_id", e.traceID)
+	}
+	if e.principal != "" {
+		logger = logger.With("principal", e.principal)
+	}
+
+	return logger
+}
+
+// combined returns the log line of the request in the combined log format:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+func (e *accessLogEntry) combined(r *http.Request) string {
+	size := "-"
+	if e.responseSize > 0 {
+		size = strconv.FormatInt(e.responseSize, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %s %s\n",
+		e.remoteIP,
+		strings.ReplaceAll(orDash(e.principal), " ", "%20"),
+		e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		log.RedactURL(r.URL),
+		r.Proto,
+		e.status,
+		size,
+		strconv.Quote(orDash(log.RedactString(r.Referer()))),
+		strconv.Quote(orDash(r.UserAgent())),
+	)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// accessLogBody counts the bytes of the request body read by the handler and captures the first ones.
+type accessLogBody struct {
+	io.ReadCloser
+	limit    int
+	n        int64
+	captured limitedBuffer
+}
+
+func (b *accessLogBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if b.limit > 0 {
+		b.captured.limit = b.limit
+		b.captured.Write(p[:n])
+	}
+	return n, err
+}
+
+// limitedBuffer keeps the first limit bytes written.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		p, b.truncated = p[:room], true
+	}
+	b.buf.Write(p)
+}
+
+// String returns the bytes kept, redacted, followed by "..." if truncated. The last value of a truncated
+// body is dropped, as it couldn't be redacted once cut.
+func (b *limitedBuffer) String() string {
+	s := b.buf.String()
+	if b.truncated {
+		s = s[:strings.LastIndexAny(s, " \t\n\"',;&=:")+1]
+	}
+
+	s = log.RedactString(s)
+	if b.truncated {
+		s += "..."
+	}
+	return s
+}
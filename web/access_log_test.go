@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+func TestAccessLogCombined(t *testing.T) {
+	var buf bytes.Buffer
+	authenticate := AuthMiddleware(auth.NewAPIKeyAuthenticator("", auth.StaticAPIKeys(map[string]*auth.Principal{
+		"key": {Subject: "john doe"},
+	})))
+
+	handler := MiddlewareChain(
+		xRequestID,
+		AccessLogMiddleware("GET /files", WithAccessLogFormat(AccessLogCombined), WithAccessLogWriter(&buf)),
+		authenticate,
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest("GET", "/files?id=1&s=f00", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Api-Key", "key")
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "curl/8.0")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "10.0.0.1 - john%20doe ["), line)
+	assert.True(t, strings.HasSuffix(line,
+		`] "GET /files?id=1&s=[REDACTED] HTTP/1.1" 200 5 "https://example.com/" "curl/8.0"`+"\n"), line)
+}
+
+func TestAccessLogStructured(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, log.Configure(log.WithFormat(log.FormatJSON), log.WithWriter(&buf)))
+	defer func() {
+		require.NoError(t, log.Configure(log.WithOutput("stderr")))
+	}()
+
+	handler := MiddlewareChain(
+		ProxyHeadersMiddleware(TrustProxies(netip.MustParsePrefix("10.0.0.0/8"))),
+		xRequestID,
+		AccessLogMiddleware("POST /users", WithAccessLogBodies(32)),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1","email":"` + body["email"] + `","name":"a very long name to be truncated"}`))
+	}))
+
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"email":"john@example.com"}`))
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.1.1.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Request-Id", "req-1")
+	r.TLS = &tls.ConnectionState{Version: tls.VersionTLS13}
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	data := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+	assert.Equal(t, "request", data["msg"])
+	assert.Equal(t, "POST", data["method"])
+	assert.Equal(t, "https://example.com/users", data["url"])
+	assert.Equal(t, "POST /users", data["route"])
+	assert.Equal(t, "203.0.113.7", data["remote_ip"])
+	assert.Equal(t, "HTTP/1.1", data["proto"])
+	assert.Equal(t, "TLS 1.3", data["tls_version"])
+	assert.Equal(t, "req-1", data["request_id"])
+	assert.Equal(t, float64(28), data["request_size"])
+	assert.Equal(t, float64(201), data["status"])
+	assert.Equal(t, `{"email":"[REDACTED]"}`, data["request_body"])
+	assert.Equal(t, `{"id":"1","email":"...`, data["response_body"])
+}
+
+func TestAccessLogJSONBodies(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, log.Configure(log.WithFormat(log.FormatJSON), log.WithWriter(&buf)))
+	defer func() {
+		require.NoError(t, log.Configure(log.WithOutput("stderr")))
+	}()
+
+	handler := AccessLogMiddleware("POST /login", WithAccessLogBodies(128))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"access_token":"abc123secret","expires_in":3600}`))
+	}))
+
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(`{"user":"bob","password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "abc123secret")
+
+	data := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+	assert.Equal(t, `{"user":"bob","password":"[REDACTED]"}`, data["request_body"])
+	assert.Equal(t, `{"access_token":"[REDACTED]","expires_in":3600}`, data["response_body"])
+}
+
+func TestAccessLogServer(t *testing.T) {
+	var buf bytes.Buffer
+	routes := Routes{NewRoute("GET", "/files/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))}
+
+	handler := New(routes,
+		WithPing(false),
+		WithHealth(false),
+		WithTracer(mocktracer.New()),
+		WithAuth(auth.NewAPIKeyAuthenticator("", auth.StaticAPIKeys(map[string]*auth.Principal{"key": {Subject: "john"}}))),
+		WithAccessLog(WithAccessLogFormat(AccessLogCombined), WithAccessLogWriter(&buf)),
+	).(*Web).server.Handler
+
+	r := httptest.NewRequest("GET", "/files/1", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("X-Api-Key", "key")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.True(t, strings.HasPrefix(buf.String(), "203.0.113.7 - john ["), buf.String())
+	assert.Contains(t, buf.String(), `"GET /files/1 HTTP/1.1" 200 5`)
+}
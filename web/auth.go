@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/auth"
+)
+
+// AuthMiddleware authenticates the requests using the given authenticator. The principal is stored in
+// the request context under SessionContextKey, and can be retrieved with GetPrincipal or auth.FromContext.
+// Authentication failures are answered with ErrMissingAuthorizationHeader, ErrInvalidAuthorizationHeader,
+// ErrInvalidAccessToken or ErrInvalidCredentials.
+func AuthMiddleware(authenticator auth.Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticator.Authenticate(r.Context(), r.Header)
+			if err != nil {
+				handleAuthError(w, r, err)
+				return
+			}
+
+			ctx := context.WithValue(auth.NewContext(r.Context(), p), SessionContextKey, p)
+			setAccessLogPrincipal(ctx, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequirePrincipal checks that the request was authenticated by AuthMiddleware and that the principal has
+// the given scopes and roles. Unauthenticated requests get ErrUnauthorized and requests of principals
+// missing scopes or roles get a 403.
+func RequirePrincipal(scopes, roles []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := GetPrincipal(r)
+			if !ok {
+				HandleErrorResponse(w, r, ErrUnauthorized)
+				return
+			}
+
+			if !p.HasScopes(scopes...) {
+				HandleErrorResponse(w, r, NewForbidden("Insufficient scope"))
+				return
+			}
+
+			if !p.HasRoles(roles...) {
+				HandleErrorResponse(w, r, NewForbidden("Insufficient role"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetPrincipal returns the principal of a request authenticated by AuthMiddleware.
+func GetPrincipal(r *http.Request) (*auth.Principal, bool) {
+	p, ok := r.Context().Value(SessionContextKey).(*auth.Principal)
+	return p, ok
+}
+
+func handleAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrMissingCredentials):
+		HandleErrorResponse(w, r, ErrMissingAuthorizationHeader)
+	case errors.Is(err, auth.ErrMalformedCredentials):
+		HandleErrorResponse(w, r, ErrInvalidAuthorizationHeader)
+	case errors.Is(err, auth.ErrInvalidToken):
+		HandleErrorResponse(w, r, ErrInvalidAccessToken)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		HandleErrorResponse(w, r, ErrInvalidCredentials)
+	default:
+		HandleError(w, r, errors.Wrap(err, "failed to authenticate request"))
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/auth"
+)
+
+func newAuthTestServer(authenticator auth.Authenticator) http.Handler {
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := GetPrincipal(r)
+		if !ok {
+			WriteJSON(w, http.StatusOK, "anonymous")
+			return
+		}
+
+		fromCtx, _ := auth.FromContext(r.Context())
+		WriteJSON(w, http.StatusOK, fromCtx.Subject+"/"+p.Method)
+	})
+
+	routes := Routes{
+		NewRoute("GET", "/whoami", whoami),
+		NewRoute("GET", "/public", whoami, Public()),
+		NewRoute("GET", "/admin", whoami, RequireRoles("admin")),
+		NewRoute("GET", "/projects", whoami, RequireScopes("projects:read")),
+	}
+
+	return New(routes, WithAuth(authenticator), WithPing(false)).(*Web).server.Handler
+}
+
+func TestWithAuth(t *testing.T) {
+	handler := newAuthTestServer(auth.NewAPIKeyAuthenticator("", auth.StaticAPIKeys(map[string]*auth.Principal{
+		"admin-key": {Subject: "admin", Roles: []string{"admin"}},
+		"user-key":  {Subject: "user", Scopes: []string{"projects:read"}},
+	})))
+
+	tests := []struct {
+		path   string
+		key    string
+		status int
+		body   string
+	}{
+		{"/whoami", "user-key", http.StatusOK, `"user/api_key"`},
+		{"/whoami", "", http.StatusUnauthorized, `Missing authorization header`},
+		{"/whoami", "wrong", http.StatusUnauthorized, `Invalid credentials`},
+		{"/public", "", http.StatusOK, `"anonymous"`},
+		{"/admin", "admin-key", http.StatusOK, `"admin/api_key"`},
+		{"/admin", "user-key", http.StatusForbidden, `Insufficient role`},
+		{"/projects", "user-key", http.StatusOK, `"user/api_key"`},
+		{"/projects", "admin-key", http.StatusForbidden, `Insufficient scope`},
+		{"/readyz", "", http.StatusOK, `"status"`},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.status, w.Code, tt.path+" "+tt.key)
+		assert.Contains(t, w.Body.String(), tt.body, tt.path+" "+tt.key)
+	}
+}
+
+func TestAuthMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected *ErrorResponse
+	}{
+		{auth.ErrMissingCredentials, ErrMissingAuthorizationHeader},
+		{errors.Wrap(auth.ErrMalformedCredentials, "unknown scheme"), ErrInvalidAuthorizationHeader},
+		{errors.Wrap(auth.ErrInvalidToken, "expired"), ErrInvalidAccessToken},
+		{auth.ErrInvalidCredentials, ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		handler := AuthMiddleware(auth.AuthenticatorFunc(func(context.Context, auth.Credentials) (*auth.Principal, error) {
+			return nil, tt.err
+		}))(http.NotFoundHandler())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, tt.expected.Code, resp.Code)
+	}
+
+	handler := AuthMiddleware(auth.AuthenticatorFunc(func(context.Context, auth.Credentials) (*auth.Principal, error) {
+		return nil, errors.New("database is down")
+	}))(http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestRequirePrincipalUnauthenticated(t *testing.T) {
+	handler := RequirePrincipal(nil, []string{"admin"})(http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
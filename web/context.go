@@ -12,22 +12,31 @@ type key int
 // Context keys
 const (
 	_ key = iota
+	// Deprecated: the logger is stored with log.WithContext.
 	LOGGERKEY
 )
 
-// SetLogger sets logger to request context.
+// SetLogger sets logger to request context, see log.WithContext.
 func SetLogger(r *http.Request, l log.Interface) *http.Request {
-	ctx := context.WithValue(r.Context(), LOGGERKEY, l)
-	*r = *r.WithContext(ctx)
+	*r = *r.WithContext(log.WithContext(r.Context(), l))
 	return r
 }
 
-// GetLogger retrieves logger from request context.
+// GetLogger retrieves logger from request context, see log.FromContext.
 func GetLogger(r *http.Request) log.Interface {
-	val, ok := r.Context().Value(LOGGERKEY).(log.Interface)
-	if !ok {
-		return log.Logger()
-	}
+	return log.FromContext(r.Context())
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a new context carrying the request id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
 
-	return val
+// RequestIDFromContext returns the id of the request being served, set by the server from the
+// X-Request-Id header, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
 }
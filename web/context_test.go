@@ -1,6 +1,7 @@
 package web_test
 
 import (
+	"context"
 	"net/http"
 
 	. "github.com/onsi/ginkgo"
@@ -23,4 +24,12 @@ var _ = Describe("Context", func() {
 			Expect(l).To(Equal(logger))
 		})
 	})
+
+	Describe("ContextWithRequestID and RequestIDFromContext", func() {
+		It("should set and get the request id", func() {
+			ctx := web.ContextWithRequestID(context.Background(), "request-1")
+			Expect(web.RequestIDFromContext(ctx)).To(Equal("request-1"))
+			Expect(web.RequestIDFromContext(context.Background())).To(BeEmpty())
+		})
+	})
 })
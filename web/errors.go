@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/starclusterteam/go-starbox/errors"
 )
@@ -122,6 +124,15 @@ func (e *ErrorResponse) Write(w http.ResponseWriter) {
 	WriteJSON(w, e.HTTPStatus, e)
 }
 
+// Error returns the messages of the error response, so it can be returned as an error by typed handlers.
+func (e *ErrorResponse) Error() string {
+	if e.isInternalError && e.internalError != nil {
+		return e.internalError.Error()
+	}
+
+	return strings.Join(e.Messages, ", ")
+}
+
 func (e *ErrorResponse) IsInternalError() bool {
 	return e.isInternalError
 }
@@ -289,3 +300,92 @@ func ParseErrorResponse(body []byte) (*ErrorResponse, error) {
 
 	return e, nil
 }
+
+// NewErrorResponseFromError renders an error into the ErrorResponse format. Errors created with
+// errors.E and its helpers keep their status, code, public message and field errors; internal and
+// untyped errors result in an internal error response that doesn't expose the error.
+// An *ErrorResponse in the error chain is returned as is.
+func NewErrorResponseFromError(err error) *ErrorResponse {
+	var errResp *ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp
+	}
+
+	e, ok := errors.AsError(err)
+	if !ok || e.Kind == errors.KindInternal || e.Kind == errors.KindUnknown {
+		return NewInternalError(err)
+	}
+
+	status := e.Kind.HTTPStatus()
+
+	code := e.Code
+	if code == 0 {
+		code = status
+	}
+
+	resp := &ErrorResponse{
+		Messages:   []string{},
+		Code:       code,
+		HTTPStatus: status,
+		Errors:     make(map[string][]string),
+	}
+
+	if e.Message != "" {
+		resp.Messages = append(resp.Messages, e.Message)
+	}
+
+	for _, f := range e.Fields {
+		if e.Message == "" {
+			resp.Messages = append(resp.Messages, f.Message)
+		}
+		resp.Errors[f.Field] = append(resp.Errors[f.Field], f.Message)
+	}
+
+	if len(e.Fields) == 0 {
+		resp.Errors[e.Kind.String()] = []string{e.Message}
+	}
+
+	return resp
+}
+
+// ParseError parses an error response body received with the given HTTP status into an *errors.Error,
+// the inverse of NewErrorResponseFromError. A code equal to the HTTP status is treated as unset.
+func ParseError(statusCode int, body []byte) error {
+	resp, err := ParseErrorResponse(body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse error response with status %d", statusCode)
+	}
+
+	return resp.toError(statusCode)
+}
+
+func (e *ErrorResponse) toError(statusCode int) *errors.Error {
+	kind := errors.KindFromHTTPStatus(statusCode)
+
+	var message string
+	if len(e.Messages) > 0 {
+		message = e.Messages[0]
+	}
+
+	typed := errors.E(kind, message)
+	if e.Code != statusCode {
+		typed.Code = e.Code
+	}
+
+	fields := make([]string, 0, len(e.Errors))
+	for f := range e.Errors {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		for _, m := range e.Errors[f] {
+			if f == kind.String() && m == message {
+				continue
+			}
+			typed.Fields = append(typed.Fields, errors.FieldError{Field: f, Message: m})
+		}
+	}
+
+	return typed
+}
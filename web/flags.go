@@ -0,0 +1,28 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/starclusterteam/go-starbox/flags"
+)
+
+// FlagsContextFunc completes the feature flags evaluation context of a request, e.g. with attributes
+// read from the request.
+type FlagsContextFunc func(r *http.Request, ec *flags.EvalContext)
+
+// FeatureFlagsMiddleware stores the feature flags evaluation context of the requests in their context,
+// so the flags evaluated by the handlers, e.g. with flags.Bool(r.Context(), ...), depend on the request.
+// The context is built by flags.RequestContext, from the authenticated principal and the headers, then
+// completed by the given functions.
+func FeatureFlagsMiddleware(fns ...FlagsContextFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ec := flags.RequestContext(r.Context(), r.Header)
+			for _, fn := range fns {
+				fn(r, ec)
+			}
+
+			next.ServeHTTP(w, r.WithContext(flags.NewContext(r.Context(), ec)))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starclusterteam/go-starbox/auth"
+	"github.com/starclusterteam/go-starbox/flags"
+)
+
+func TestFeatureFlagsMiddleware(t *testing.T) {
+	client := flags.NewStatic(map[string]*flags.Flag{
+		"new-checkout": {Rules: []flags.Rule{{Match: map[string][]string{flags.AttributeTenant: {"acme"}}, Variant: "true"}}},
+		"beta":         {Rules: []flags.Rule{{Match: map[string][]string{"country": {"ro"}}, Variant: "true"}}},
+	})
+
+	var ec *flags.EvalContext
+	handler := FeatureFlagsMiddleware(func(r *http.Request, ec *flags.EvalContext) {
+		ec.Attributes = map[string]string{"country": r.URL.Query().Get("country")}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ec, _ = flags.FromContext(r.Context())
+		if client.Bool(r.Context(), "new-checkout", false) {
+			w.Write([]byte("new-checkout "))
+		}
+		if client.Bool(r.Context(), "beta", false) {
+			w.Write([]byte("beta"))
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/?country=ro", nil)
+	r.Header.Set(flags.TenantHeader, "acme")
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Subject: "john"}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "new-checkout beta", w.Body.String())
+	assert.Equal(t, "john", ec.UserID)
+	assert.Equal(t, "acme", ec.Tenant)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Empty(t, w.Body.String())
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// StatusCoder can be implemented by responses of typed handlers to override the default 200 status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// TypedHandlerFunc is a handler working with decoded requests and responses.
+type TypedHandlerFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+type typedHandler[Req, Resp any] struct {
+	fn     TypedHandlerFunc[Req, Resp]
+	params []paramField
+}
+
+// TypedHandler returns an http.Handler that:
+//   - decodes the JSON body, the path variables and the query parameters into a Req (see ReadParams
+//     for the struct tags used for path variables and query parameters);
+//   - validates the request using its `validate` struct tags (see ValidateStruct) and, if it implements
+//     Validatable, its Validate method, responding with a 400 on validation errors;
+//   - calls fn and writes the returned response as JSON, with the status code given by StatusCoder or 200.
+//
+// Errors returned by fn are written with HandleError, so both *ErrorResponse and errors created with
+// errors.E keep their status code.
+func TypedHandler[Req, Resp any](fn TypedHandlerFunc[Req, Resp]) http.Handler {
+	h := &typedHandler[Req, Resp]{fn: fn}
+
+	if t := reflect.TypeOf((*Req)(nil)).Elem(); t.Kind() == reflect.Struct {
+		h.params = paramFieldsOf(t)
+	}
+
+	return h
+}
+
+// Handle returns a new route for the given method and path, handled by a TypedHandler.
+func Handle[Req, Resp any](method, path string, fn TypedHandlerFunc[Req, Resp], opts ...RouteOption) Route {
+	return NewRoute(method, path, TypedHandler(fn), opts...)
+}
+
+// typedRouteHandler is implemented by handlers that know their request and response types.
+type typedRouteHandler interface {
+	types() (request, response reflect.Type)
+}
+
+func (h *typedHandler[Req, Resp]) types() (reflect.Type, reflect.Type) {
+	return reflect.TypeOf((*Req)(nil)).Elem(), reflect.TypeOf((*Resp)(nil)).Elem()
+}
+
+func (h *typedHandler[Req, Resp]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Req
+
+	if errResp := h.decode(r, &req); errResp != nil {
+		HandleErrorResponse(w, r, errResp)
+		return
+	}
+
+	if errs := ValidateStruct(&req); len(errs) > 0 {
+		HandleErrorResponse(w, r, NewValidationErrorsResponse(errs))
+		return
+	}
+
+	if v, ok := any(&req).(Validatable); ok {
+		if errs := v.Validate(); len(errs) > 0 {
+			HandleErrorResponse(w, r, NewValidationErrorsResponse(errs))
+			return
+		}
+	}
+
+	resp, err := h.fn(r.Context(), req)
+	if err != nil {
+		HandleError(w, r, err)
+		return
+	}
+
+	code := http.StatusOK
+	if sc, ok := any(resp).(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+
+	if code == http.StatusNoContent {
+		w.WriteHeader(code)
+		return
+	}
+
+	WriteJSON(w, code, resp)
+}
+
+func (h *typedHandler[Req, Resp]) decode(r *http.Request, req *Req) *ErrorResponse {
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+			return ErrInvalidRequestFormat
+		}
+	}
+
+	if len(h.params) == 0 {
+		return nil
+	}
+
+	if errs := bindParams(r, reflect.ValueOf(req).Elem(), h.params); len(errs) > 0 {
+		return NewValidationErrorsResponse(errs)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+type updateProjectRequest struct {
+	ID      int      `path:"id" json:"-"`
+	DryRun  bool     `query:"dry_run" json:"-"`
+	Tags    []string `query:"tag" json:"-"`
+	Name    string   `json:"name"`
+	Private *bool    `json:"private"`
+}
+
+func (r *updateProjectRequest) Validate() []web.ValidationError {
+	if r.Name == "" {
+		return []web.ValidationError{web.NewValidationError("name", "name is required")}
+	}
+	return nil
+}
+
+type project struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	DryRun bool     `json:"dry_run"`
+	Tags   []string `json:"tags"`
+}
+
+type createdProject struct {
+	project
+}
+
+func (createdProject) StatusCode() int { return http.StatusCreated }
+
+func serveTyped(t *testing.T, route web.Route, method, url, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	web.NewRouter(web.Routes{route}).ServeHTTP(w, r)
+	return w
+}
+
+func TestTypedHandler(t *testing.T) {
+	route := web.Handle("PUT", "/projects/{id}", func(ctx context.Context, req updateProjectRequest) (project, error) {
+		if req.ID == 404 {
+			return project{}, errors.NotFound("project not found")
+		}
+		if req.ID == 403 {
+			return project{}, web.NewForbidden("not allowed")
+		}
+		return project{ID: req.ID, Name: req.Name, DryRun: req.DryRun, Tags: req.Tags}, nil
+	})
+
+	t.Run("decodes body, path and query", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/12?dry_run=true&tag=a&tag=b", `{"name":"starbox"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, project{ID: 12, Name: "starbox", DryRun: true, Tags: []string{"a", "b"}}, resp)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/12", `{"name":`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid request format")
+	})
+
+	t.Run("invalid path variable", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/abc", `{"name":"starbox"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":["must be an integer"]`)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/12", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "name is required")
+	})
+
+	t.Run("typed error", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/404", `{"name":"starbox"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		w := serveTyped(t, route, "PUT", "/projects/403", `{"name":"starbox"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestTypedHandlerStatusCode(t *testing.T) {
+	route := web.Post("/projects", web.TypedHandler(func(ctx context.Context, req struct{}) (createdProject, error) {
+		return createdProject{project{ID: 1}}, nil
+	}))
+
+	w := serveTyped(t, route, "POST", "/projects", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1`)
+}
+
+func TestReadParams(t *testing.T) {
+	var params struct {
+		Page  int     `query:"page"`
+		Limit *uint   `query:"limit"`
+		Ratio float64 `query:"ratio"`
+	}
+
+	r := httptest.NewRequest("GET", "/?page=2&limit=10&ratio=0.5", nil)
+	require.Nil(t, web.ReadParams(r, &params))
+	assert.Equal(t, 2, params.Page)
+	assert.Equal(t, uint(10), *params.Limit)
+	assert.Equal(t, 0.5, params.Ratio)
+
+	r = httptest.NewRequest("GET", "/?limit=-1", nil)
+	errResp := web.ReadParams(r, &params)
+	require.NotNil(t, errResp)
+	assert.Equal(t, []string{"must be a positive integer"}, errResp.Errors["limit"])
+}
+
+type listMeta struct {
+	ID int `query:"id"`
+}
+
+func TestReadParamsEmbeddedPointer(t *testing.T) {
+	var params struct {
+		*listMeta
+		Name string `query:"name"`
+	}
+
+	// The fields of an embedded pointer to an unexported struct cannot be set and are ignored.
+	r := httptest.NewRequest("GET", "/?id=3&name=x", nil)
+	require.Nil(t, web.ReadParams(r, &params))
+	assert.Nil(t, params.listMeta)
+	assert.Equal(t, "x", params.Name)
+}
+
+type ListMeta struct {
+	ID int `query:"id"`
+}
+
+func TestTypedHandlerEmbeddedPointer(t *testing.T) {
+	type listRequest struct {
+		*ListMeta
+		Name string `query:"name"`
+	}
+
+	route := web.Get("/projects", web.TypedHandler(func(ctx context.Context, req listRequest) (project, error) {
+		if req.ListMeta == nil {
+			return project{Name: req.Name}, nil
+		}
+		return project{ID: req.ID, Name: req.Name}, nil
+	}))
+
+	w := serveTyped(t, route, "GET", "/projects?id=3&name=starbox", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":3`)
+
+	// The embedded struct is not allocated if none of its fields is given.
+	w = serveTyped(t, route, "GET", "/projects?name=starbox", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":0`)
+}
@@ -0,0 +1,33 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/starclusterteam/go-starbox/health"
+)
+
+// LivenessHandler returns a handler that runs the liveness checks of the given registry.
+// It responds with 200 if all critical checks pass and 503 otherwise.
+func LivenessHandler(registry *health.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, registry.Liveness(r.Context()))
+	})
+}
+
+// ReadinessHandler returns a handler that runs all the checks of the given registry.
+// It responds with 200 if all critical checks pass and 503 otherwise, including while the
+// registry is shutting down.
+func ReadinessHandler(registry *health.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, registry.Readiness(r.Context()))
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report health.Report) {
+	code := http.StatusOK
+	if !report.Healthy() {
+		code = http.StatusServiceUnavailable
+	}
+
+	WriteJSON(w, code, report)
+}
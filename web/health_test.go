@@ -0,0 +1,54 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/health"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Register("db", health.CheckerFunc(func(context.Context) error { return nil }))
+
+	w := httptest.NewRecorder()
+	web.ReadinessHandler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	registry.Register("db", health.CheckerFunc(func(context.Context) error { return errors.New("down") }))
+
+	w = httptest.NewRecorder()
+	web.ReadinessHandler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, "down", report.Checks["db"].Error)
+}
+
+func TestLivenessHandler(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Shutdown()
+
+	w := httptest.NewRecorder()
+	web.LivenessHandler(registry).ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNilHealthRegistry(t *testing.T) {
+	// The previously configured registry is kept. A new registry is used so that Stop does not mark
+	// the default registry as shutting down for the other tests.
+	registry := health.NewRegistry()
+	s := web.New(nil, web.WithHealthRegistry(registry), web.WithHealthRegistry(nil))
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.True(t, registry.ShuttingDown())
+}
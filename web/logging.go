@@ -4,22 +4,43 @@ import (
 	"net/http"
 
 	"github.com/felixge/httpsnoop"
+
+	"github.com/starclusterteam/go-starbox/log"
 )
 
+// requestURL returns the URL of a request as sent by the client, redacted, see log.RedactURL.
+func requestURL(r *http.Request) string {
+	info := GetClientInfo(r)
+	return info.Scheme + "://" + info.Host + log.RedactURL(r.URL)
+}
+
 func logger(inner http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		SetLogger(
-			r,
-			GetLogger(r).
-				With("method", r.Method).
-				With("url", "http://"+r.Host+r.URL.String()),
-		)
+	return requestLogger(nil)(inner)
+}
+
+// requestLogger logs the requests kept by the sampling, see log.RequestSampling. The requests answered
+// with a status other than 2xx are considered failed. The sensitive query params of the URL, e.g. the
+// signature, are redacted, see log.RedactURL.
+func requestLogger(sampling *log.RequestSampling) Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetLogger(
+				r,
+				GetLogger(r).
+					With("method", r.Method).
+					With("url", requestURL(r)),
+			)
 
-		m := httpsnoop.CaptureMetrics(inner, w, r)
+			m := httpsnoop.CaptureMetrics(inner, w, r)
 
-		GetLogger(r).
-			With("latency", m.Duration.String()).
-			With("status", m.Code).
-			Info("request")
-	})
+			if !sampling.Sample(m.Code < 200 || m.Code >= 300, m.Duration) {
+				return
+			}
+
+			GetLogger(r).
+				With("latency", m.Duration.String()).
+				With("status", m.Code).
+				Info("request")
+		})
+	}
 }
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 
 	"github.com/golang/mock/gomock"
+	"github.com/starclusterteam/go-starbox/log"
 	"github.com/starclusterteam/go-starbox/mock"
 )
 
@@ -50,5 +51,45 @@ var _ = Describe("Logging", func() {
 
 			By("logging context data")
 		})
+
+		It("samples successful requests", func() {
+			status := http.StatusOK
+			fakeHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			})
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				SetLogger(r, mockLogger)
+				requestLogger(&log.RequestSampling{Rate: 0})(fakeHandler).ServeHTTP(w, r)
+			})
+
+			mockLogger.EXPECT().With("method", "GET").Return(mockLogger).Times(2)
+			mockLogger.EXPECT().With("url", gomock.Any()).Return(mockLogger).Times(2)
+			mockLogger.EXPECT().With("latency", gomock.Any()).Return(mockLogger)
+			mockLogger.EXPECT().With("status", http.StatusInternalServerError).Return(mockLogger)
+			mockLogger.EXPECT().Info("request")
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			status = http.StatusInternalServerError
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		})
+
+		It("redacts the sensitive query params", func() {
+			fakeHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				SetLogger(r, mockLogger)
+				logger(fakeHandler).ServeHTTP(w, r)
+			})
+
+			mockLogger.EXPECT().With("method", "GET").Return(mockLogger)
+			mockLogger.EXPECT().With("url", "http://example.com/files?id=1&s=[REDACTED]&token=[REDACTED]").Return(mockLogger)
+			mockLogger.EXPECT().With("latency", gomock.Any()).Return(mockLogger)
+			mockLogger.EXPECT().With("status", http.StatusOK).Return(mockLogger)
+			mockLogger.EXPECT().Info("request")
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/files?id=1&s=f00&token=abc", nil))
+		})
 	})
 })
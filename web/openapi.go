@@ -0,0 +1,398 @@
+package web
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const openAPIVersion = "3.1.0"
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIDocument is an OpenAPI 3.1 document generated from routes.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIComponents holds the reusable schemas of an OpenAPI document.
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+// OpenAPIOperation describes a single route.
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	PathPrefix  bool                        `json:"x-path-prefix,omitempty"`
+}
+
+// OpenAPIParameter describes a path or query parameter.
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a JSON request body.
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response.
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a request or response body.
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is a JSON schema.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// JSON returns the JSON encoding of the document.
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the YAML encoding of the document.
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode document")
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.Wrap(err, "failed to decode document")
+	}
+
+	return yaml.Marshal(v)
+}
+
+// NewOpenAPIDocument generates an OpenAPI document describing the given routes. Path variables are
+// read from the route patterns. Request and response schemas are reflected from the types of routes
+// created with Handle, TypedHandler or annotated with WithSchema, and all operations document the
+// ErrorResponse format for 4xx and 5xx responses.
+func NewOpenAPIDocument(routes Routes, info OpenAPIInfo) *OpenAPIDocument {
+	g := &schemaGenerator{
+		schemas: make(map[string]*OpenAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+
+	errorResponse := &OpenAPIResponse{
+		Description: "Error",
+		Content: map[string]OpenAPIMediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(ErrorResponse{}))},
+		},
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI:    openAPIVersion,
+		Info:       info,
+		Paths:      make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{Schemas: g.schemas},
+	}
+
+	for _, r := range routes {
+		path, params := openAPIPath(r.Pattern)
+
+		op := &OpenAPIOperation{
+			OperationID: operationID(r.Method, path),
+			Responses: map[string]*OpenAPIResponse{
+				"4XX": errorResponse,
+				"5XX": errorResponse,
+			},
+			PathPrefix: r.prefix,
+		}
+
+		for _, p := range params {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     p,
+				In:       "path",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			})
+		}
+
+		if r.requestType != nil {
+			g.addRequest(op, r.Method, r.requestType)
+		}
+
+		status, resp := "200", &OpenAPIResponse{Description: "OK"}
+		if r.responseType != nil {
+			if sc, ok := reflect.Zero(r.responseType).Interface().(StatusCoder); ok && r.responseType.Kind() != reflect.Ptr {
+				status = strconv.Itoa(sc.StatusCode())
+			}
+
+			if status != strconv.Itoa(http.StatusNoContent) {
+				resp.Content = map[string]OpenAPIMediaType{
+					"application/json": {Schema: g.schema(r.responseType)},
+				}
+			}
+		}
+		op.Responses[status] = resp
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	return doc
+}
+
+var pathVarRegexp = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIPath converts a mux pattern to an OpenAPI path, removing the variables regular expressions,
+// and returns the names of the variables.
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	path := pathVarRegexp.ReplaceAllStringFunc(pattern, func(v string) string {
+		name := pathVarRegexp.FindStringSubmatch(v)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+
+	return path, params
+}
+
+var nonAlphaNumRegexp = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func operationID(method, path string) string {
+	return strings.ToLower(method) + "_" + strings.Trim(nonAlphaNumRegexp.ReplaceAllString(path, "_"), "_")
+}
+
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGenerator) addRequest(op *OpenAPIOperation, method string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		op.RequestBody = &OpenAPIRequestBody{Content: map[string]OpenAPIMediaType{"application/json": {Schema: g.schema(t)}}}
+		return
+	}
+
+	for _, f := range paramFieldsOf(t) {
+		field := t.FieldByIndex(f.index)
+
+		if f.source == "path" {
+			for i, p := range op.Parameters {
+				if p.Name == f.name {
+					op.Parameters[i].Schema = g.schema(field.Type)
+				}
+			}
+			continue
+		}
+
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: f.name, In: "query", Schema: g.schema(field.Type)})
+	}
+
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+		return
+	}
+
+	if body := g.schema(t); len(g.properties(body)) > 0 {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{"application/json": {Schema: body}},
+		}
+	}
+}
+
+func (g *schemaGenerator) properties(s *OpenAPISchema) map[string]*OpenAPISchema {
+	if s.Ref != "" {
+		return g.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")].Properties
+	}
+
+	return s.Properties
+}
+
+var (
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &OpenAPISchema{}
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &OpenAPISchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	if t.Name() == "" {
+		return g.objectSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = nonAlphaNumRegexp.ReplaceAllString(t.Name(), "_")
+		if _, taken := g.schemas[name]; taken {
+			name = nonAlphaNumRegexp.ReplaceAllString(t.PkgPath()+"_"+t.Name(), "_")
+		}
+
+		g.names[t] = name
+		// Register the name before generating the properties so recursive types reference themselves.
+		g.schemas[name] = &OpenAPISchema{}
+		*g.schemas[name] = *g.objectSchema(t)
+	}
+
+	return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemaGenerator) objectSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		if _, ok := f.Tag.Lookup("json"); !ok && isParamField(f) {
+			continue
+		}
+
+		name, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+
+		s.Properties[name] = g.schema(f.Type)
+		if hasRule(f, "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+func isParamField(f reflect.StructField) bool {
+	_, path := f.Tag.Lookup("path")
+	_, query := f.Tag.Lookup("query")
+	return path || query
+}
+
+// jsonFieldName returns the JSON name of a struct field, following the encoding/json rules.
+func jsonFieldName(f reflect.StructField) (name string, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	name = strings.Split(tag, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+
+	return name, false
+}
+
+// WithOpenAPI serves the OpenAPI document generated from the server routes at the given path.
+// The document is encoded as YAML if the path ends with ".yaml" or ".yml" and as JSON otherwise.
+func WithOpenAPI(path string, info OpenAPIInfo) Option {
+	return func(o *serverOptions) {
+		o.openAPIPath = path
+		o.openAPIInfo = info
+	}
+}
+
+// OpenAPIHandler returns a handler that serves the OpenAPI document generated from the given routes.
+func OpenAPIHandler(routes Routes, info OpenAPIInfo, format string) http.Handler {
+	doc := NewOpenAPIDocument(routes, info)
+
+	var (
+		data        []byte
+		err         error
+		contentType string
+	)
+
+	if format == "yaml" {
+		data, err = doc.YAML()
+		contentType = "application/yaml"
+	} else {
+		data, err = doc.JSON()
+		contentType = "application/json"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			HandleError(w, r, errors.Wrap(err, "failed to encode OpenAPI document"))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+	})
+}
@@ -0,0 +1,97 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+type listProjectsRequest struct {
+	Page int `query:"page"`
+}
+
+type projectList struct {
+	Projects []project `json:"projects"`
+	Next     *string   `json:"next,omitempty"`
+}
+
+func openAPIRoutes() web.Routes {
+	return web.Routes{
+		web.Handle("GET", "/projects", func(ctx context.Context, req listProjectsRequest) (projectList, error) {
+			return projectList{}, nil
+		}),
+		web.Handle("PUT", "/projects/{id:[0-9]+}", func(ctx context.Context, req updateProjectRequest) (project, error) {
+			return project{}, nil
+		}),
+		web.NewRoute("POST", "/projects", http.NotFoundHandler(), web.WithSchema(updateProjectRequest{}, createdProject{})),
+		web.NewRoute("GET", "/static/", http.NotFoundHandler(), web.WithPrefix()),
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := web.NewOpenAPIDocument(openAPIRoutes(), web.OpenAPIInfo{Title: "projects", Version: "1.0.0"})
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	list := doc.Paths["/projects"]["get"]
+	require.NotNil(t, list)
+	assert.Equal(t, "get_projects", list.OperationID)
+	assert.Equal(t, []web.OpenAPIParameter{{Name: "page", In: "query", Schema: &web.OpenAPISchema{Type: "integer", Format: "int64"}}}, list.Parameters)
+	assert.Nil(t, list.RequestBody)
+	assert.Equal(t, "#/components/schemas/projectList", list.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/ErrorResponse", list.Responses["4XX"].Content["application/json"].Schema.Ref)
+
+	update := doc.Paths["/projects/{id}"]["put"]
+	require.NotNil(t, update)
+	assert.Equal(t, web.OpenAPIParameter{Name: "id", In: "path", Required: true, Schema: &web.OpenAPISchema{Type: "integer", Format: "int64"}}, update.Parameters[0])
+	require.NotNil(t, update.RequestBody)
+
+	body := doc.Components.Schemas["updateProjectRequest"]
+	require.NotNil(t, body)
+	assert.Contains(t, body.Properties, "name")
+	assert.Contains(t, body.Properties, "private")
+	assert.NotContains(t, body.Properties, "ID")
+
+	create := doc.Paths["/projects"]["post"]
+	require.NotNil(t, create)
+	assert.Contains(t, create.Responses, "201")
+
+	assert.True(t, doc.Paths["/static/"]["get"].PathPrefix)
+
+	errorSchema := doc.Components.Schemas["ErrorResponse"]
+	require.NotNil(t, errorSchema)
+	assert.Equal(t, &web.OpenAPISchema{Type: "object", AdditionalProperties: &web.OpenAPISchema{Type: "array", Items: &web.OpenAPISchema{Type: "string"}}}, errorSchema.Properties["errors"])
+	assert.NotContains(t, errorSchema.Properties, "HTTPStatus")
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	info := web.OpenAPIInfo{Title: "projects", Version: "1.0.0"}
+
+	w := httptest.NewRecorder()
+	web.OpenAPIHandler(openAPIRoutes(), info, "json").ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var fromJSON map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fromJSON))
+
+	w = httptest.NewRecorder()
+	web.OpenAPIHandler(openAPIRoutes(), info, "yaml").ServeHTTP(w, httptest.NewRequest("GET", "/openapi.yaml", nil))
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+
+	data, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	var fromYAML map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(data, &fromYAML))
+	assert.Equal(t, fromJSON["paths"].(map[string]interface{})["/projects"] != nil, fromYAML["paths"].(map[string]interface{})["/projects"] != nil)
+	assert.Equal(t, "3.1.0", fromYAML["openapi"])
+}
@@ -0,0 +1,211 @@
+package web
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// ReadParams decodes the path variables and query parameters of a request into the fields of the struct
+// pointed to by v. Fields are matched using the `path:"name"` and `query:"name"` struct tags. Supported
+// field types are strings, booleans, numbers, time.Duration, time.Time (RFC 3339), types implementing
+// encoding.TextUnmarshaler, and pointers and slices of these. Slices are filled from repeated query parameters.
+func ReadParams(r *http.Request, v interface{}) *ErrorResponse {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return NewInternalError(errors.Errorf("ReadParams expects a pointer to a struct, got %T", v))
+	}
+
+	errs := bindParams(r, rv.Elem(), paramFieldsOf(rv.Elem().Type()))
+	if len(errs) > 0 {
+		return NewValidationErrorsResponse(errs)
+	}
+
+	return nil
+}
+
+// paramField describes a struct field bound from a path variable or a query parameter.
+type paramField struct {
+	index  []int
+	source string // "path" or "query"
+	name   string
+}
+
+func paramFieldsOf(t reflect.Type) []paramField {
+	var fields []paramField
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || behindUnexportedPointer(t, f.Index) {
+			continue
+		}
+
+		for _, source := range []string{"path", "query"} {
+			if name, ok := f.Tag.Lookup(source); ok && name != "-" {
+				fields = append(fields, paramField{index: f.Index, source: source, name: name})
+			}
+		}
+	}
+
+	return fields
+}
+
+func bindParams(r *http.Request, v reflect.Value, fields []paramField) []ValidationError {
+	var (
+		errs  []ValidationError
+		vars  = mux.Vars(r)
+		query = r.URL.Query()
+	)
+
+	for _, f := range fields {
+		var values []string
+		switch f.source {
+		case "path":
+			if value, ok := vars[f.name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[f.name]
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(fieldByIndexAlloc(v, f.index), values); err != nil {
+			errs = append(errs, NewValidationError(f.name, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// fieldByIndexAlloc returns the field of v with the given index, allocating the nil embedded struct
+// pointers on the way to it.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// behindUnexportedPointer returns true if the field with the given index is promoted through an
+// embedded pointer to an unexported struct, which cannot be allocated.
+func behindUnexportedPointer(t reflect.Type, index []int) bool {
+	for _, x := range index[:len(index)-1] {
+		f := t.Field(x)
+		t = f.Type
+		if t.Kind() == reflect.Ptr {
+			if !f.IsExported() {
+				return true
+			}
+			t = t.Elem()
+		}
+	}
+
+	return false
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !field.Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return errors.New("is not valid")
+		}
+		return nil
+	}
+
+	switch field.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration")
+		}
+		field.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be an RFC 3339 timestamp")
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Type())
+	}
+
+	return nil
+}
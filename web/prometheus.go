@@ -23,6 +23,7 @@ type serverMetrics struct {
 	totalRequests         *prometheus.CounterVec
 	totalRequestsPerRoute *prometheus.CounterVec
 	requestLatency        *prometheus.HistogramVec
+	untrustedForwarded    prometheus.Counter
 }
 
 func NewServerMetrics() *serverMetrics {
@@ -52,11 +53,18 @@ func NewServerMetrics() *serverMetrics {
 		[]string{"method", "url"},
 	)
 
+	s.untrustedForwarded = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "incoming_http_untrusted_forwarded_requests_total",
+			Help: "The number of incoming HTTP requests with forwarding headers ignored as not sent by a trusted proxy.",
+		},
+	)
+
 	return &s
 }
 
 func (m *serverMetrics) mustRegister() {
-	prometheus.MustRegister(m.totalRequests, m.totalRequestsPerRoute, m.requestLatency)
+	prometheus.MustRegister(m.totalRequests, m.totalRequestsPerRoute, m.requestLatency, m.untrustedForwarded)
 }
 
 // Middleware reports request duration, method and status code to prometheus.
@@ -0,0 +1,316 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+// ClientInfo is the client of a request as seen by the first trusted proxy, or by the server if the
+// request doesn't come from a trusted proxy.
+type ClientInfo struct {
+	IP string
+	// Scheme is "http" or "https".
+	Scheme string
+	Host   string
+	// Proxied reports whether the request was forwarded by a trusted proxy.
+	Proxied bool
+}
+
+type clientInfoKey struct{}
+
+// ContextWithClientInfo returns a new context carrying the client info.
+func ContextWithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFromContext returns the client info of the request being served, resolved by
+// ProxyHeadersMiddleware.
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+// GetClientInfo returns the client info of a request resolved by ProxyHeadersMiddleware, or the one of
+// the connection.
+func GetClientInfo(r *http.Request) ClientInfo {
+	if info, ok := ClientInfoFromContext(r.Context()); ok {
+		return info
+	}
+
+	return connectionInfo(r)
+}
+
+// ClientIP returns the IP of the client of a request, see GetClientInfo.
+func ClientIP(r *http.Request) string {
+	return GetClientInfo(r).IP
+}
+
+type proxyOptions struct {
+	trustedProxies  []netip.Prefix
+	strippedHeaders []string
+}
+
+// ProxyOption is a functional option for ProxyHeadersMiddleware and WithProxyHeaders.
+type ProxyOption func(*proxyOptions)
+
+// TrustProxies trusts the forwarding headers set by the proxies of the given networks, e.g. the load
+// balancers.
+func TrustProxies(proxies ...netip.Prefix) ProxyOption {
+	return func(o *proxyOptions) {
+		o.trustedProxies = append(o.trustedProxies, proxies...)
+	}
+}
+
+// defaultStrippedHeaders are the prefixes of the headers stripped by default, the internal headers added
+// by the Vulcand proxy.
+var defaultStrippedHeaders = []string{"X-Vulcand"}
+
+// StripHeaders removes the headers whose name starts with one of the given prefixes, case insensitively,
+// from the requests before they are handled, e.g. the internal headers added by a proxy. The prefixes
+// replace the default ones, "X-Vulcand", and StripHeaders() strips no header.
+func StripHeaders(prefixes ...string) ProxyOption {
+	return func(o *proxyOptions) {
+		o.strippedHeaders = make([]string, 0, len(prefixes))
+		for _, p := range prefixes {
+			o.strippedHeaders = append(o.strippedHeaders, http.CanonicalHeaderKey(p))
+		}
+	}
+}
+
+// ParseTrustedProxies parses a comma separated list of networks in CIDR notation or of IP addresses.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(v); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid trusted proxy %q", v)
+		}
+		proxies = append(proxies, p.Masked())
+	}
+
+	return proxies, nil
+}
+
+// trustedProxiesFromEnv returns the trusted proxies set by the WEB_TRUSTED_PROXIES environment variable.
+func trustedProxiesFromEnv() []netip.Prefix {
+	proxies, err := ParseTrustedProxies(config.String(envvar.WebTrustedProxies, ""))
+	if err != nil {
+		log.Fatalf("invalid %s: %v", envvar.WebTrustedProxies, err)
+	}
+
+	return proxies
+}
+
+// ProxyHeadersMiddleware resolves the client of the requests, retrieved with GetClientInfo and ClientIP.
+// For the requests of trusted proxies, the client IP, scheme and host are taken from the RFC 7239
+// Forwarded header, or else from the X-Forwarded-For (or X-Real-IP), X-Forwarded-Proto and
+// X-Forwarded-Host headers. The addresses of the chain of proxies are read from the last one, up to the
+// first address which isn't trusted. The forwarding headers of the other requests are ignored and counted
+// by the incoming_http_untrusted_forwarded_requests_total Prometheus counter. The "X-Vulcand" headers are
+// stripped unless configured otherwise with StripHeaders.
+func ProxyHeadersMiddleware(opts ...ProxyOption) Middleware {
+	o := proxyOptions{strippedHeaders: defaultStrippedHeaders}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name := range r.Header {
+				if o.stripped(name) {
+					r.Header.Del(name)
+				}
+			}
+
+			info := connectionInfo(r)
+			if o.trusted(info.IP) {
+				info = o.resolve(r, info)
+			} else if hasForwardingHeaders(r.Header) {
+				defaultServerMetrics.untrustedForwarded.Inc()
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClientInfo(r.Context(), info)))
+		})
+	}
+}
+
+func (o *proxyOptions) stripped(name string) bool {
+	for _, prefix := range o.strippedHeaders {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *proxyOptions) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range o.trustedProxies {
+		if p.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hop is a proxy or the client in a chain of forwarding headers.
+type hop struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+// resolve returns the client info of a request of a trusted proxy.
+func (o *proxyOptions) resolve(r *http.Request, info ClientInfo) ClientInfo {
+	var hops []hop
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		hops = parseForwarded(values)
+	} else {
+		for _, ip := range headerValues(r.Header, "X-Forwarded-For") {
+			hops = append(hops, hop{ip: ip})
+		}
+		if len(hops) == 0 && r.Header.Get("X-Real-IP") != "" {
+			hops = append(hops, hop{ip: strings.TrimSpace(r.Header.Get("X-Real-IP"))})
+		}
+		if len(hops) == 0 {
+			hops = append(hops, hop{ip: info.IP})
+		}
+
+		// The proxies appending to these headers add their values in step with X-Forwarded-For, so they
+		// are matched from the right. The leftmost values may be set by the client.
+		schemes := headerValues(r.Header, "X-Forwarded-Proto")
+		hosts := headerValues(r.Header, "X-Forwarded-Host")
+		for i := range hops {
+			if j := len(schemes) - len(hops) + i; j >= 0 {
+				hops[i].scheme = schemes[j]
+			}
+			if j := len(hosts) - len(hops) + i; j >= 0 {
+				hops[i].host = hosts[j]
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0 && o.trusted(info.IP); i-- {
+		ip, ok := parseHopIP(hops[i].ip)
+		if !ok {
+			// Unknown or obfuscated address, the last trusted proxy is considered the client.
+			break
+		}
+
+		info.IP, info.Proxied = ip, true
+		if s := strings.ToLower(hops[i].scheme); s == "http" || s == "https" {
+			info.Scheme = s
+		}
+		if h := hops[i].host; h != "" && !strings.ContainsAny(h, "/ \t") {
+			info.Host = h
+		}
+	}
+
+	return info
+}
+
+// parseForwarded parses the elements of RFC 7239 Forwarded headers.
+func parseForwarded(values []string) []hop {
+	var hops []hop
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			var h hop
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+
+				value = strings.Trim(value, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					h.ip = value
+				case "proto":
+					h.scheme = value
+				case "host":
+					h.host = value
+				}
+			}
+			hops = append(hops, h)
+		}
+	}
+
+	return hops
+}
+
+// parseHopIP returns the IP of a forwarded address, which may have a port, the IPv6 addresses being
+// bracketed then.
+func parseHopIP(s string) (string, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return "", false
+	}
+
+	return addr.Unmap().String(), true
+}
+
+// headerValues returns the comma separated values of all the headers with the given name.
+func headerValues(h http.Header, name string) []string {
+	var values []string
+	for _, v := range h.Values(name) {
+		for _, value := range strings.Split(v, ",") {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+
+	return values
+}
+
+func hasForwardingHeaders(h http.Header) bool {
+	for _, name := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Real-Ip"} {
+		if _, ok := h[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// connectionInfo returns the client info of the connection of a request.
+func connectionInfo(r *http.Request) ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return ClientInfo{IP: ip, Scheme: scheme, Host: r.Host}
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyHeadersMiddleware(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, ::1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		expected   ClientInfo
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "203.0.113.7:1234",
+			expected:   ClientInfo{IP: "203.0.113.7", Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:       "untrusted forwarding headers",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}, "X-Forwarded-Proto": {"https"}},
+			expected:   ClientInfo{IP: "203.0.113.7", Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:       "x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For":   {"198.51.100.1", "203.0.113.7, 10.0.0.2"},
+				"X-Forwarded-Proto": {"https, http"},
+				"X-Forwarded-Host":  {"api.example.com"},
+			},
+			expected: ClientInfo{IP: "203.0.113.7", Scheme: "https", Host: "api.example.com", Proxied: true},
+		},
+		{
+			name:       "spoofed x-forwarded-proto",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For":   {"203.0.113.7"},
+				"X-Forwarded-Proto": {"https", "http"},
+				"X-Forwarded-Host":  {"evil.example.org, api.example.com"},
+			},
+			expected: ClientInfo{IP: "203.0.113.7", Scheme: "http", Host: "api.example.com", Proxied: true},
+		},
+		{
+			name:       "spoofed x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For":   {"10.0.0.3, 203.0.113.7"},
+				"X-Forwarded-Proto": {"https, http"},
+			},
+			expected: ClientInfo{IP: "203.0.113.7", Scheme: "http", Host: "example.com", Proxied: true},
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "[::1]:1234",
+			headers:    map[string][]string{"X-Real-Ip": {"203.0.113.7"}},
+			expected:   ClientInfo{IP: "203.0.113.7", Scheme: "http", Host: "example.com", Proxied: true},
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for=198.51.100.1, for="[2001:db8::17]:4711";proto=https;host=api.example.com`, "for=10.0.0.2"},
+				"X-Forwarded-For": {"192.0.2.1"},
+			},
+			expected: ClientInfo{IP: "2001:db8::17", Scheme: "https", Host: "api.example.com", Proxied: true},
+		},
+		{
+			name:       "obfuscated forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.2"}},
+			expected:   ClientInfo{IP: "10.0.0.2", Scheme: "http", Host: "example.com", Proxied: true},
+		},
+		{
+			name:       "trusted proxy without headers",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-Proto": {"https"}},
+			expected:   ClientInfo{IP: "10.0.0.1", Scheme: "https", Host: "example.com", Proxied: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info ClientInfo
+			handler := ProxyHeadersMiddleware(TrustProxies(trusted...))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				info = GetClientInfo(r)
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				r.Header[name] = values
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tt.expected, info)
+		})
+	}
+}
+
+func TestStripHeaders(t *testing.T) {
+	serve := func(opts ...ProxyOption) http.Header {
+		var header http.Header
+		handler := ProxyHeadersMiddleware(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Vulcand-Id", "1")
+		r.Header.Set("X-Internal-Id", "2")
+		r.Header.Set("X-Request-Id", "3")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return header
+	}
+
+	// The Vulcand headers are stripped by default.
+	header := serve()
+	assert.Empty(t, header.Get("X-Vulcand-Id"))
+	assert.Equal(t, "2", header.Get("X-Internal-Id"))
+	assert.Equal(t, "3", header.Get("X-Request-Id"))
+
+	header = serve(StripHeaders("x-internal"))
+	assert.Equal(t, "1", header.Get("X-Vulcand-Id"))
+	assert.Empty(t, header.Get("X-Internal-Id"))
+	assert.Equal(t, "3", header.Get("X-Request-Id"))
+
+	header = serve(StripHeaders())
+	assert.Equal(t, "1", header.Get("X-Vulcand-Id"))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.1.2.3/8, 192.168.1.1,")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}, proxies)
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	// Without ProxyHeadersMiddleware, the address of the connection.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	assert.Equal(t, "203.0.113.7", ClientIP(r))
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/starclusterteam/go-starbox/ratelimit"
+)
+
+// ErrTooManyRequests is returned when a client exceeds its rate limit.
+var ErrTooManyRequests = NewError("Too many requests", 301, http.StatusTooManyRequests)
+
+// RateLimitKeyFunc returns the key whose limit is consumed by a request. Requests with an empty key
+// are not limited.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitByIP limits the requests per client IP address, see ClientIP.
+func RateLimitByIP(r *http.Request) string {
+	return ClientIP(r)
+}
+
+// RateLimitByHeader limits the requests per value of the given header, e.g. an API key.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimitByPrincipal limits the requests per authenticated principal, the session stored under
+// SessionContextKey. The session must be a string or implement fmt.Stringer.
+func RateLimitByPrincipal(r *http.Request) string {
+	switch s := r.Context().Value(SessionContextKey).(type) {
+	case string:
+		return s
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return ""
+	}
+}
+
+// RateLimitByRoute limits the requests per route pattern and method, sharing the limit between all the
+// clients of the route.
+func RateLimitByRoute(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.Method + " " + r.URL.Path
+	}
+
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return r.Method + " " + r.URL.Path
+	}
+
+	return r.Method + " " + tpl
+}
+
+// RateLimitMiddleware limits the requests using the limiter and the key returned by keyFunc. Every
+// response gets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, while requests
+// over the limit get ErrTooManyRequests with a Retry-After header. Requests are let through if the
+// limiter fails, so an unavailable store doesn't take the service down. A nil keyFunc limits the requests
+// per client IP address, see RateLimitByIP.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, keyFunc RateLimitKeyFunc) Middleware {
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				GetLogger(r).Errorf("Rate limit check failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				HandleErrorResponse(w, r, ErrTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,109 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/ratelimit"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+type principal string
+
+func (p principal) String() string {
+	return string(p)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.PerMinute(2))
+	handler := web.RateLimitMiddleware(limiter, web.RateLimitByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("10.0.0.1:1234")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusNoContent, serve("10.0.0.1:4321").Code)
+
+	w = serve("10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	var resp web.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, web.ErrTooManyRequests.Code, resp.Code)
+	assert.Equal(t, []string{"Too many requests"}, resp.Messages)
+
+	assert.Equal(t, http.StatusNoContent, serve("10.0.0.2:1234").Code)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	r := httptest.NewRequest("GET", "/projects/1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Api-Key", "key")
+
+	assert.Equal(t, "10.0.0.1", web.RateLimitByIP(r))
+	assert.Equal(t, "key", web.RateLimitByHeader("X-Api-Key")(r))
+	assert.Equal(t, "", web.RateLimitByPrincipal(r))
+
+	r = r.WithContext(context.WithValue(r.Context(), web.SessionContextKey, principal("user-1")))
+	assert.Equal(t, "user-1", web.RateLimitByPrincipal(r))
+
+	var key string
+	router := mux.NewRouter()
+	router.HandleFunc("/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		key = web.RateLimitByRoute(r)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "GET /projects/{id}", key)
+}
+
+func TestRateLimitMiddlewareEmptyKey(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1))
+	handler := web.RateLimitMiddleware(limiter, web.RateLimitByHeader("X-Api-Key"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitMiddlewareDefaultKey(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1))
+	handler := web.RateLimitMiddleware(limiter, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(remoteAddr string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.1:1234"))
+	assert.Equal(t, http.StatusOK, serve("10.0.0.2:1234"))
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"no id", "", false},
+		{"valid id", "abc-123_DEF.4", true},
+		{"invalid characters", "abc\n123", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fromContext string
+			h := xRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Request-Id", test.incoming)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			id := w.Header().Get("X-Request-Id")
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, fromContext)
+			if test.keep {
+				assert.Equal(t, test.incoming, id)
+			} else {
+				assert.NotEqual(t, test.incoming, id)
+			}
+		})
+	}
+}
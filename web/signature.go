@@ -41,9 +41,9 @@ func SignURL(key string, u *url.URL) {
 	u.RawQuery = q.Encode()
 }
 
-// ValidSign validates signature of u using key
+// ValidSign validates signature of u using key. The signatures are compared in constant time.
 func ValidSign(key string, u *url.URL) bool {
-	return createCompatSign(key, u) == u.Query().Get("s")
+	return hmac.Equal([]byte(createCompatSign(key, u)), []byte(u.Query().Get("s")))
 }
 
 func canonicalizeQuery(query string) string {
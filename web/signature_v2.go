@@ -0,0 +1,431 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers carrying the request signatures created by Signer. URL signatures carry the same values in
+// query parameters with lowercase names, e.g. "x-signature".
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	// SignatureExpiresParam is the query parameter with the expiration time of signed URLs. Unlike the
+	// other parameters, it is part of the signed query.
+	SignatureExpiresParam = "x-signature-expires"
+)
+
+// signatureAlgorithm is the first line of the canonical request, identifying the signing scheme.
+const signatureAlgorithm = "STARBOX-HMAC-SHA256"
+
+const (
+	defaultSignatureClockSkew   = 5 * time.Minute
+	defaultSignatureMaxBodySize = 10 << 20
+)
+
+// minNonceSweep is the number of nonces from which MemoryNonceStore removes the expired ones.
+const minNonceSweep = 1024
+
+// Errors returned by SignatureVerifier.Verify.
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("expired signature")
+	ErrReplayedRequest  = errors.New("replayed request")
+	ErrBodyTooLarge     = errors.New("body too large")
+)
+
+// ErrRequestBodyTooLarge is sent by SignatureMiddleware for the requests whose body exceeds the limit of
+// the verifier.
+var ErrRequestBodyTooLarge = NewError("Request body too large", 202, http.StatusRequestEntityTooLarge)
+
+// Signer signs requests with HMAC-SHA256 over a canonical request made of the method, the escaped path,
+// the sorted query, the SHA-256 hash of the body, the timestamp, the key id and a random nonce. It
+// replaces the legacy CreateSign and SignURL, which only sign the query with HMAC-SHA1.
+type Signer struct {
+	keyID string
+	key   []byte
+	now   func() time.Time
+}
+
+// NewSigner returns a signer using the given key, identified by keyID so verifiers can support several
+// active keys during rotations.
+func NewSigner(keyID string, key []byte) *Signer {
+	return &Signer{keyID: keyID, key: key, now: time.Now}
+}
+
+// SignRequest signs the request, setting the signature headers. The body is read and replaced, so it can
+// still be sent.
+func (s *Signer) SignRequest(r *http.Request) error {
+	body, err := readBody(r, 0)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	sig := signature(s.key, canonicalRequest(r.Method, r.URL, body, ts, s.keyID, nonce))
+
+	r.Header.Set(SignatureHeader, sig)
+	r.Header.Set(SignatureKeyIDHeader, s.keyID)
+	r.Header.Set(SignatureTimestampHeader, ts)
+	r.Header.Set(SignatureNonceHeader, nonce)
+
+	return nil
+}
+
+// SignURL adds a signature to the query of u for requests with the given method and no body, e.g. links
+// to download files. The signature expires after ttl. Verifiers configured with a nonce store accept each
+// signed URL only once.
+func (s *Signer) SignURL(method string, u *url.URL, ttl time.Duration) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+
+	q := u.Query()
+	for _, p := range signatureParams {
+		q.Del(p)
+	}
+	q.Set(SignatureExpiresParam, strconv.FormatInt(now.Add(ttl).Unix(), 10))
+	u.RawQuery = q.Encode()
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := signature(s.key, canonicalRequest(method, u, nil, ts, s.keyID, nonce))
+
+	q.Set(strings.ToLower(SignatureHeader), sig)
+	q.Set(strings.ToLower(SignatureKeyIDHeader), s.keyID)
+	q.Set(strings.ToLower(SignatureTimestampHeader), ts)
+	q.Set(strings.ToLower(SignatureNonceHeader), nonce)
+	u.RawQuery = q.Encode()
+
+	return nil
+}
+
+// NonceStore remembers the nonces of verified requests to reject replays.
+type NonceStore interface {
+	// Seen records the nonce for ttl and reports whether it was already recorded.
+	Seen(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore keeping the nonces in memory. The expired nonces are removed each time
+// the number of nonces doubles.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	// sweepAt is the number of nonces from which the expired ones are removed.
+	sweepAt int
+	now     func() time.Time
+}
+
+// NewMemoryNonceStore returns a new in-memory nonce store.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time), sweepAt: minNonceSweep, now: time.Now}
+}
+
+// Seen implements NonceStore.
+func (s *MemoryNonceStore) Seen(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expiresAt, ok := s.nonces[nonce]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+
+	if len(s.nonces) >= s.sweepAt {
+		for n, expiresAt := range s.nonces {
+			if !now.Before(expiresAt) {
+				delete(s.nonces, n)
+			}
+		}
+		s.sweepAt = max(2*len(s.nonces), minNonceSweep)
+	}
+
+	s.nonces[nonce] = now.Add(ttl)
+	return false, nil
+}
+
+// SignatureVerifier verifies the requests signed by Signer.
+type SignatureVerifier struct {
+	keys        map[string][]byte
+	skew        time.Duration
+	maxBodySize int64
+	nonces      NonceStore
+	now         func() time.Time
+}
+
+// SignatureOption configures a SignatureVerifier.
+type SignatureOption func(*SignatureVerifier)
+
+// WithSignatureClockSkew sets how far the timestamp of a signed request may be from the current time.
+// Defaults to 5 minutes.
+func WithSignatureClockSkew(skew time.Duration) SignatureOption {
+	return func(v *SignatureVerifier) {
+		v.skew = skew
+	}
+}
+
+// WithSignatureMaxBodySize sets the maximum size in bytes of the bodies read to verify the signatures,
+// 10 MiB by default. The larger requests are rejected with ErrBodyTooLarge.
+func WithSignatureMaxBodySize(size int64) SignatureOption {
+	return func(v *SignatureVerifier) {
+		v.maxBodySize = size
+	}
+}
+
+// WithSignatureNonceStore rejects the requests whose nonce was already seen.
+func WithSignatureNonceStore(store NonceStore) SignatureOption {
+	return func(v *SignatureVerifier) {
+		v.nonces = store
+	}
+}
+
+// NewSignatureVerifier returns a verifier accepting signatures made with any of the given keys, indexed by
+// key id.
+func NewSignatureVerifier(keys map[string][]byte, opts ...SignatureOption) *SignatureVerifier {
+	v := &SignatureVerifier{
+		keys:        keys,
+		skew:        defaultSignatureClockSkew,
+		maxBodySize: defaultSignatureMaxBodySize,
+		now:         time.Now,
+	}
+
+	for _, o := range opts {
+		o(v)
+	}
+
+	return v
+}
+
+// Verify verifies the signature of the request, given by the signature headers or, for signed URLs, by
+// the query parameters. The body is read and replaced, so handlers can still read it.
+func (v *SignatureVerifier) Verify(r *http.Request) error {
+	params := signatureValues(r)
+
+	sig := params[SignatureHeader]
+	if sig == "" {
+		return ErrMissingSignature
+	}
+
+	key, ok := v.keys[params[SignatureKeyIDHeader]]
+	if !ok {
+		return errors.Wrapf(ErrInvalidSignature, "unknown key id %q", params[SignatureKeyIDHeader])
+	}
+
+	ts, err := strconv.ParseInt(params[SignatureTimestampHeader], 10, 64)
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "invalid timestamp")
+	}
+
+	now := v.now()
+	signedAt := time.Unix(ts, 0)
+	expiresAt := signedAt.Add(v.skew)
+
+	if exp := r.URL.Query().Get(SignatureExpiresParam); exp != "" {
+		e, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil {
+			return errors.Wrap(ErrInvalidSignature, "invalid expiration time")
+		}
+		expiresAt = time.Unix(e, 0)
+	}
+
+	if signedAt.After(now.Add(v.skew)) {
+		return errors.Wrap(ErrInvalidSignature, "timestamp is in the future")
+	}
+	if now.After(expiresAt) {
+		return ErrExpiredSignature
+	}
+
+	body, err := readBody(r, v.maxBodySize)
+	if err != nil {
+		return err
+	}
+
+	expected := signature(key, canonicalRequest(r.Method, r.URL, body, params[SignatureTimestampHeader], params[SignatureKeyIDHeader], params[SignatureNonceHeader]))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+
+	if v.nonces != nil {
+		nonce := params[SignatureNonceHeader]
+		if nonce == "" {
+			return errors.Wrap(ErrInvalidSignature, "missing nonce")
+		}
+
+		seen, err := v.nonces.Seen(r.Context(), nonce, expiresAt.Sub(now)+v.skew)
+		if err != nil {
+			return errors.Wrap(err, "failed to check nonce")
+		}
+		if seen {
+			return ErrReplayedRequest
+		}
+	}
+
+	return nil
+}
+
+// SignatureMiddleware rejects the requests without a valid signature with ErrInvalidCredentials, and the
+// requests with a body too large with ErrRequestBodyTooLarge. The other errors, e.g. of the nonce store,
+// are handled by HandleError.
+func SignatureMiddleware(v *SignatureVerifier) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := v.Verify(r); err != nil {
+				switch errors.Cause(err) {
+				case ErrMissingSignature, ErrInvalidSignature, ErrExpiredSignature, ErrReplayedRequest:
+					GetLogger(r).Infof("Rejected request signature: %v", err)
+					HandleErrorResponse(w, r, ErrInvalidCredentials)
+				case ErrBodyTooLarge:
+					HandleErrorResponse(w, r, ErrRequestBodyTooLarge)
+				default:
+					HandleError(w, r, errors.Wrap(err, "failed to verify request signature"))
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SigningTransport is an http.RoundTripper signing the outgoing requests.
+type SigningTransport struct {
+	Signer *Signer
+	// Base is the RoundTripper sending the signed requests. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *SigningTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	signed := r.Clone(r.Context())
+	if r.Body != nil && r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get request body")
+		}
+		signed.Body = body
+	}
+
+	if err := t.Signer.SignRequest(signed); err != nil {
+		return nil, errors.Wrap(err, "failed to sign request")
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signed)
+}
+
+var signatureParams = []string{
+	strings.ToLower(SignatureHeader),
+	strings.ToLower(SignatureKeyIDHeader),
+	strings.ToLower(SignatureTimestampHeader),
+	strings.ToLower(SignatureNonceHeader),
+}
+
+// signatureValues returns the signature values from the headers, or from the query for signed URLs.
+func signatureValues(r *http.Request) map[string]string {
+	headers := []string{SignatureHeader, SignatureKeyIDHeader, SignatureTimestampHeader, SignatureNonceHeader}
+	values := make(map[string]string, len(headers))
+
+	if r.Header.Get(SignatureHeader) != "" {
+		for _, h := range headers {
+			values[h] = r.Header.Get(h)
+		}
+		return values
+	}
+
+	q := r.URL.Query()
+	for i, h := range headers {
+		values[h] = q.Get(signatureParams[i])
+	}
+
+	return values
+}
+
+// canonicalRequest returns the string signed for a request. The query is decoded and re-encoded with
+// sorted keys, without the signature parameters, so that equivalent encodings get the same signature.
+func canonicalRequest(method string, u *url.URL, body []byte, ts, keyID, nonce string) string {
+	q := u.Query()
+	for _, p := range signatureParams {
+		q.Del(p)
+	}
+
+	bodyHash := sha256.Sum256(body)
+
+	return strings.Join([]string{
+		signatureAlgorithm,
+		strings.ToUpper(method),
+		u.EscapedPath(),
+		q.Encode(),
+		hex.EncodeToString(bodyHash[:]),
+		ts,
+		keyID,
+		nonce,
+	}, "\n")
+}
+
+func signature(key []byte, canonical string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate nonce")
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// readBody reads the body of the request and replaces it with a reader over the bytes read. Bodies larger
+// than limit bytes, if positive, give ErrBodyTooLarge.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	reader := r.Body
+	if limit > 0 {
+		reader = http.MaxBytesReader(nil, r.Body, limit)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, ErrBodyTooLarge
+		}
+		return nil, errors.Wrap(err, "failed to read body")
+	}
+	r.Body.Close()
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
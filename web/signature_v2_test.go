@@ -0,0 +1,236 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Request signature v2", func() {
+	var (
+		now      time.Time
+		clock    func() time.Time
+		signer   *Signer
+		verifier *SignatureVerifier
+	)
+
+	newRequest := func(method, target, body string) *http.Request {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+
+		return r
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		clock = func() time.Time { return now }
+
+		signer = NewSigner("key-2", []byte("secret-2"))
+		signer.now = clock
+
+		verifier = NewSignatureVerifier(map[string][]byte{
+			"key-1": []byte("secret-1"),
+			"key-2": []byte("secret-2"),
+		}, WithSignatureClockSkew(time.Minute), WithSignatureNonceStore(NewMemoryNonceStore()))
+		verifier.now = clock
+	})
+
+	Describe("SignRequest", func() {
+		It("should sign method, path, query and body", func() {
+			r := newRequest("POST", "/api/v1/projects?b=2&a=1", `{"name":"starbox"}`)
+			Expect(signer.SignRequest(r)).To(Succeed())
+			Expect(r.Header.Get(SignatureKeyIDHeader)).To(Equal("key-2"))
+			Expect(r.Header.Get(SignatureTimestampHeader)).To(Equal("1704110400"))
+			Expect(r.Header.Get(SignatureNonceHeader)).To(HaveLen(32))
+
+			Expect(verifier.Verify(r)).To(Succeed())
+
+			body, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal(`{"name":"starbox"}`))
+		})
+
+		It("should reject tampered requests", func() {
+			tamper := []func(r *http.Request){
+				func(r *http.Request) { r.Method = "PUT" },
+				func(r *http.Request) { r.URL.Path = "/api/v1/other" },
+				func(r *http.Request) { r.URL.RawQuery = "a=1&b=3" },
+				func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"name":"other"}`)) },
+				func(r *http.Request) { r.Header.Set(SignatureTimestampHeader, "1704110401") },
+				func(r *http.Request) { r.Header.Set(SignatureNonceHeader, "other") },
+				func(r *http.Request) { r.Header.Set(SignatureKeyIDHeader, "key-1") },
+				func(r *http.Request) { r.Header.Set(SignatureKeyIDHeader, "key-3") },
+			}
+
+			for _, t := range tamper {
+				r := newRequest("POST", "/api/v1/projects?b=2&a=1", `{"name":"starbox"}`)
+				Expect(signer.SignRequest(r)).To(Succeed())
+
+				t(r)
+				Expect(errors.Is(verifier.Verify(r), ErrInvalidSignature)).To(BeTrue())
+			}
+		})
+
+		It("should accept equivalent query encodings", func() {
+			r := newRequest("GET", "/files?url=http%3A%2F%2Fgoogle.com%2F&width=10", "")
+			Expect(signer.SignRequest(r)).To(Succeed())
+
+			r.URL.RawQuery = "width=10&url=http://google.com/"
+			Expect(verifier.Verify(r)).To(Succeed())
+		})
+
+		It("should reject replayed requests", func() {
+			r := newRequest("GET", "/files", "")
+			Expect(signer.SignRequest(r)).To(Succeed())
+			Expect(verifier.Verify(r)).To(Succeed())
+			Expect(verifier.Verify(r)).To(MatchError(ErrReplayedRequest))
+		})
+
+		It("should enforce the clock skew", func() {
+			r := newRequest("GET", "/files", "")
+			Expect(signer.SignRequest(r)).To(Succeed())
+
+			now = now.Add(61 * time.Second)
+			Expect(verifier.Verify(r)).To(MatchError(ErrExpiredSignature))
+
+			now = now.Add(-122 * time.Second)
+			Expect(errors.Is(verifier.Verify(r), ErrInvalidSignature)).To(BeTrue())
+		})
+
+		It("should require a signature", func() {
+			Expect(verifier.Verify(newRequest("GET", "/files", ""))).To(MatchError(ErrMissingSignature))
+		})
+	})
+
+	Describe("SignURL", func() {
+		It("should sign URLs until they expire", func() {
+			u := mustParse("http://proxy/files?width=100&height=100")
+			Expect(signer.SignURL("GET", u, time.Hour)).To(Succeed())
+			Expect(u.Query().Get(SignatureExpiresParam)).To(Equal("1704114000"))
+
+			now = now.Add(59 * time.Minute)
+			Expect(verifier.Verify(newRequest("GET", u.String(), ""))).To(Succeed())
+
+			tampered := *u
+			tampered.RawQuery = strings.Replace(u.RawQuery, "x-signature-expires=1704114000", "x-signature-expires=1804114000", 1)
+			Expect(errors.Is(verifier.Verify(newRequest("GET", tampered.String(), "")), ErrInvalidSignature)).To(BeTrue())
+
+			verifier.nonces = nil
+			now = now.Add(2 * time.Minute)
+			Expect(verifier.Verify(newRequest("GET", u.String(), ""))).To(MatchError(ErrExpiredSignature))
+		})
+	})
+
+	Describe("SignatureMiddleware", func() {
+		It("should reject invalid signatures with ErrInvalidCredentials", func() {
+			handler := SignatureMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Write(body)
+			}))
+
+			r := newRequest("POST", "/hooks", "payload")
+			Expect(signer.SignRequest(r)).To(Succeed())
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("payload"))
+
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, newRequest("POST", "/hooks", "payload"))
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(w.Body.String()).To(ContainSubstring("Invalid credentials"))
+		})
+
+		It("should reject bodies larger than the limit before checking the signature", func() {
+			verifier.maxBodySize = 4
+			handler := SignatureMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := newRequest("POST", "/hooks", "payload")
+			Expect(signer.SignRequest(r)).To(Succeed())
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+
+		It("should fail with an internal error if the nonce store fails", func() {
+			verifier.nonces = failingNonceStore{}
+			handler := SignatureMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := newRequest("GET", "/hooks", "")
+			Expect(signer.SignRequest(r)).To(Succeed())
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Describe("MemoryNonceStore", func() {
+		It("should remove the expired nonces when the number of nonces doubles", func() {
+			store := NewMemoryNonceStore()
+			store.now = clock
+
+			for i := 0; i < minNonceSweep; i++ {
+				Expect(store.Seen(context.Background(), strconv.Itoa(i), time.Minute)).To(BeFalse())
+			}
+			Expect(store.Seen(context.Background(), "0", time.Minute)).To(BeTrue())
+
+			now = now.Add(time.Minute)
+			Expect(store.Seen(context.Background(), "0", time.Minute)).To(BeFalse())
+			Expect(store.nonces).To(HaveLen(1))
+			Expect(store.sweepAt).To(Equal(minNonceSweep))
+		})
+	})
+
+	Describe("SigningTransport", func() {
+		It("should sign outgoing requests", func() {
+			verifier.now = time.Now
+
+			server := httptest.NewServer(SignatureMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Write(body)
+			})))
+			defer server.Close()
+
+			client := &http.Client{Transport: &SigningTransport{Signer: NewSigner("key-1", []byte("secret-1"))}}
+
+			resp, err := client.Post(server.URL+"/hooks?id=1", "text/plain", strings.NewReader("payload"))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(body)).To(Equal("payload"))
+		})
+	})
+})
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Seen(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func mustParse(rawurl string) *url.URL {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+}
@@ -0,0 +1,339 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/starclusterteam/go-starbox/certwatch"
+	"github.com/starclusterteam/go-starbox/config"
+	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/log"
+)
+
+type tlsOptions struct {
+	certFile      string
+	keyFile       string
+	clientCAFiles []string
+	clientAuth    tls.ClientAuthType
+	minVersion    uint16
+	maxVersion    uint16
+	cipherSuites  []uint16
+}
+
+// config returns the server TLS configuration, and the watcher reloading the files to close with the server.
+func (o tlsOptions) config(certFile, keyFile string) (*tls.Config, *certwatch.Watcher, error) {
+	certs, err := certwatch.New(certFile, keyFile, o.clientCAFiles)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load certificates")
+	}
+
+	c := certs.ServerTLSConfig(o.clientAuth)
+	c.MinVersion = o.minVersion
+	c.MaxVersion = o.maxVersion
+	c.CipherSuites = o.cipherSuites
+
+	return c, certs, nil
+}
+
+// WithServerTLSFromParams makes Run serve HTTPS using the given paths for the certificate, key and client
+// CAs. If client CAs are given, the clients must present a certificate signed by one of them, unless
+// changed with WithClientAuth. The files are reloaded when they change.
+func WithServerTLSFromParams(certFile, keyFile string, clientCAFiles []string) Option {
+	return func(o *serverOptions) {
+		o.tls.certFile = certFile
+		o.tls.keyFile = keyFile
+		o.tls.clientCAFiles = clientCAFiles
+	}
+}
+
+// WithServerTLS is similar to WithServerTLSFromParams, only it reads the certificate, key and client CAs
+// paths from the WEB_SERVER_CERT, WEB_SERVER_KEY and WEB_CLIENT_CA environment variables. WEB_CLIENT_CA is
+// optional and may hold several comma separated paths. The client authentication and the minimum TLS
+// version can be set with WEB_CLIENT_AUTH (e.g. "VerifyClientCertIfGiven") and WEB_TLS_MIN_VERSION (e.g. "1.3").
+func WithServerTLS() Option {
+	certFile := config.String(envvar.WebServerCert, "")
+	if certFile == "" {
+		log.Fatalf("%s must be set", envvar.WebServerCert)
+	}
+
+	keyFile := config.String(envvar.WebServerKey, "")
+	if keyFile == "" {
+		log.Fatalf("%s must be set", envvar.WebServerKey)
+	}
+
+	var clientCAFiles []string
+	if cas := config.String(envvar.WebClientCA, ""); cas != "" {
+		clientCAFiles = strings.Split(cas, ",")
+	}
+
+	opts := []Option{WithServerTLSFromParams(certFile, keyFile, clientCAFiles)}
+
+	if s := config.String(envvar.WebClientAuth, ""); s != "" {
+		clientAuth, err := parseClientAuth(s)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", envvar.WebClientAuth, err)
+		}
+		opts = append(opts, WithClientAuth(clientAuth))
+	}
+
+	if s := config.String(envvar.WebTLSMinVersion, ""); s != "" {
+		version, err := parseTLSVersion(s)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", envvar.WebTLSMinVersion, err)
+		}
+		opts = append(opts, WithTLSVersions(version, 0))
+	}
+
+	return func(o *serverOptions) {
+		for _, opt := range opts {
+			opt(o)
+		}
+	}
+}
+
+// WithMTLS requires the clients to present a certificate signed by one of the given CAs.
+func WithMTLS(clientCAFiles ...string) Option {
+	return func(o *serverOptions) {
+		o.tls.clientCAFiles = clientCAFiles
+	}
+}
+
+// WithClientAuth sets the client authentication policy used when client CAs are configured. It defaults
+// to tls.RequireAndVerifyClientCert. The certificates are only verified, and used by
+// ClientCertMiddleware, with tls.VerifyClientCertIfGiven and tls.RequireAndVerifyClientCert.
+func WithClientAuth(clientAuth tls.ClientAuthType) Option {
+	return func(o *serverOptions) {
+		o.tls.clientAuth = clientAuth
+	}
+}
+
+// WithTLSVersions sets the minimum and maximum TLS versions, e.g. tls.VersionTLS13. A zero value keeps
+// the default of the crypto/tls package.
+func WithTLSVersions(min, max uint16) Option {
+	return func(o *serverOptions) {
+		o.tls.minVersion = min
+		o.tls.maxVersion = max
+	}
+}
+
+// WithCipherSuites sets the cipher suites enabled for TLS 1.0 to 1.2, e.g. tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+// The TLS 1.3 cipher suites are not configurable.
+func WithCipherSuites(suites ...uint16) Option {
+	return func(o *serverOptions) {
+		o.tls.cipherSuites = suites
+	}
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	for _, t := range []tls.ClientAuthType{
+		tls.NoClientCert,
+		tls.RequestClientCert,
+		tls.RequireAnyClientCert,
+		tls.VerifyClientCertIfGiven,
+		tls.RequireAndVerifyClientCert,
+	} {
+		if strings.EqualFold(s, t.String()) {
+			return t, nil
+		}
+	}
+
+	return 0, errors.Errorf("unknown client auth type %q", s)
+}
+
+func parseTLSVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(s), "TLS") {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, errors.Errorf("unknown TLS version %q", s)
+}
+
+// ClientCertificate is the identity of a client authenticated with a TLS certificate.
+type ClientCertificate struct {
+	Subject        pkix.Name
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+	// SPIFFEID is the spiffe:// URI of the certificate, if any.
+	SPIFFEID    string
+	Certificate *x509.Certificate
+}
+
+func newClientCertificate(cert *x509.Certificate) *ClientCertificate {
+	c := &ClientCertificate{
+		Subject:        cert.Subject,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}
+
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			c.SPIFFEID = u.String()
+			break
+		}
+	}
+
+	return c
+}
+
+type clientCertificateKey struct{}
+
+// ContextWithClientCertificate returns a new context carrying the client certificate.
+func ContextWithClientCertificate(ctx context.Context, c *ClientCertificate) context.Context {
+	return context.WithValue(ctx, clientCertificateKey{}, c)
+}
+
+// ClientCertificateFromContext returns the client certificate stored by ClientCertMiddleware.
+func ClientCertificateFromContext(ctx context.Context) (*ClientCertificate, bool) {
+	c, ok := ctx.Value(clientCertificateKey{}).(*ClientCertificate)
+	return c, ok
+}
+
+// GetClientCertificate returns the client certificate of a request, stored by ClientCertMiddleware.
+func GetClientCertificate(r *http.Request) (*ClientCertificate, bool) {
+	return ClientCertificateFromContext(r.Context())
+}
+
+type clientCertOptions struct {
+	spiffeIDs   []string
+	commonNames map[string]bool
+}
+
+// ClientCertOption is a functional option for ClientCertMiddleware.
+type ClientCertOption func(*clientCertOptions)
+
+// AllowSPIFFEIDs allows the clients with one of the given SPIFFE IDs. An ID ending with "/*" allows all
+// the IDs under its path, e.g. "spiffe://example.org/ns/prod/*".
+func AllowSPIFFEIDs(ids ...string) ClientCertOption {
+	return func(o *clientCertOptions) {
+		o.spiffeIDs = append(o.spiffeIDs, ids...)
+	}
+}
+
+// AllowCommonNames allows the clients whose certificate has one of the given subject common names.
+func AllowCommonNames(names ...string) ClientCertOption {
+	return func(o *clientCertOptions) {
+		for _, n := range names {
+			o.commonNames[n] = true
+		}
+	}
+}
+
+func (o *clientCertOptions) restricted() bool {
+	return len(o.spiffeIDs) > 0 || len(o.commonNames) > 0
+}
+
+func (o *clientCertOptions) allowed(c *ClientCertificate) bool {
+	if o.commonNames[c.Subject.CommonName] {
+		return true
+	}
+
+	if c.SPIFFEID == "" {
+		return false
+	}
+
+	for _, id := range o.spiffeIDs {
+		if prefix := strings.TrimSuffix(id, "*"); prefix != id {
+			if strings.HasPrefix(c.SPIFFEID, prefix) {
+				return true
+			}
+		} else if c.SPIFFEID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCertMiddleware stores the client certificate verified by the server in the request context, where
+// it can be retrieved with GetClientCertificate. The certificates which weren't verified, e.g. with the
+// RequestClientCert client authentication, are ignored. Without options, requests without certificate are
+// let through. With allow-lists, they get ErrUnauthorized and the clients allowed by none of the lists get
+// a 403. The server must verify the certificates, see WithServerTLSFromParams and WithClientAuth.
+func ClientCertMiddleware(opts ...ClientCertOption) Middleware {
+	o := clientCertOptions{commonNames: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || (len(r.TLS.VerifiedChains) == 0 && !serverVerified(r.Context())) {
+				if o.restricted() {
+					HandleErrorResponse(w, r, ErrUnauthorized)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c := newClientCertificate(r.TLS.PeerCertificates[0])
+			if o.restricted() && !o.allowed(c) {
+				HandleErrorResponse(w, r, NewForbidden("Client certificate not allowed"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClientCertificate(r.Context(), c)))
+		})
+	}
+}
+
+// WithClientCertificates adds ClientCertMiddleware with the given options to all the routes, except the
+// ping and health routes. It runs before the authentication enabled with WithAuth. Allow-lists require a
+// client authentication verifying the certificates, VerifyClientCertIfGiven or RequireAndVerifyClientCert.
+func WithClientCertificates(opts ...ClientCertOption) Option {
+	return func(o *serverOptions) {
+		o.clientCert = true
+		o.clientCertOpts = append(o.clientCertOpts, opts...)
+	}
+}
+
+// clientCertMiddleware returns the ClientCertMiddleware of the server. The allow-lists require a client
+// authentication mode verifying the certificates.
+func (o tlsOptions) clientCertMiddleware(opts []ClientCertOption) Middleware {
+	co := clientCertOptions{commonNames: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&co)
+	}
+	if co.restricted() && !o.verifying() {
+		log.Fatalf("client certificate allow-lists require verified certificates, got client auth %s", o.clientAuth)
+	}
+
+	return ClientCertMiddleware(opts...)
+}
+
+// verifying returns true if the client certificates are verified against the client CAs.
+func (o tlsOptions) verifying() bool {
+	return o.clientAuth == tls.VerifyClientCertIfGiven || o.clientAuth == tls.RequireAndVerifyClientCert
+}
+
+type serverVerifiedKey struct{}
+
+// verifiedConnContext marks the connections of a server verifying the client certificates itself, which
+// leaves the verified chains of the requests empty, see certwatch.Watcher.ServerTLSConfig.
+func verifiedConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, serverVerifiedKey{}, true)
+}
+
+func serverVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(serverVerifiedKey{}).(bool)
+	return verified
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/health"
+	"github.com/starclusterteam/go-starbox/testutil"
+)
+
+func issueClientCertificate(t *testing.T, commonName string, hosts ...string) *x509.Certificate {
+	ca, err := testutil.NewCertificateAuthority("client-ca")
+	require.NoError(t, err)
+
+	cert, _, err := ca.Issue(commonName, hosts...)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(cert)
+	c, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestRunWithMTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	serverCA, err := testutil.NewCertificateAuthority("server-ca")
+	require.NoError(t, err)
+	clientCA, err := testutil.NewCertificateAuthority("client-ca")
+	require.NoError(t, err)
+
+	serverCert, serverKey, err := serverCA.Issue("server", "localhost")
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server-key.pem")
+	caFile := filepath.Join(dir, "client-ca.pem")
+	require.NoError(t, os.WriteFile(certFile, serverCert, 0600))
+	require.NoError(t, os.WriteFile(keyFile, serverKey, 0600))
+	require.NoError(t, os.WriteFile(caFile, clientCA.CertPEM, 0600))
+
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := GetClientCertificate(r)
+		require.True(t, ok)
+		w.Write([]byte(c.SPIFFEID))
+	})
+
+	// ClientCertMiddleware can also be used on its own.
+	standalone := NewRoute("GET", "/standalone", whoami).WithMiddlewares(ClientCertMiddleware(AllowCommonNames("client")))
+
+	server := New(Routes{NewRoute("GET", "/whoami", whoami), standalone},
+		WithAddr("localhost:18889"),
+		WithHealthRegistry(health.NewRegistry()),
+		WithServerTLSFromParams(certFile, keyFile, []string{caFile}),
+		WithTLSVersions(tls.VersionTLS13, 0),
+		WithClientCertificates(AllowSPIFFEIDs("spiffe://example.org/ns/prod/*")),
+	)
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Run() }()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.Cert)
+
+	getPath := func(path string, config *tls.Config, certificates ...tls.Certificate) (int, string, error) {
+		config.RootCAs = roots
+		config.Certificates = certificates
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+		resp, err := client.Get("https://localhost:18889" + path)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), err
+	}
+	get := func(config *tls.Config, certificates ...tls.Certificate) (int, string, error) {
+		return getPath("/whoami", config, certificates...)
+	}
+
+	issue := func(hosts ...string) tls.Certificate {
+		cert, key, err := clientCA.Issue("client", hosts...)
+		require.NoError(t, err)
+
+		pair, err := tls.X509KeyPair(cert, key)
+		require.NoError(t, err)
+		return pair
+	}
+
+	allowed := issue("spiffe://example.org/ns/prod/sa/api")
+	assert.Eventually(t, func() bool {
+		_, _, err := get(&tls.Config{}, allowed)
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	status, body, err := get(&tls.Config{}, allowed)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "spiffe://example.org/ns/prod/sa/api", body)
+
+	status, _, err = get(&tls.Config{}, issue("spiffe://example.org/ns/dev/sa/api"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body, err = getPath("/standalone", &tls.Config{}, allowed)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "spiffe://example.org/ns/prod/sa/api", body)
+
+	_, _, err = get(&tls.Config{})
+	assert.Error(t, err, "client certificate required")
+
+	_, _, err = get(&tls.Config{MaxVersion: tls.VersionTLS12}, allowed)
+	assert.Error(t, err, "TLS 1.2 not allowed")
+
+	require.NoError(t, server.Stop(context.Background()))
+	assert.NoError(t, <-errc)
+}
+
+func TestClientCertMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []ClientCertOption
+		cert   *x509.Certificate
+		status int
+	}{
+		{"no allow-list without certificate", nil, nil, http.StatusOK},
+		{"no allow-list", nil, issueClientCertificate(t, "billing"), http.StatusOK},
+		{"no allow-list with unverified certificate", nil, issueClientCertificate(t, "billing"), http.StatusOK},
+		{"unverified certificate", []ClientCertOption{AllowCommonNames("billing")}, issueClientCertificate(t, "billing"), http.StatusUnauthorized},
+		{"allow-list without certificate", []ClientCertOption{AllowCommonNames("billing")}, nil, http.StatusUnauthorized},
+		{"allowed common name", []ClientCertOption{AllowCommonNames("billing")}, issueClientCertificate(t, "billing"), http.StatusOK},
+		{"other common name", []ClientCertOption{AllowCommonNames("billing")}, issueClientCertificate(t, "orders"), http.StatusForbidden},
+		{
+			"allowed SPIFFE ID",
+			[]ClientCertOption{AllowCommonNames("billing"), AllowSPIFFEIDs("spiffe://example.org/orders")},
+			issueClientCertificate(t, "orders", "spiffe://example.org/orders"),
+			http.StatusOK,
+		},
+		{
+			"SPIFFE ID prefix",
+			[]ClientCertOption{AllowSPIFFEIDs("spiffe://example.org/orders")},
+			issueClientCertificate(t, "orders", "spiffe://example.org/orders/admin"),
+			http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got *ClientCertificate
+			handler := ClientCertMiddleware(test.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = GetClientCertificate(r)
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			verified := !strings.Contains(test.name, "unverified")
+			if test.cert != nil {
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{test.cert}}
+				if verified {
+					r.TLS.VerifiedChains = [][]*x509.Certificate{{test.cert}}
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, test.status, w.Code)
+
+			if !verified {
+				assert.Nil(t, got)
+			}
+			if test.status == http.StatusOK && test.cert != nil && verified {
+				require.NotNil(t, got)
+				assert.Equal(t, test.cert.Subject.CommonName, got.Subject.CommonName)
+			}
+		})
+	}
+}
+
+func TestParseTLSSettings(t *testing.T) {
+	v, err := parseTLSVersion("1.3")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	v, err = parseTLSVersion("TLS12")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), v)
+
+	_, err = parseTLSVersion("2.0")
+	assert.Error(t, err)
+
+	a, err := parseClientAuth("verifyclientcertifgiven")
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, a)
+
+	_, err = parseClientAuth("always")
+	assert.Error(t, err)
+}
@@ -54,20 +54,22 @@ func TracingMiddleware(tracer opentracing.Tracer, operationName string) func(htt
 
 			// span tags
 			ext.SpanKind.Set(span, ext.SpanKindRPCServerEnum)
-			ext.HTTPUrl.Set(span, r.URL.String())
+			ext.HTTPUrl.Set(span, log.RedactURL(r.URL))
 			ext.HTTPMethod.Set(span, r.Method)
-			ext.PeerAddress.Set(span, r.RemoteAddr)
-
-			host, port, err := net.SplitHostPort(r.RemoteAddr)
-			if err == nil {
-				ip := net.ParseIP(host)
+			// The peer is the client of the request, see ClientIP.
+			client := GetClientInfo(r)
+			ext.PeerAddress.Set(span, client.IP)
 
+			if ip := net.ParseIP(client.IP); ip != nil {
 				if ipv4 := ip.To4(); ipv4 != nil {
 					ext.PeerHostIPv4.Set(span, binary.BigEndian.Uint32(ipv4))
 				} else {
 					ext.PeerHostIPv6.Set(span, ip.String())
 				}
+			}
 
+			// The port of a client behind proxies is unknown.
+			if _, port, err := net.SplitHostPort(r.RemoteAddr); err == nil && !client.Proxied {
 				uintPort, err := strconv.ParseUint(port, 10, 16)
 				if err == nil {
 					ext.PeerPort.Set(span, uint16(uintPort))
@@ -168,7 +170,7 @@ func TraceableRequestFunc(tracer opentracing.Tracer) RequestFunc {
 		// add standard OpenTracing tags
 		ext.SpanKind.Set(span, ext.SpanKindRPCClientEnum)
 		ext.HTTPMethod.Set(span, r.Method)
-		ext.HTTPUrl.Set(span, r.URL.String())
+		ext.HTTPUrl.Set(span, log.RedactURL(r.URL))
 		ext.PeerHostname.Set(span, r.URL.Host)
 
 		// inject the span context into the outgoing HTTP request
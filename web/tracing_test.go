@@ -8,6 +8,7 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/starclusterteam/go-starbox/log"
 	"github.com/starclusterteam/go-starbox/web"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,7 +18,7 @@ import (
 func TestTracingMiddleware(t *testing.T) {
 	var (
 		method   = "PUT"
-		url      = "/dummy"
+		url      = "/dummy?token=secret"
 		tracer   = mocktracer.New()
 		finalReq *http.Request
 	)
@@ -41,7 +42,7 @@ func TestTracingMiddleware(t *testing.T) {
 	span := tracer.FinishedSpans()[0]
 
 	assert.Equal(t, ext.SpanKindEnum("server"), span.Tags()[string(ext.SpanKind)])
-	assert.Equal(t, url, span.Tags()[string(ext.HTTPUrl)])
+	assert.Equal(t, "/dummy?token="+log.Redacted, span.Tags()[string(ext.HTTPUrl)])
 	assert.Equal(t, method, span.Tags()[string(ext.HTTPMethod)])
 
 	// Check if the trace id header was set.
@@ -151,7 +152,7 @@ func TestTracedTransport(t *testing.T) {
 func TestTraceableRequestFunc(t *testing.T) {
 	var (
 		method      = "PUT"
-		url         = "/dummy"
+		url         = "/dummy?token=secret"
 		tracer      = mocktracer.New()
 		wireContext opentracing.SpanContext
 	)
@@ -167,7 +168,7 @@ func TestTraceableRequestFunc(t *testing.T) {
 	assert.Equal(t, span.Context(), wireContext)
 
 	assert.Equal(t, ext.SpanKindEnum("client"), span.Tags()[string(ext.SpanKind)])
-	assert.Equal(t, url, span.Tags()[string(ext.HTTPUrl)])
+	assert.Equal(t, "/dummy?token="+log.Redacted, span.Tags()[string(ext.HTTPUrl)])
 	assert.Equal(t, method, span.Tags()[string(ext.HTTPMethod)])
 }
 
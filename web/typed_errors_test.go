@@ -0,0 +1,58 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/starclusterteam/go-starbox/errors"
+	"github.com/starclusterteam/go-starbox/web"
+)
+
+func TestHandleTypedError(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := errors.Wrap(errors.NotFound("user not found").WithCode(1001), "failed to get user")
+	web.HandleError(w, httptest.NewRequest("GET", "/", nil), err)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var resp web.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, web.ErrorResponse{
+		Messages: []string{"user not found"},
+		Code:     1001,
+		Errors:   map[string][]string{"not_found": {"user not found"}},
+	}, resp)
+}
+
+func TestHandleTypedInternalError(t *testing.T) {
+	w := httptest.NewRecorder()
+	web.HandleError(w, httptest.NewRequest("GET", "/", nil), errors.Internal(fmt.Errorf("secret dsn")))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret dsn")
+}
+
+func TestParseErrorRoundTrip(t *testing.T) {
+	tests := []*errors.Error{
+		errors.NotFound("user not found"),
+		errors.Conflict("email taken").WithCode(2002),
+		errors.InvalidArgument("invalid request").WithField("email", "is required").WithField("name", "too long", "invalid characters"),
+		errors.Unauthenticated("invalid token"),
+	}
+
+	for _, expected := range tests {
+		t.Run(expected.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			web.HandleError(w, httptest.NewRequest("GET", "/", nil), expected)
+
+			err := web.ParseError(w.Code, w.Body.Bytes())
+			assert.Equal(t, expected, err)
+		})
+	}
+}
@@ -3,7 +3,6 @@ package web
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"github.com/starclusterteam/go-starbox/apm"
 )
@@ -16,9 +15,20 @@ func WriteJSON(w http.ResponseWriter, code int, value interface{}) {
 	w.Write(valueJSON)
 }
 
-// HandleError logs the error then responds with generic 500 message
+// HandleError responds with the status, code and public message of *ErrorResponse errors and of errors
+// created with errors.E and its helpers. Any other error is reported, then a generic 500 message is sent.
 func HandleError(w http.ResponseWriter, req *http.Request, err error) {
-	apm.GlobalReporter.Report(req.Context(), err)
+	resp := NewErrorResponseFromError(err)
+	if !resp.isInternalError {
+		WriteJSON(w, resp.HTTPStatus, resp)
+		return
+	}
+
+	if resp.internalError != nil {
+		err = resp.internalError
+	}
+
+	apm.Report(req.Context(), err)
 
 	WriteJSON(w, http.StatusInternalServerError, &ErrorResponse{
 		Messages: []string{"Something went wrong, error has been reported and we'll look into it as soon as possible"},
@@ -27,16 +37,3 @@ func HandleError(w http.ResponseWriter, req *http.Request, err error) {
 		},
 	})
 }
-
-// blacklistHeaders takes a map of headers and returns a map without
-// the header matching "X-Vulcand"
-// TODO: make it configurable
-func blacklistHeaders(headers map[string]string) map[string]string {
-	res := make(map[string]string)
-	for k, v := range headers {
-		if !strings.Contains(strings.ToLower(k), strings.ToLower("X-Vulcand")) {
-			res[k] = v
-		}
-	}
-	return res
-}
@@ -0,0 +1,401 @@
+package web
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// ValidationRule validates a field value against the rule parameter, e.g. "3" for "min=3".
+// Pointers are dereferenced before the rule is called and rules are not called for nil pointers.
+// The returned error message is prefixed with the field path, e.g. "items[2].name must be ...".
+type ValidationRule func(value reflect.Value, param string) error
+
+var (
+	validationRulesMu sync.RWMutex
+	validationRules   = map[string]ValidationRule{
+		"min":   validateMin,
+		"max":   validateMax,
+		"len":   validateLen,
+		"oneof": validateOneOf,
+		"regex": validateRegex,
+		"email": validateEmail,
+		"url":   validateURL,
+		"uuid":  validateUUID,
+		"ksuid": validateKSUID,
+	}
+
+	structRulesCache sync.Map // map[reflect.Type][]fieldRules
+	regexCache       sync.Map // map[string]*regexp.Regexp
+)
+
+// RegisterValidation registers a custom rule usable in `validate` struct tags under the given name.
+// Registering an existing name replaces the rule.
+func RegisterValidation(name string, rule ValidationRule) {
+	validationRulesMu.Lock()
+	defer validationRulesMu.Unlock()
+
+	validationRules[name] = rule
+}
+
+// ValidateStruct validates the fields of a struct according to their `validate` tags and returns the
+// validation errors, using the JSON names of the fields as paths. Rules are separated by commas:
+//
+//	type Item struct {
+//		Name  string   `json:"name" validate:"required,max=64"`
+//		Kind  string   `json:"kind" validate:"oneof=book movie"`
+//		Email string   `json:"email" validate:"omitempty,email"`
+//		Code  string   `json:"code" validate:"regex=^[A-Z]{3}$"`
+//	}
+//
+// Built-in rules are required, omitempty, min, max and len (length of strings, slices and maps, value of
+// numbers), oneof (space separated values), regex (must be the last rule), email, url, uuid and ksuid.
+// Nested structs, and structs inside slices and maps, are validated recursively with paths such as
+// "items[2].name". Fields tagged with `validate:"-"` are skipped.
+func ValidateStruct(v interface{}) []ValidationError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return validateStruct(rv, "")
+}
+
+type fieldRules struct {
+	index     []int
+	name      string
+	required  bool
+	omitEmpty bool
+	skip      bool
+	rules     []rule
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+func validateStruct(v reflect.Value, prefix string) []ValidationError {
+	var errs []ValidationError
+
+	for _, f := range rulesOf(v.Type()) {
+		if f.skip {
+			continue
+		}
+
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// Field of a nil embedded struct pointer.
+			continue
+		}
+
+		errs = append(errs, validateField(fv, joinPath(prefix, f.name), f)...)
+	}
+
+	return errs
+}
+
+func validateField(v reflect.Value, path string, f fieldRules) []ValidationError {
+	if isEmpty(v) {
+		if f.required {
+			return []ValidationError{NewValidationError(path, path+" is required")}
+		}
+
+		if f.omitEmpty || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			return nil
+		}
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	var errs []ValidationError
+	for _, r := range f.rules {
+		validationRulesMu.RLock()
+		fn, ok := validationRules[r.name]
+		validationRulesMu.RUnlock()
+
+		if !ok {
+			errs = append(errs, NewValidationError(path, fmt.Sprintf("%s has unknown validation rule %q", path, r.name)))
+			continue
+		}
+
+		if err := fn(v, r.param); err != nil {
+			errs = append(errs, NewValidationError(path, path+" "+err.Error()))
+		}
+	}
+
+	return append(errs, validateNested(v, path)...)
+}
+
+func validateNested(v reflect.Value, path string) []ValidationError {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		return validateStruct(v, path)
+	case reflect.Slice, reflect.Array:
+		var errs []ValidationError
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateElem(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case reflect.Map:
+		var errs []ValidationError
+		iter := v.MapRange()
+		for iter.Next() {
+			errs = append(errs, validateElem(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key().Interface()))...)
+		}
+		return errs
+	default:
+		return nil
+	}
+}
+
+func validateElem(v reflect.Value, path string) []ValidationError {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	return validateNested(v, path)
+}
+
+func rulesOf(t reflect.Type) []fieldRules {
+	if cached, ok := structRulesCache.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+
+	var fields []fieldRules
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+
+		f := fieldRules{index: sf.Index, name: fieldPath(sf)}
+
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			f.skip = true
+		}
+
+		for tag != "" && !f.skip {
+			var part string
+			if strings.HasPrefix(tag, "regex=") {
+				part, tag = tag, ""
+			} else if i := strings.Index(tag, ","); i >= 0 {
+				part, tag = tag[:i], tag[i+1:]
+			} else {
+				part, tag = tag, ""
+			}
+
+			name, param, _ := strings.Cut(part, "=")
+			switch name {
+			case "required":
+				f.required = true
+			case "omitempty":
+				f.omitEmpty = true
+			case "":
+			default:
+				f.rules = append(f.rules, rule{name: name, param: param})
+			}
+		}
+
+		fields = append(fields, f)
+	}
+
+	structRulesCache.Store(t, fields)
+	return fields
+}
+
+// hasRule reports whether the `validate` tag of a field contains the given rule.
+func hasRule(f reflect.StructField, name string) bool {
+	for _, part := range strings.Split(f.Tag.Get("validate"), ",") {
+		if strings.HasPrefix(part, "regex=") {
+			return false
+		}
+		if part == name || strings.HasPrefix(part, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldPath returns the name of a field in validation errors: the JSON name, or the path/query
+// parameter name for parameters not decoded from the body.
+func fieldPath(f reflect.StructField) string {
+	if _, ok := f.Tag.Lookup("json"); !ok {
+		for _, source := range []string{"path", "query"} {
+			if name, ok := f.Tag.Lookup(source); ok && name != "-" {
+				return name
+			}
+		}
+	}
+
+	name, _ := jsonFieldName(f)
+	return name
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// size returns the length of strings (in runes), slices and maps, and the value of numbers.
+func size(v reflect.Value) (float64, bool, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true, nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, nil
+	default:
+		return 0, false, errors.Errorf("cannot be compared, unsupported type %s", v.Type())
+	}
+}
+
+func compareSize(v reflect.Value, param string, ok func(size, limit float64) bool, lengthMsg, valueMsg string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return errors.Errorf("has invalid rule parameter %q", param)
+	}
+
+	s, isLength, err := size(v)
+	if err != nil {
+		return err
+	}
+
+	if ok(s, limit) {
+		return nil
+	}
+
+	if isLength {
+		return errors.Errorf(lengthMsg, param)
+	}
+	return errors.Errorf(valueMsg, param)
+}
+
+func validateMin(v reflect.Value, param string) error {
+	return compareSize(v, param, func(s, l float64) bool { return s >= l },
+		"must have at least %s characters or items", "must be greater than or equal to %s")
+}
+
+func validateMax(v reflect.Value, param string) error {
+	return compareSize(v, param, func(s, l float64) bool { return s <= l },
+		"must have at most %s characters or items", "must be less than or equal to %s")
+}
+
+func validateLen(v reflect.Value, param string) error {
+	return compareSize(v, param, func(s, l float64) bool { return s == l },
+		"must have exactly %s characters or items", "must be equal to %s")
+}
+
+func validateOneOf(v reflect.Value, param string) error {
+	value := fmt.Sprint(v.Interface())
+	for _, allowed := range strings.Fields(param) {
+		if value == allowed {
+			return nil
+		}
+	}
+
+	return errors.Errorf("must be one of: %s", strings.Join(strings.Fields(param), ", "))
+}
+
+func validateRegex(v reflect.Value, param string) error {
+	var re *regexp.Regexp
+	if cached, ok := regexCache.Load(param); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return errors.Errorf("has invalid regular expression %q", param)
+		}
+		regexCache.Store(param, compiled)
+		re = compiled
+	}
+
+	if v.Kind() != reflect.String || !re.MatchString(v.String()) {
+		return errors.Errorf("must match %s", param)
+	}
+
+	return nil
+}
+
+func validateEmail(v reflect.Value, _ string) error {
+	if v.Kind() == reflect.String {
+		if addr, err := mail.ParseAddress(v.String()); err == nil && addr.Address == v.String() {
+			return nil
+		}
+	}
+
+	return errors.New("must be a valid email address")
+}
+
+func validateURL(v reflect.Value, _ string) error {
+	if v.Kind() == reflect.String {
+		if u, err := url.ParseRequestURI(v.String()); err == nil && u.Scheme != "" && u.Host != "" {
+			return nil
+		}
+	}
+
+	return errors.New("must be a valid URL")
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func validateUUID(v reflect.Value, _ string) error {
+	if v.Kind() == reflect.String && uuidRegexp.MatchString(v.String()) {
+		return nil
+	}
+
+	return errors.New("must be a valid UUID")
+}
+
+func validateKSUID(v reflect.Value, _ string) error {
+	if v.Kind() == reflect.String {
+		if _, err := ksuid.Parse(v.String()); err == nil {
+			return nil
+		}
+	}
+
+	return errors.New("must be a valid KSUID")
+}
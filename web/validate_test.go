@@ -0,0 +1,152 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderItem struct {
+	Name     string `json:"name" validate:"required,max=10"`
+	Quantity int    `json:"quantity" validate:"min=1,max=100"`
+}
+
+type order struct {
+	ID       string      `json:"id" validate:"required,ksuid"`
+	Email    string      `json:"email" validate:"omitempty,email"`
+	Website  string      `json:"website,omitempty" validate:"omitempty,url"`
+	Status   string      `json:"status" validate:"oneof=pending paid"`
+	Code     string      `json:"code" validate:"regex=^[A-Z]{2,3}$"`
+	Items    []orderItem `json:"items" validate:"required,max=3"`
+	Shipping *orderItem  `json:"shipping"`
+	Note     *string     `json:"note" validate:"min=3"`
+	Internal string      `json:"-" validate:"-"`
+}
+
+func (o *order) Validate() []ValidationError {
+	return ValidateStruct(o)
+}
+
+func validOrder() order {
+	return order{
+		ID:     "0ujtsYcgvSTl8PAuAdqWYSMnLOv",
+		Email:  "john@example.com",
+		Status: "paid",
+		Code:   "ABC",
+		Items:  []orderItem{{Name: "book", Quantity: 1}},
+	}
+}
+
+func TestValidateStructValid(t *testing.T) {
+	o := validOrder()
+	assert.Empty(t, ValidateStruct(&o))
+	assert.Empty(t, ValidateStruct(o))
+	assert.Empty(t, ValidateStruct((*order)(nil)))
+}
+
+func TestValidateStructRules(t *testing.T) {
+	note := "ab"
+
+	o := validOrder()
+	o.ID = "not-a-ksuid"
+	o.Email = "john"
+	o.Website = "example.com"
+	o.Status = "refunded"
+	o.Code = "abc"
+	o.Items = []orderItem{{Name: "book", Quantity: 1}, {Name: "", Quantity: 0}, {Name: "encyclopedia", Quantity: 101}}
+	o.Shipping = &orderItem{Quantity: 1}
+	o.Note = &note
+
+	assert.Equal(t, []ValidationError{
+		NewValidationError("id", "id must be a valid KSUID"),
+		NewValidationError("email", "email must be a valid email address"),
+		NewValidationError("website", "website must be a valid URL"),
+		NewValidationError("status", "status must be one of: pending, paid"),
+		NewValidationError("code", "code must match ^[A-Z]{2,3}$"),
+		NewValidationError("items[1].name", "items[1].name is required"),
+		NewValidationError("items[1].quantity", "items[1].quantity must be greater than or equal to 1"),
+		NewValidationError("items[2].name", "items[2].name must have at most 10 characters or items"),
+		NewValidationError("items[2].quantity", "items[2].quantity must be less than or equal to 100"),
+		NewValidationError("shipping.name", "shipping.name is required"),
+		NewValidationError("note", "note must have at least 3 characters or items"),
+	}, ValidateStruct(&o))
+}
+
+func TestValidateStructRequired(t *testing.T) {
+	o := validOrder()
+	o.ID = ""
+	o.Items = []orderItem{}
+
+	assert.Equal(t, []ValidationError{
+		NewValidationError("id", "id is required"),
+		NewValidationError("items", "items is required"),
+	}, ValidateStruct(&o))
+}
+
+func TestValidateStructCustomRule(t *testing.T) {
+	RegisterValidation("prefix", func(v reflect.Value, param string) error {
+		if !strings.HasPrefix(v.String(), param) {
+			return errors.Errorf("must start with %s", param)
+		}
+		return nil
+	})
+
+	type request struct {
+		Key   string `json:"key" validate:"prefix=sk_"`
+		Other string `json:"other" validate:"unknown"`
+	}
+
+	assert.Equal(t, []ValidationError{
+		NewValidationError("key", "key must start with sk_"),
+		NewValidationError("other", `other has unknown validation rule "unknown"`),
+	}, ValidateStruct(request{Key: "pk_123"}))
+}
+
+func TestValidateStructNilEmbedded(t *testing.T) {
+	type Meta struct {
+		Owner string `json:"owner" validate:"required"`
+	}
+	type request struct {
+		*Meta
+		Name string `json:"name" validate:"required"`
+	}
+
+	// The fields of a nil embedded struct are skipped.
+	assert.Empty(t, ValidateStruct(&request{Name: "x"}))
+
+	assert.Equal(t, []ValidationError{
+		NewValidationError("owner", "owner is required"),
+	}, ValidateStruct(&request{Meta: &Meta{}, Name: "x"}))
+}
+
+func TestValidateStructParams(t *testing.T) {
+	type request struct {
+		ID    string `path:"id" validate:"uuid"`
+		Limit int    `query:"limit" validate:"max=50"`
+	}
+
+	assert.Equal(t, []ValidationError{
+		NewValidationError("id", "id must be a valid UUID"),
+		NewValidationError("limit", "limit must be less than or equal to 50"),
+	}, ValidateStruct(request{ID: "123", Limit: 51}))
+
+	assert.Empty(t, ValidateStruct(request{ID: "3f0c0f3e-8a6e-4a4e-9d1e-2b7c3c1a9f00", Limit: 50}))
+}
+
+func TestReadJSONValidateStruct(t *testing.T) {
+	r := &http.Request{
+		Body: io.NopCloser(strings.NewReader(`{"id": "0ujtsYcgvSTl8PAuAdqWYSMnLOv", "status": "paid", "code": "AB", "items": [{"name": "", "quantity": 1}]}`)),
+	}
+
+	var o order
+	err := ReadJSON(r, &o)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.HTTPStatus)
+	assert.Equal(t, map[string][]string{"items[0].name": {"items[0].name is required"}}, err.Errors)
+}
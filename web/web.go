@@ -3,18 +3,24 @@ package web
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"reflect"
 	"runtime"
+	"strings"
 
 	"github.com/gorilla/mux"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/cors"
+	"github.com/starclusterteam/go-starbox/auth"
 	"github.com/starclusterteam/go-starbox/config"
 	"github.com/starclusterteam/go-starbox/constants"
 	"github.com/starclusterteam/go-starbox/constants/envvar"
+	"github.com/starclusterteam/go-starbox/health"
 	"github.com/starclusterteam/go-starbox/log"
+	"github.com/starclusterteam/go-starbox/ratelimit"
 
 	// This metrics import is used to initialize Prometheus HTTP endpoint server.
 	_ "github.com/starclusterteam/go-starbox/metrics/auto"
@@ -32,6 +38,8 @@ type Server interface {
 // Web is a generic webserver.
 type Web struct {
 	server *http.Server
+	health *health.Registry
+	tls    tlsOptions
 }
 
 type serverOptions struct {
@@ -41,6 +49,23 @@ type serverOptions struct {
 	pingPath       string
 	defaultHandler http.Handler
 	cors           *cors.Cors
+	health         bool
+	healthRegistry *health.Registry
+	livenessPath   string
+	readinessPath  string
+	openAPIPath    string
+	openAPIInfo    OpenAPIInfo
+	rateLimit      Middleware
+	authenticator  auth.Authenticator
+	clientCert     bool
+	clientCertOpts []ClientCertOption
+	featureFlags   Middleware
+	tls            tlsOptions
+
+	requestSampling *log.RequestSampling
+	accessLog       bool
+	accessLogOpts   []AccessLogOption
+	proxy           []ProxyOption
 }
 
 // New returns new web instance that handle the given routes. If no port
@@ -52,27 +77,94 @@ func New(routes Routes, opts ...Option) Server {
 		tracer:   tracing.Tracer,
 		ping:     true,
 		pingPath: "/api/v1/ping",
+
+		health:         true,
+		healthRegistry: health.DefaultRegistry,
+		livenessPath:   "/livez",
+		readinessPath:  "/readyz",
+
+		tls: tlsOptions{clientAuth: tls.RequireAndVerifyClientCert},
+
+		requestSampling: log.DefaultRequestSampling,
+
+		proxy: []ProxyOption{TrustProxies(trustedProxiesFromEnv()...)},
 	}
 
 	for _, o := range opts {
 		o(&options)
 	}
 
+	proxyHeaders := ProxyHeadersMiddleware(options.proxy...)
+
+	var clientCert Middleware
+	if options.clientCert {
+		clientCert = options.tls.clientCertMiddleware(options.clientCertOpts)
+	}
+
 	rs := make([]Route, len(routes))
 	for i, r := range routes {
-		rs[i] = r.WithMiddlewares(
+		requestLog := requestLogger(options.requestSampling)
+		if options.accessLog {
+			opts := []AccessLogOption{WithAccessLogSampling(options.requestSampling)}
+			opts = append(opts, options.accessLogOpts...)
+			if r.bodyLimit > 0 {
+				opts = append(opts, WithAccessLogBodies(r.bodyLimit))
+			}
+			requestLog = AccessLogMiddleware(r.String(), opts...)
+		}
+
+		middlewares := []Middleware{
 			panicHandler,
+			proxyHeaders,
 			xRequestID,
-			logger,
+			requestLog,
 			TracingMiddleware(options.tracer, r.String()),
 			defaultServerMetrics.Middleware(r.Pattern),
-		)
+		}
+
+		if clientCert != nil {
+			middlewares = append(middlewares, clientCert)
+		}
+
+		if options.authenticator != nil && !r.public {
+			middlewares = append(middlewares, AuthMiddleware(options.authenticator))
+		}
+
+		if len(r.scopes) > 0 || len(r.roles) > 0 {
+			middlewares = append(middlewares, RequirePrincipal(r.scopes, r.roles))
+		}
+
+		if options.rateLimit != nil {
+			middlewares = append(middlewares, options.rateLimit)
+		}
+
+		if options.featureFlags != nil {
+			middlewares = append(middlewares, options.featureFlags)
+		}
+
+		rs[i] = r.WithMiddlewares(middlewares...)
 	}
 
 	if options.ping {
 		rs = append(rs, NewRoute("GET", options.pingPath, Ping))
 	}
 
+	if options.health {
+		rs = append(rs,
+			NewRoute("GET", options.livenessPath, LivenessHandler(options.healthRegistry)),
+			NewRoute("GET", options.readinessPath, ReadinessHandler(options.healthRegistry)),
+		)
+	}
+
+	if options.openAPIPath != "" {
+		format := "json"
+		if strings.HasSuffix(options.openAPIPath, ".yaml") || strings.HasSuffix(options.openAPIPath, ".yml") {
+			format = "yaml"
+		}
+
+		rs = append(rs, NewRoute("GET", options.openAPIPath, OpenAPIHandler(rs, options.openAPIInfo, format)))
+	}
+
 	router := NewRouter(rs)
 
 	if options.defaultHandler != nil {
@@ -90,12 +182,18 @@ func New(routes Routes, opts ...Option) Server {
 			Addr:    options.addr,
 			Handler: finalHandler,
 		},
+		health: options.healthRegistry,
+		tls:    options.tls,
 	}
 }
 
 // Run starts the http server. It returns the error returned by http.Server.ListenAndServe, except if
-// that error is http.ErrServerClosed.
+// that error is http.ErrServerClosed. The server uses HTTPS if configured with WithServerTLSFromParams.
 func (w *Web) Run() error {
+	if w.tls.certFile != "" {
+		return w.RunSSL(w.tls.certFile, w.tls.keyFile)
+	}
+
 	log.Infof("Running web server on %s", w.server.Addr)
 
 	if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -105,18 +203,33 @@ func (w *Web) Run() error {
 	return nil
 }
 
+// RunSSL starts the https server with the given certificate and key, and the client CAs and TLS options
+// given to New. The files are reloaded when they change.
 func (w *Web) RunSSL(certFile, keyFile string) error {
+	tlsConfig, certs, err := w.tls.config(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	defer certs.Close()
+
+	w.server.TLSConfig = tlsConfig
+	if w.tls.verifying() && len(w.tls.clientCAFiles) > 0 {
+		w.server.ConnContext = verifiedConnContext
+	}
+
 	log.Infof("Running web server on https://%s", w.server.Addr)
 
-	if err := w.server.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+	if err := w.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
 
-// Stop gracefully shutdowns the http server.
+// Stop gracefully shutdowns the http server. The health registry is marked as shutting down first,
+// so the readiness endpoint reports the server as unavailable while it drains.
 func (w *Web) Stop(ctx context.Context) error {
+	w.health.Shutdown()
 	return w.server.Shutdown(ctx)
 }
 
@@ -129,6 +242,15 @@ type Route struct {
 	Pattern string
 	Handler http.Handler
 	prefix  bool
+
+	requestType  reflect.Type
+	responseType reflect.Type
+
+	public bool
+	scopes []string
+	roles  []string
+
+	bodyLimit int
 }
 
 // NewRoute returns a new route for this params.
@@ -139,6 +261,10 @@ func NewRoute(method, path string, handler http.Handler, opts ...RouteOption) Ro
 		Handler: handler,
 	}
 
+	if th, ok := handler.(typedRouteHandler); ok {
+		r.requestType, r.responseType = th.types()
+	}
+
 	for _, o := range opts {
 		o(&r)
 	}
@@ -148,12 +274,8 @@ func NewRoute(method, path string, handler http.Handler, opts ...RouteOption) Ro
 
 // WithMiddlewares returns a route with its handler wrapped with the given middlewares.
 func (r Route) WithMiddlewares(middlewares ...Middleware) Route {
-	return Route{
-		Method:  r.Method,
-		Pattern: r.Pattern,
-		Handler: MiddlewareChain(middlewares...)(r.Handler),
-		prefix:  r.prefix,
-	}
+	r.Handler = MiddlewareChain(middlewares...)(r.Handler)
+	return r
 }
 
 // String returns the name of the root as a combination of the method and the route pattern.
@@ -216,6 +338,86 @@ func WithCORS(c *cors.Cors) Option {
 	}
 }
 
+// WithHealth can be used to disable the liveness and readiness routes by giving WithHealth(false) as option to New().
+// The endpoints default to "/livez" and "/readyz". If you want to specify custom paths use `WithHealthPaths`.
+func WithHealth(enabled bool) Option {
+	return func(o *serverOptions) {
+		o.health = enabled
+	}
+}
+
+// WithHealthPaths sets the paths of the liveness and readiness endpoints.
+func WithHealthPaths(livenessPath, readinessPath string) Option {
+	return func(o *serverOptions) {
+		o.health = true
+		o.livenessPath = livenessPath
+		o.readinessPath = readinessPath
+	}
+}
+
+// WithHealthRegistry sets the registry whose checks are exposed by the health endpoints.
+// It defaults to health.DefaultRegistry, a nil registry is ignored.
+func WithHealthRegistry(registry *health.Registry) Option {
+	return func(o *serverOptions) {
+		if registry != nil {
+			o.healthRegistry = registry
+		}
+	}
+}
+
+// WithRateLimit limits the requests to all the routes using the limiter and the key returned by keyFunc,
+// see RateLimitMiddleware. A nil keyFunc limits per client IP address. The ping and health routes are
+// not limited.
+func WithRateLimit(limiter *ratelimit.Limiter, keyFunc RateLimitKeyFunc) Option {
+	return func(o *serverOptions) {
+		o.rateLimit = RateLimitMiddleware(limiter, keyFunc)
+	}
+}
+
+// WithAuth authenticates the requests to all the routes, except the ones created with the Public option,
+// see AuthMiddleware. The ping and health routes are not authenticated. Rate limits are applied after
+// authentication, so the principal can be used as rate limit key.
+func WithAuth(authenticator auth.Authenticator) Option {
+	return func(o *serverOptions) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithFeatureFlags adds the feature flags evaluation context to the requests of all the routes, see
+// FeatureFlagsMiddleware. It is added after authentication, so the flags can depend on the principal.
+func WithFeatureFlags(fns ...FlagsContextFunc) Option {
+	return func(o *serverOptions) {
+		o.featureFlags = FeatureFlagsMiddleware(fns...)
+	}
+}
+
+// WithRequestLogSampling samples the logs of the requests, see log.RequestSampling. It defaults to
+// log.DefaultRequestSampling.
+func WithRequestLogSampling(sampling *log.RequestSampling) Option {
+	return func(o *serverOptions) {
+		o.requestSampling = sampling
+	}
+}
+
+// WithProxyHeaders configures the resolution of the clients of the requests forwarded by proxies and the
+// headers stripped from the requests, see ProxyHeadersMiddleware. The proxies of the WEB_TRUSTED_PROXIES
+// environment variable, a comma separated list of networks in CIDR notation or of IP addresses, are
+// trusted, and the "X-Vulcand" headers stripped, by default.
+func WithProxyHeaders(opts ...ProxyOption) Option {
+	return func(o *serverOptions) {
+		o.proxy = append(o.proxy, opts...)
+	}
+}
+
+// WithAccessLog logs the requests with AccessLogMiddleware instead of the default request logger, which
+// only logs their method, URL, latency and status. The sampling set by WithRequestLogSampling applies.
+func WithAccessLog(opts ...AccessLogOption) Option {
+	return func(o *serverOptions) {
+		o.accessLog = true
+		o.accessLogOpts = append(o.accessLogOpts, opts...)
+	}
+}
+
 // RouteOption is a functional option for creating routes.
 type RouteOption func(*Route)
 
@@ -227,6 +429,44 @@ func WithPrefix() RouteOption {
 	}
 }
 
+// WithSchema annotates a route with its request and response types, used to generate the OpenAPI document.
+// Either value may be nil. Routes created with Handle or TypedHandler are annotated automatically.
+func WithSchema(request, response interface{}) RouteOption {
+	return func(r *Route) {
+		r.requestType = reflect.TypeOf(request)
+		r.responseType = reflect.TypeOf(response)
+	}
+}
+
+// Public opts a route out of the authentication enabled with WithAuth.
+func Public() RouteOption {
+	return func(r *Route) {
+		r.public = true
+	}
+}
+
+// RequireScopes requires the principal of the requests to have all the given scopes, see RequirePrincipal.
+func RequireScopes(scopes ...string) RouteOption {
+	return func(r *Route) {
+		r.scopes = append(r.scopes, scopes...)
+	}
+}
+
+// RequireRoles requires the principal of the requests to have all the given roles, see RequirePrincipal.
+func RequireRoles(roles ...string) RouteOption {
+	return func(r *Route) {
+		r.roles = append(r.roles, roles...)
+	}
+}
+
+// CaptureBodies logs up to limit bytes of the request and response bodies of a route, see
+// WithAccessLogBodies. It requires the access log to be enabled with WithAccessLog.
+func CaptureBodies(limit int) RouteOption {
+	return func(r *Route) {
+		r.bodyLimit = limit
+	}
+}
+
 // NewRouter returns a new router
 func NewRouter(routes Routes) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
@@ -262,11 +502,17 @@ func panicHandler(h http.Handler) http.Handler {
 	})
 }
 
+// xRequestID sets the X-Request-Id header of the request and of the response, and stores the id in the
+// request context. The id of the incoming request is kept if valid, so it is propagated across services.
 func xRequestID(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id := generateID()
+		id := r.Header.Get("X-Request-Id")
+		if !validRequestID(id) {
+			id = generateID()
+		}
 
 		SetLogger(r, GetLogger(r).With("request_id", id))
+		*r = *r.WithContext(ContextWithRequestID(r.Context(), id))
 
 		r.Header.Set("X-Request-Id", id)
 		w.Header().Set("X-Request-Id", id)
@@ -275,6 +521,21 @@ func xRequestID(h http.Handler) http.Handler {
 	})
 }
 
+// validRequestID reports whether id is short and only made of characters safe to log and to send in headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
 func generateID() string {
 	r := make([]byte, 16)
 	_, err := rand.Read(r)